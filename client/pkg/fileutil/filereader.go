@@ -46,7 +46,16 @@ type FileBufReader struct {
 }
 
 func NewFileBufReader(fr FileReader) *FileBufReader {
-	bufReader := bufio.NewReader(fr)
+	return newFileBufReader(fr, bufio.NewReader(fr))
+}
+
+// NewFileBufReaderSize is like NewFileBufReader, but reads through a buffer of
+// at least size bytes.
+func NewFileBufReaderSize(fr FileReader, size int) *FileBufReader {
+	return newFileBufReader(fr, bufio.NewReaderSize(fr, size))
+}
+
+func newFileBufReader(fr FileReader, bufReader *bufio.Reader) *FileBufReader {
 	fi, err := fr.FileInfo()
 	if err != nil {
 		// This should never happen.
@@ -42,3 +42,12 @@ func TestFileBufReader(t *testing.T) {
 	assert.Equal(t, fi.Mode(), fbr.FileInfo().Mode())
 	assert.Equal(t, fi.ModTime(), fbr.FileInfo().ModTime())
 }
+
+func TestFileBufReaderSize(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "wal")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert.Equal(t, 4096, NewFileBufReader(NewFileReader(f)).Size())
+	assert.Equal(t, 1<<20, NewFileBufReaderSize(NewFileReader(f), 1<<20).Size())
+}
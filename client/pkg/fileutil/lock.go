@@ -19,6 +19,12 @@ import (
 	"os"
 )
 
-var ErrLocked = errors.New("fileutil: file already locked")
+var (
+	ErrLocked = errors.New("fileutil: file already locked")
+
+	// ErrSharedLockUnsupported is returned by TryRLockFile on platforms
+	// without shared file locks.
+	ErrSharedLockUnsupported = errors.New("fileutil: shared file locks are not supported")
+)
 
 type LockedFile struct{ *os.File }
@@ -38,9 +38,16 @@ var (
 		Start:  0,
 		Len:    0,
 	}
+	rdlck = syscall.Flock_t{
+		Type:   syscall.F_RDLCK,
+		Whence: int16(io.SeekStart),
+		Start:  0,
+		Len:    0,
+	}
 
-	linuxTryLockFile = flockTryLockFile
-	linuxLockFile    = flockLockFile
+	linuxTryLockFile  = flockTryLockFile
+	linuxTryRLockFile = flockTryRLockFile
+	linuxLockFile     = flockLockFile
 )
 
 func init() {
@@ -48,6 +55,7 @@ func init() {
 	getlk := syscall.Flock_t{Type: syscall.F_RDLCK}
 	if err := syscall.FcntlFlock(0, unix.F_OFD_GETLK, &getlk); err == nil {
 		linuxTryLockFile = ofdTryLockFile
+		linuxTryRLockFile = ofdTryRLockFile
 		linuxLockFile = ofdLockFile
 	}
 }
@@ -73,6 +81,27 @@ func ofdTryLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error
 	return &LockedFile{f}, nil
 }
 
+func TryRLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	return linuxTryRLockFile(path, flag, perm)
+}
+
+func ofdTryRLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, fmt.Errorf("ofdTryRLockFile failed to open %q (%w)", path, err)
+	}
+
+	flock := rdlck
+	if err = syscall.FcntlFlock(f.Fd(), unix.F_OFD_SETLK, &flock); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			err = ErrLocked
+		}
+		return nil, err
+	}
+	return &LockedFile{f}, nil
+}
+
 func LockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
 	return linuxLockFile(path, flag, perm)
 }
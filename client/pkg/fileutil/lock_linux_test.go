@@ -20,10 +20,11 @@ import "testing"
 
 // TestLockAndUnlockSyscallFlock tests the fallback flock using the flock syscall.
 func TestLockAndUnlockSyscallFlock(t *testing.T) {
-	oldTryLock, oldLock := linuxTryLockFile, linuxLockFile
+	oldTryLock, oldTryRLock, oldLock := linuxTryLockFile, linuxTryRLockFile, linuxLockFile
 	defer func() {
-		linuxTryLockFile, linuxLockFile = oldTryLock, oldLock
+		linuxTryLockFile, linuxTryRLockFile, linuxLockFile = oldTryLock, oldTryRLock, oldLock
 	}()
-	linuxTryLockFile, linuxLockFile = flockTryLockFile, flockLockFile
+	linuxTryLockFile, linuxTryRLockFile, linuxLockFile = flockTryLockFile, flockTryRLockFile, flockLockFile
 	TestLockAndUnlock(t)
+	TestTryRLockFile(t)
 }
@@ -31,6 +31,12 @@ func TryLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
 	return &LockedFile{f}, nil
 }
 
+// TryRLockFile always fails with ErrSharedLockUnsupported, plan9 only has
+// exclusive access files.
+func TryRLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	return nil, ErrSharedLockUnsupported
+}
+
 func LockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
 	if err := os.Chmod(path, syscall.DMEXCL|PrivateFileMode); err != nil {
 		return nil, err
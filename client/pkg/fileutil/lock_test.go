@@ -76,3 +76,27 @@ func TestLockAndUnlock(t *testing.T) {
 		t.Error("unexpected blocking")
 	}
 }
+
+func TestTryRLockFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "lock")
+	require.NoError(t, err)
+	f.Close()
+
+	// shared locks can be held together
+	r1, err := TryRLockFile(f.Name(), os.O_RDONLY, PrivateFileMode)
+	require.NoError(t, err)
+	r2, err := TryRLockFile(f.Name(), os.O_RDONLY, PrivateFileMode)
+	require.NoError(t, err)
+
+	// but not with an exclusive lock
+	_, err = TryLockFile(f.Name(), os.O_WRONLY, PrivateFileMode)
+	require.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, r1.Close())
+	require.NoError(t, r2.Close())
+
+	l, err := TryLockFile(f.Name(), os.O_WRONLY, PrivateFileMode)
+	require.NoError(t, err)
+	_, err = TryRLockFile(f.Name(), os.O_RDONLY, PrivateFileMode)
+	require.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, l.Close())
+}
@@ -24,6 +24,10 @@ func TryLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
 	return flockTryLockFile(path, flag, perm)
 }
 
+func TryRLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	return flockTryRLockFile(path, flag, perm)
+}
+
 func LockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
 	return flockLockFile(path, flag, perm)
 }
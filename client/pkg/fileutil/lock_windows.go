@@ -28,6 +28,18 @@ import (
 var errLocked = errors.New("the process cannot access the file because another process has locked a portion of the file")
 
 func TryLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	f, err := open(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &LockedFile{f}, nil
+}
+
+func TryRLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
 	f, err := open(path, flag, perm)
 	if err != nil {
 		return nil, err
@@ -44,7 +56,7 @@ func LockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := lockFile(windows.Handle(f.Fd()), 0); err != nil {
+	if err := lockFile(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK); err != nil {
 		f.Close()
 		return nil, err
 	}
@@ -85,7 +97,7 @@ func lockFile(fd windows.Handle, flags uint32) error {
 	if fd == windows.InvalidHandle {
 		return nil
 	}
-	err := windows.LockFileEx(fd, flags|windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+	err := windows.LockFileEx(fd, flags, 0, 1, 0, &windows.Overlapped{})
 	if err == nil {
 		return nil
 	} else if err.Error() == errLocked.Error() {
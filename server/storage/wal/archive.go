@@ -0,0 +1,78 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+)
+
+// ErrLiveSegment is returned by CopySegment when asked to copy the tail
+// segment of a WAL, which is still being appended to.
+var ErrLiveSegment = errors.New("wal: segment is the live tail of the WAL")
+
+// CopySegment copies the finalized WAL segment src to dst, e.g. to archive it
+// off-host, and returns the crc of the copied bytes, computed with the same
+// table as the record crcs, so that the copy can be verified independently of
+// the source. The tail segment, the last one of the WAL directory, is refused
+// with ErrLiveSegment. dst must not exist, it is synced once written and
+// removed if the copy fails.
+func CopySegment(src, dst string) (crc uint32, err error) {
+	name := filepath.Base(src)
+	if _, _, err = parseWALName(name); err != nil {
+		return 0, fmt.Errorf("wal: %q is not a WAL segment: %w", src, err)
+	}
+	names, err := readWALNames(zap.NewNop(), filepath.Dir(src))
+	if err != nil {
+		return 0, err
+	}
+	if names[len(names)-1] == name {
+		return 0, fmt.Errorf("%w: %q", ErrLiveSegment, src)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fileutil.PrivateFileMode)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	h := crc32.New(crcTable)
+	if _, err = io.Copy(io.MultiWriter(out, h), in); err != nil {
+		return 0, err
+	}
+	if err = fileutil.Fsync(out); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
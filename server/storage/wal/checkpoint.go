@@ -0,0 +1,68 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+)
+
+// Checkpoint locates a segment of a WAL opened at a given snapshot.
+type Checkpoint struct {
+	// Name is the file name of the segment.
+	Name string
+	// Seq is the sequence number of the segment.
+	Seq uint64
+	// Index is the first raft index expected in the segment. The segment
+	// holds no entry below it, but entries of the previous segments may be
+	// overwritten by the ones it holds.
+	Index uint64
+	// Offset is the byte offset of the beginning of the segment, counted like
+	// ReadPosition.Offset from the beginning of the first segment selected by
+	// the snapshot.
+	Offset int64
+}
+
+// Checkpoints returns the segments of the WAL in dir that a WAL opened at
+// snap reads, in order, from the one holding the snapshot index to the tail.
+// It is a persistable form of the index Open builds from the file names, to
+// find the segment holding a given index without listing the directory. The
+// checkpoints stay valid until the WAL is cut, which appends one, or purged,
+// which removes the segments before the snapshot.
+func Checkpoints(lg *zap.Logger, dir string, snap walpb.Snapshot) ([]Checkpoint, error) {
+	names, nameIndex, err := selectWALFiles(lg, dir, snap)
+	if err != nil {
+		return nil, err
+	}
+	cps := make([]Checkpoint, 0, len(names)-nameIndex)
+	var offset int64
+	for _, name := range names[nameIndex:] {
+		seq, index, err := parseWALName(name)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		cps = append(cps, Checkpoint{Name: name, Seq: seq, Index: index, Offset: offset})
+		offset += info.Size()
+	}
+	return cps, nil
+}
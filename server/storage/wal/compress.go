@@ -0,0 +1,81 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+)
+
+// compressedTypeFlag is set in the type of records written with
+// WithCompression whose data is compressed with zstd. Records of unknown
+// types fail to decode, so etcd versions unaware of the flag stop at them
+// rather than misreading their data.
+const compressedTypeFlag = int64(1) << 32
+
+// maxCompressedRecordBytes bounds the data of records written compressed, and
+// so the size records are decompressed to, keeping a corrupted or crafted
+// record from making the decoder allocate an arbitrary amount of memory.
+// Larger records are written uncompressed.
+const maxCompressedRecordBytes = 64 * 1024 * 1024
+
+// zstdCoders returns the zstd encoder and decoder shared by all WALs, both
+// safe for concurrent use through EncodeAll and DecodeAll. They are only
+// created once a compressed record is written or read.
+var zstdCoders = sync.OnceValues(func() (*zstd.Encoder, *zstd.Decoder) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		panic(err)
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxCompressedRecordBytes))
+	if err != nil {
+		panic(err)
+	}
+	return enc, dec
+})
+
+// compressRecord compresses the data of an entry record in place, flagging
+// its type, unless compressing doesn't make it smaller or the data is larger
+// than maxCompressedRecordBytes.
+func compressRecord(rec *walpb.Record) {
+	if rec.Type != EntryType || len(rec.Data) == 0 || len(rec.Data) > maxCompressedRecordBytes {
+		return
+	}
+	enc, _ := zstdCoders()
+	data := enc.EncodeAll(rec.Data, nil)
+	if len(data) >= len(rec.Data) {
+		return
+	}
+	rec.Type |= compressedTypeFlag
+	rec.Data = data
+}
+
+// decompressRecord decompresses the data of a record compressed by
+// compressRecord in place, clearing the flag from its type. It fails if the
+// data decompresses to more than maxCompressedRecordBytes.
+func decompressRecord(rec *walpb.Record) error {
+	_, dec := zstdCoders()
+	data, err := dec.DecodeAll(rec.Data, nil)
+	if err != nil {
+		return fmt.Errorf("wal: failed to decompress record: %w", err)
+	}
+	rec.Type &^= compressedTypeFlag
+	rec.Data = data
+	return nil
+}
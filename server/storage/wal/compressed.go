@@ -0,0 +1,116 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	// ErrDecompressedTooLarge is returned when a compressed WAL file
+	// decompresses to more than decompressedSizeLimit.
+	ErrDecompressedTooLarge = errors.New("wal: decompressed file exceeds the size limit")
+)
+
+// decompressedSegmentsLimit is how many times SegmentSizeBytes a compressed
+// WAL file can decompress to. Segments only exceed their size by the last
+// records written before a cut, while a small crafted or corrupted file could
+// otherwise decompress to an arbitrary size in memory.
+const decompressedSegmentsLimit = 4
+
+// decompressedSizeLimit returns the most bytes a compressed WAL file can
+// decompress to.
+func decompressedSizeLimit() int64 {
+	if SegmentSizeBytes > math.MaxInt64/decompressedSegmentsLimit {
+		return math.MaxInt64 - 1
+	}
+	return decompressedSegmentsLimit * SegmentSizeBytes
+}
+
+// decompressedFileInfo reports the size of the decompressed content of a
+// file, which the decoder relies on to bound record sizes.
+type decompressedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (fi decompressedFileInfo) Size() int64 {
+	return fi.size
+}
+
+// newReadFileReader returns a reader of the WAL file opened for reading. Files
+// compressed with gzip or zstd, for example archived segments, are
+// transparently decompressed into memory. The first record of a WAL file is a
+// small crc record, so a length field can't be mistaken for a compression
+// magic.
+func newReadFileReader(f *os.File) (fileutil.FileReader, error) {
+	magic := make([]byte, len(zstdMagic))
+	n, err := f.ReadAt(magic, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %q: %w", f.Name(), err)
+		}
+		defer zr.Close()
+		return newDecompressedFileReader(f, zr)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %q: %w", f.Name(), err)
+		}
+		defer zr.Close()
+		return newDecompressedFileReader(f, zr)
+	default:
+		return fileutil.NewFileReader(f), nil
+	}
+}
+
+// newDecompressedFileReader reads the whole content of f decompressed by r
+// into memory, failing with ErrDecompressedTooLarge past
+// decompressedSizeLimit.
+func newDecompressedFileReader(f *os.File, r io.Reader) (fileutil.FileReader, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	limit := decompressedSizeLimit()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %q: %w", f.Name(), err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("failed to decompress %q: %w", f.Name(), ErrDecompressedTooLarge)
+	}
+	return &bytesFileReader{Reader: bytes.NewReader(data), fi: decompressedFileInfo{FileInfo: fi, size: int64(len(data))}}, nil
+}
@@ -15,6 +15,7 @@
 package wal
 
 import (
+	"bufio"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -34,11 +35,41 @@ const minSectorSize = 512
 // frameSizeBytes is frame size in bytes, including record size and padding size.
 const frameSizeBytes = 8
 
+// frameSequencedFlag is set in the length field of frames written with
+// WithRecordSequencing, whose header is followed by the 8 bytes sequence
+// number of the record.
+const frameSequencedFlag = uint64(0x40) << 56
+
+// SequenceError is returned when the sequence number of a record does not
+// follow the one of the previous record, meaning that records were lost,
+// repeated or reordered.
+type SequenceError struct {
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("wal: record sequence mismatch, expected %d, got %d", e.Expected, e.Actual)
+}
+
 type Decoder interface {
 	Decode(rec *walpb.Record) error
 	LastOffset() int64
+	Offset() int64
 	LastCRC() uint32
 	UpdateCRC(prevCrc uint32)
+	SkippedRegions() []SkippedRegion
+}
+
+// SkippedRegion is a record skipped by a decoder configured with
+// MaxSkippableErrors because it failed the crc check.
+type SkippedRegion struct {
+	// File is the name of the file holding the record.
+	File string
+	// Offset is the offset of the record frame within File.
+	Offset int64
+	// Size is the size of the record frame, including its header and padding.
+	Size int64
 }
 
 type decoder struct {
@@ -47,24 +78,98 @@ type decoder struct {
 
 	// lastValidOff file offset following the last valid decoded record
 	lastValidOff int64
-	crc          hash.Hash32
+	// consumedOff total size of the files the decoder has moved past
+	consumedOff int64
+	crc         hash.Hash32
 
 	// continueOnCrcError - causes the decoder to continue working even in case of crc mismatch.
 	// This is a desired mode for tools performing inspection of the corrupted WAL logs.
 	// See comments on 'Decode' method for semantic.
 	continueOnCrcError bool
+	// tolerateZeroPadding - see DecoderConfig.TolerateZeroPadding.
+	tolerateZeroPadding bool
+
+	// lastSeq is the sequence number of the last decoded sequenced record,
+	// valid only if hasSeq is set. Unsequenced records, like the CrcType and
+	// FormatType records heading each file, leave both as they are, so the
+	// sequence is checked across them and across files.
+	lastSeq uint64
+	hasSeq  bool
+	// format is the version declared by the last FormatType record of the
+	// current file, 0 if there is none.
+	format uint32
+
+	// maxSkippableErrors - see DecoderConfig.MaxSkippableErrors.
+	maxSkippableErrors int
+	skipped            []SkippedRegion
 }
 
-func NewDecoderAdvanced(continueOnCrcError bool, r ...fileutil.FileReader) Decoder {
+// DecoderConfig holds the optional behaviors of a decoder.
+type DecoderConfig struct {
+	// ContinueOnCrcError causes the decoder to continue working even in case of crc mismatch.
+	// This is a desired mode for tools performing inspection of the corrupted WAL logs.
+	ContinueOnCrcError bool
+	// TolerateZeroPadding makes the decoder treat a partially read frame header
+	// consisting only of zeros as the end of the written data of a file, instead
+	// of returning io.ErrUnexpectedEOF. Frames are 8-byte aligned, so this only
+	// happens to files cut short in their zeroed, preallocated region, such as
+	// copies truncated to the data written so far. A header torn by a crash
+	// reads the same, so this is meant for inspection tools, not for recovery.
+	TolerateZeroPadding bool
+	// MaxSkippableErrors is the number of records failing the crc check the
+	// decoder skips before failing, for tools salvaging a corrupted WAL. The
+	// running crc is resumed from the crc stored in a skipped record, which
+	// lets the following records be checked as long as the record frame is
+	// intact. Skipped records are reported by SkippedRegions. 0 makes the
+	// decoder fail on the first crc mismatch. Resyncing involves no heuristics
+	// or randomness, so salvaging the same files always gives the same records
+	// and skipped regions.
+	MaxSkippableErrors int
+	// ReadAhead is the size in bytes of the buffer each file is read through,
+	// larger buffers take fewer read syscalls. 0 keeps the default of bufio.
+	ReadAhead int
+}
+
+// NewDecoderWithConfig creates a decoder reading the given files in order.
+func NewDecoderWithConfig(cfg DecoderConfig, r ...fileutil.FileReader) Decoder {
 	readers := make([]*fileutil.FileBufReader, len(r))
 	for i := range r {
-		readers[i] = fileutil.NewFileBufReader(r[i])
+		if cfg.ReadAhead > 0 {
+			readers[i] = fileutil.NewFileBufReaderSize(r[i], cfg.ReadAhead)
+		} else {
+			readers[i] = fileutil.NewFileBufReader(r[i])
+		}
 	}
 	return &decoder{
-		brs:                readers,
-		crc:                crc.New(0, crcTable),
-		continueOnCrcError: continueOnCrcError,
+		brs:                 readers,
+		crc:                 crc.New(0, crcTable),
+		continueOnCrcError:  cfg.ContinueOnCrcError,
+		tolerateZeroPadding: cfg.TolerateZeroPadding,
+		maxSkippableErrors:  cfg.MaxSkippableErrors,
+	}
+}
+
+// newDecoderAt returns a decoder reading r from offset in the first file on,
+// continuing the crc chain from prevCrc. Unlike seek, it moves the underlying
+// file, so the data before offset is not read at all.
+func newDecoderAt(offset int64, prevCrc uint32, r ...fileutil.FileReader) (Decoder, error) {
+	if len(r) != 0 && offset != 0 {
+		s, ok := r[0].(io.Seeker)
+		if !ok {
+			return nil, errors.New("wal: file reader does not support seeking")
+		}
+		if _, err := s.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
 	}
+	d := NewDecoder(r...).(*decoder)
+	d.lastValidOff = offset
+	d.UpdateCRC(prevCrc)
+	return d, nil
+}
+
+func NewDecoderAdvanced(continueOnCrcError bool, r ...fileutil.FileReader) Decoder {
+	return NewDecoderWithConfig(DecoderConfig{ContinueOnCrcError: continueOnCrcError}, r...)
 }
 
 func NewDecoder(r ...fileutil.FileReader) Decoder {
@@ -89,23 +194,34 @@ func (d *decoder) decodeRecord(rec *walpb.Record) error {
 	}
 
 	fileBufReader := d.brs[0]
-	l, err := readInt64(fileBufReader)
+	l, err := d.readFrameHeader(fileBufReader)
 	if errors.Is(err, io.EOF) || (err == nil && l == 0) {
-		// hit end of file or preallocated space
-		d.brs = d.brs[1:]
-		if len(d.brs) == 0 {
+		// hit end of file or preallocated space; an all-zero frame header
+		// marks the end of the data written to this file
+		if len(d.brs) == 1 {
+			d.brs = d.brs[1:]
 			return io.EOF
 		}
-		d.lastValidOff = 0
+		d.nextFile()
 		return d.decodeRecord(rec)
 	}
 	if err != nil {
 		return err
 	}
 
+	headerBytes := int64(frameSizeBytes)
+	sequenced := uint64(l)&frameSequencedFlag != 0
+	var seq uint64
+	if sequenced {
+		if seq, err = readSequence(fileBufReader); err != nil {
+			return err
+		}
+		headerBytes += frameSizeBytes
+	}
+
 	recBytes, padBytes := decodeFrameSize(l)
 	// The length of current WAL entry must be less than the remaining file size.
-	maxEntryLimit := fileBufReader.FileInfo().Size() - d.lastValidOff - padBytes
+	maxEntryLimit := fileBufReader.FileInfo().Size() - d.lastValidOff - headerBytes + frameSizeBytes - padBytes
 	if recBytes > maxEntryLimit {
 		return fmt.Errorf("%w: [wal] max entry size limit exceeded when reading %q, recBytes: %d, fileSize(%d) - offset(%d) - padBytes(%d) = entryLimit(%d)",
 			io.ErrUnexpectedEOF, fileBufReader.FileInfo().Name(), recBytes, fileBufReader.FileInfo().Size(), d.lastValidOff, padBytes, maxEntryLimit)
@@ -121,7 +237,7 @@ func (d *decoder) decodeRecord(rec *walpb.Record) error {
 		return err
 	}
 	if err := rec.Unmarshal(data[:recBytes]); err != nil {
-		if d.isTornEntry(data) {
+		if d.isTornEntry(data, headerBytes) {
 			return io.ErrUnexpectedEOF
 		}
 		return err
@@ -134,24 +250,94 @@ func (d *decoder) decodeRecord(rec *walpb.Record) error {
 			return err
 		}
 		if err := rec.Validate(d.crc.Sum32()); err != nil {
+			if len(d.skipped) < d.maxSkippableErrors && !d.isTornEntry(data, headerBytes) {
+				size := headerBytes + recBytes + padBytes
+				d.skipped = append(d.skipped, SkippedRegion{
+					File:   fileBufReader.FileInfo().Name(),
+					Offset: d.lastValidOff,
+					Size:   size,
+				})
+				d.crc = crc.New(rec.Crc, crcTable)
+				d.lastValidOff += size
+				rec.Reset()
+				return d.decodeRecord(rec)
+			}
 			if !d.continueOnCrcError {
 				rec.Reset()
 			} else {
 				// If we continue, we want to update lastValidOff, such that following errors are consistent
-				defer func() { d.lastValidOff += frameSizeBytes + recBytes + padBytes }()
+				defer func() { d.lastValidOff += headerBytes + recBytes + padBytes }()
 			}
 
-			if d.isTornEntry(data) {
+			if d.isTornEntry(data, headerBytes) {
 				return fmt.Errorf("%w: in file '%s' at position: %d", io.ErrUnexpectedEOF, fileBufReader.FileInfo().Name(), d.lastValidOff)
 			}
 			return fmt.Errorf("%w: in file '%s' at position: %d", err, fileBufReader.FileInfo().Name(), d.lastValidOff)
 		}
 	}
+	if sequenced && d.hasSeq && seq != d.lastSeq+1 {
+		rec.Reset()
+		return &SequenceError{Expected: d.lastSeq + 1, Actual: seq}
+	}
+	if rec.Type&compressedTypeFlag != 0 {
+		if err := decompressRecord(rec); err != nil {
+			rec.Reset()
+			return err
+		}
+	}
+	if rec.Type == FormatType {
+		if len(rec.Data) != 4 || binary.BigEndian.Uint32(rec.Data) > formatSequenced {
+			err := fmt.Errorf("%w %x in file '%s' at position: %d", ErrUnknownFormat, rec.Data, fileBufReader.FileInfo().Name(), d.lastValidOff)
+			rec.Reset()
+			return err
+		}
+		d.format = binary.BigEndian.Uint32(rec.Data)
+	}
+	if sequenced {
+		d.lastSeq, d.hasSeq = seq, true
+	}
 	// record decoded as valid; point last valid offset to end of record
-	d.lastValidOff += frameSizeBytes + recBytes + padBytes
+	d.lastValidOff += headerBytes + recBytes + padBytes
 	return nil
 }
 
+// lastFormat returns the format version declared in the last file read by d.
+func lastFormat(d Decoder) uint32 {
+	if d, ok := d.(*decoder); ok {
+		return d.format
+	}
+	return 0
+}
+
+// lastSequence returns the sequence number of the last sequenced record
+// decoded by d, or 0 if there is none.
+func lastSequence(d Decoder) uint64 {
+	if d, ok := d.(*decoder); ok && d.hasSeq {
+		return d.lastSeq
+	}
+	return 0
+}
+
+// remainingFiles returns how many of the files given to d it hasn't moved
+// past, the file it decodes records from included.
+func remainingFiles(d Decoder) int {
+	if d, ok := d.(*decoder); ok {
+		return len(d.brs)
+	}
+	return 0
+}
+
+func readSequence(r io.Reader) (uint64, error) {
+	var buf [frameSizeBytes]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
 func decodeFrameSize(lenField int64) (recBytes int64, padBytes int64) {
 	// the record size is stored in the lower 56 bits of the 64-bit length
 	recBytes = int64(uint64(lenField) & ^(uint64(0xff) << 56))
@@ -165,12 +351,12 @@ func decodeFrameSize(lenField int64) (recBytes int64, padBytes int64) {
 
 // isTornEntry determines whether the last entry of the WAL was partially written
 // and corrupted because of a torn write.
-func (d *decoder) isTornEntry(data []byte) bool {
+func (d *decoder) isTornEntry(data []byte, headerBytes int64) bool {
 	if len(d.brs) != 1 {
 		return false
 	}
 
-	fileOff := d.lastValidOff + frameSizeBytes
+	fileOff := d.lastValidOff + headerBytes
 	curOff := 0
 	var chunks [][]byte
 	// split data on sector boundaries
@@ -186,14 +372,7 @@ func (d *decoder) isTornEntry(data []byte) bool {
 
 	// if any data for a sector chunk is all 0, it's a torn write
 	for _, sect := range chunks {
-		isZero := true
-		for _, v := range sect {
-			if v != 0 {
-				isZero = false
-				break
-			}
-		}
-		if isZero {
+		if isZeros(sect) {
 			return true
 		}
 	}
@@ -210,6 +389,55 @@ func (d *decoder) LastCRC() uint32 {
 
 func (d *decoder) LastOffset() int64 { return d.lastValidOff }
 
+// SkippedRegions returns the records skipped so far because of crc errors.
+func (d *decoder) SkippedRegions() []SkippedRegion {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]SkippedRegion(nil), d.skipped...)
+}
+
+// Offset returns the position following the last valid decoded record,
+// counted in bytes from the start of the first file given to the decoder.
+// Files the decoder moved past count with their full size, so the offset
+// stays meaningful only as long as these files are left unchanged.
+func (d *decoder) Offset() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.consumedOff + d.lastValidOff
+}
+
+// nextFile moves the decoder to the beginning of the next file.
+func (d *decoder) nextFile() {
+	d.consumedOff += d.brs[0].FileInfo().Size()
+	d.brs = d.brs[1:]
+	d.lastValidOff = 0
+	d.format = 0
+}
+
+// seek moves a decoder that has not decoded any record yet to the given
+// offset, previously returned by Offset of a decoder reading the same files.
+// The running crc is not restored by seek; callers need to call UpdateCRC
+// with the crc captured along with the offset.
+func (d *decoder) seek(offset int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.consumedOff != 0 || d.lastValidOff != 0 {
+		return errors.New("wal: decoder can only seek before decoding")
+	}
+	for len(d.brs) > 1 && offset >= d.consumedOff+d.brs[0].FileInfo().Size() {
+		d.nextFile()
+	}
+	if len(d.brs) == 0 {
+		return io.EOF
+	}
+	n, err := d.brs[0].Discard(int(offset - d.consumedOff))
+	d.lastValidOff += int64(n)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: offset %d is beyond the end of the WAL", io.ErrUnexpectedEOF, offset)
+	}
+	return err
+}
+
 func MustUnmarshalEntry(d []byte) raftpb.Entry {
 	var e raftpb.Entry
 	pbutil.MustUnmarshal(&e, d)
@@ -222,8 +450,75 @@ func MustUnmarshalState(d []byte) raftpb.HardState {
 	return s
 }
 
-func readInt64(r io.Reader) (int64, error) {
-	var n int64
-	err := binary.Read(r, binary.LittleEndian, &n)
-	return n, err
+// readFrameHeader reads the length field of the next frame. If the decoder
+// tolerates zero padding, a truncated header made only of zeros is reported
+// as io.EOF, taking it for the preallocated, unwritten part of the file.
+func (d *decoder) readFrameHeader(r io.Reader) (int64, error) {
+	var buf [frameSizeBytes]byte
+	n, err := io.ReadFull(r, buf[:])
+	if errors.Is(err, io.ErrUnexpectedEOF) && d.tolerateZeroPadding && isZeros(buf[:n]) {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(buf[:])), nil
+}
+
+// tornScanZeroBytes bounds the run of zeros scanTornTail skips over. A torn
+// write only clobbers part of a single flush of the page writer, so a longer
+// run of zeros is preallocated space.
+const tornScanZeroBytes = 128 * 1024
+
+// scanTornTail scans the data following the last valid record of a file,
+// which is expected to be zeros. It reports whether any non-zero data was
+// found, how many records it holds, torn ones included, and how many entry
+// records could still be decoded from it.
+func scanTornTail(r io.Reader) (entries, records int, torn bool) {
+	br := bufio.NewReaderSize(r, tornScanZeroBytes)
+	var zeros int
+	for zeros < tornScanZeroBytes {
+		header, err := br.Peek(frameSizeBytes)
+		if err != nil {
+			return entries, records, torn
+		}
+		if isZeros(header) {
+			zeros += frameSizeBytes
+			br.Discard(frameSizeBytes)
+			continue
+		}
+		torn, zeros = true, 0
+		records++
+		lenField := binary.LittleEndian.Uint64(header)
+		br.Discard(frameSizeBytes)
+		if lenField&frameSequencedFlag != 0 {
+			br.Discard(frameSizeBytes)
+		}
+
+		recBytes, padBytes := decodeFrameSize(int64(lenField))
+		if recBytes <= 0 || recBytes+padBytes > tornScanZeroBytes {
+			return entries, records, torn
+		}
+		data := make([]byte, recBytes+padBytes)
+		if _, err = io.ReadFull(br, data); err != nil {
+			return entries, records, torn
+		}
+		var rec walpb.Record
+		if rec.Unmarshal(data[:recBytes]) != nil {
+			return entries, records, torn
+		}
+		if rec.Type&^compressedTypeFlag == EntryType {
+			entries++
+		}
+	}
+	return entries, records, torn
+}
+
+func isZeros(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
 }
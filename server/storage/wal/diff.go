@@ -0,0 +1,156 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/pkg/v3/pbutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+// RecordDiff is a difference between two WALs found by Diff.
+type RecordDiff struct {
+	// Type is the type of the records that differ: MetadataType, EntryType,
+	// StateType or SnapshotType.
+	Type int64
+	// Index is the raft index of the differing entries or snapshots.
+	Index uint64
+	// A and B describe the record of each WAL, they are empty if the WAL
+	// has none.
+	A, B string
+}
+
+// Diff decodes the WALs in dirA and dirB from snap, like ReadAll would, and
+// returns their differences: the metadata, the first index at which the
+// entries differ in term, type or data, the last hard state, and the
+// snapshots recorded in only one of them or with a different term or conf
+// state. Later entries are not compared, as the logs forked at the first
+// one. It is meant for investigating members whose logs diverged, the WALs
+// shouldn't be open for writing.
+func Diff(lg *zap.Logger, dirA, dirB string, snap walpb.Snapshot) ([]RecordDiff, error) {
+	a, err := readDiffContent(lg, dirA, snap)
+	if err != nil {
+		return nil, fmt.Errorf("wal: failed to read %q: %w", dirA, err)
+	}
+	b, err := readDiffContent(lg, dirB, snap)
+	if err != nil {
+		return nil, fmt.Errorf("wal: failed to read %q: %w", dirB, err)
+	}
+
+	var diffs []RecordDiff
+	if !bytes.Equal(a.metadata, b.metadata) {
+		diffs = append(diffs, RecordDiff{Type: MetadataType, A: fmt.Sprintf("%q", a.metadata), B: fmt.Sprintf("%q", b.metadata)})
+	}
+	for i := 0; i < max(len(a.ents), len(b.ents)); i++ {
+		var ea, eb *raftpb.Entry
+		if i < len(a.ents) {
+			ea = &a.ents[i]
+		}
+		if i < len(b.ents) {
+			eb = &b.ents[i]
+		}
+		if ea != nil && eb != nil && ea.Term == eb.Term && ea.Type == eb.Type && bytes.Equal(ea.Data, eb.Data) {
+			continue
+		}
+		diffs = append(diffs, RecordDiff{Type: EntryType, Index: snap.Index + uint64(i) + 1, A: describeEntry(ea), B: describeEntry(eb)})
+		break
+	}
+	if a.state != b.state {
+		diffs = append(diffs, RecordDiff{Type: StateType, A: a.state.String(), B: b.state.String()})
+	}
+
+	indexes := map[uint64]bool{}
+	for index := range a.snaps {
+		indexes[index] = true
+	}
+	for index := range b.snaps {
+		indexes[index] = true
+	}
+	var sorted []uint64
+	for index := range indexes {
+		sorted = append(sorted, index)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, index := range sorted {
+		sa, okA := a.snaps[index]
+		sb, okB := b.snaps[index]
+		if okA && okB && bytes.Equal(pbutil.MustMarshal(&sa), pbutil.MustMarshal(&sb)) {
+			continue
+		}
+		diff := RecordDiff{Type: SnapshotType, Index: index}
+		if okA {
+			diff.A = sa.String()
+		}
+		if okB {
+			diff.B = sb.String()
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+type diffContent struct {
+	metadata []byte
+	state    raftpb.HardState
+	ents     []raftpb.Entry
+	snaps    map[uint64]walpb.Snapshot
+}
+
+// readDiffContent decodes the records of the WAL in dir like ReadAll, with
+// later entries overwriting the ones at the same index, but keeping all the
+// snapshot records.
+func readDiffContent(lg *zap.Logger, dir string, snap walpb.Snapshot) (diffContent, error) {
+	c := diffContent{snaps: map[uint64]walpb.Snapshot{}}
+	recs, err := ReadAllRecords(lg, dir, snap)
+	if err != nil {
+		return c, err
+	}
+	for _, rec := range recs {
+		switch rec.Type {
+		case MetadataType:
+			c.metadata = rec.Data
+		case StateType:
+			c.state = MustUnmarshalState(rec.Data)
+		case SnapshotType:
+			var s walpb.Snapshot
+			pbutil.MustUnmarshal(&s, rec.Data)
+			c.snaps[s.Index] = s
+		case EntryType:
+			e := MustUnmarshalEntry(rec.Data)
+			if e.Index <= snap.Index {
+				continue
+			}
+			offset := e.Index - snap.Index - 1
+			if offset > uint64(len(c.ents)) {
+				return c, ErrSliceOutOfRange
+			}
+			c.ents = append(c.ents[:offset], e)
+		}
+	}
+	return c, nil
+}
+
+func describeEntry(e *raftpb.Entry) string {
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("term=%d type=%s size=%d", e.Term, e.Type, len(e.Data))
+}
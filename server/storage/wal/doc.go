@@ -41,6 +41,11 @@ protobuf. The record protobuf contains a CRC, a type, and a data payload. The le
 record is 8-byte aligned so that the length field is never torn. The CRC contains the CRC32
 value of all record protobufs preceding the current record.
 
+The 56-bit length already covers records of any practical size, so there is no
+wider frame variant. Record size is bounded by the record protobuf instead, which
+can't be larger than 2GB, and by the record being held in memory when written
+and read.
+
 WAL files are placed inside the directory in the following format:
 $seq-$index.wal
 
@@ -16,6 +16,8 @@ package wal
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"os"
@@ -32,6 +34,10 @@ import (
 // distinguish between torn writes and ordinary data corruption.
 const walPageBytes = 8 * minSectorSize
 
+// ErrShortWrite is returned when the file the WAL appends to takes only part
+// of the data written to it, even after retrying to write the rest.
+var ErrShortWrite = errors.New("wal: short write")
+
 type encoder struct {
 	mu sync.Mutex
 	bw *ioutil.PageWriter
@@ -39,15 +45,34 @@ type encoder struct {
 	crc       hash.Hash32
 	buf       []byte
 	uint64buf []byte
+
+	// sequenced makes the encoder write the sequence number of each record
+	// into its frame; seq is the sequence number of the last written record.
+	sequenced bool
+	seq       uint64
+
+	// timed makes the encoder observe the time spent computing the crc of and
+	// marshaling each record in walEncodeSec.
+	timed bool
+
+	// compressed makes the encoder compress entry records, see
+	// WithCompression.
+	compressed bool
+
+	// offset is the file offset the encoder started writing at, and encoded
+	// the number of bytes of the frames encoded since, padding included.
+	offset  int64
+	encoded int64
 }
 
 func newEncoder(w io.Writer, prevCrc uint32, pageOffset int) *encoder {
 	return &encoder{
-		bw:  ioutil.NewPageWriter(w, walPageBytes, pageOffset),
+		bw:  ioutil.NewPageWriter(fullWriter{w}, walPageBytes, pageOffset),
 		crc: crc.New(prevCrc, crcTable),
 		// 1MB buffer
 		buf:       make([]byte, 1024*1024),
-		uint64buf: make([]byte, 8),
+		uint64buf: make([]byte, 2*frameSizeBytes),
+		offset:    int64(pageOffset),
 	}
 }
 
@@ -61,9 +86,27 @@ func newFileEncoder(f *os.File, prevCrc uint32) (*encoder, error) {
 }
 
 func (e *encoder) encode(rec *walpb.Record) error {
+	return e.encodeSince(rec, e.now())
+}
+
+// now returns the time encoding a record starts at, if the encoder is timed.
+func (e *encoder) now() time.Time {
+	if e.timed {
+		return time.Now()
+	}
+	return time.Time{}
+}
+
+// encodeSince encodes the record like encode, observing the time spent since
+// start, when the caller began marshaling the record data.
+func (e *encoder) encodeSince(rec *walpb.Record, start time.Time) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if e.compressed {
+		// the crc covers the data as written
+		compressRecord(rec)
+	}
 	e.crc.Write(rec.Data)
 	rec.Crc = e.crc.Sum32()
 	var (
@@ -86,8 +129,58 @@ func (e *encoder) encode(rec *walpb.Record) error {
 	}
 
 	data, lenField := prepareDataWithPadding(data)
+	if e.timed {
+		walEncodeSec.Observe(time.Since(start).Seconds())
+	}
+
+	e.encoded += frameSizeBytes + int64(len(data))
+	if e.sequenced {
+		e.seq++
+		e.encoded += frameSizeBytes
+		return writeSequenced(e.bw, e.uint64buf, data, lenField|frameSequencedFlag, e.seq)
+	}
+	return write(e.bw, e.uint64buf[:frameSizeBytes], data, lenField)
+}
+
+// encodeRaw writes frames, records framed the way encode does, as they are.
+// recs are the records of the frames, whose crcs have to chain from the one
+// of the last encoded record, otherwise nothing is written and
+// ErrCRCMismatch is returned.
+func (e *encoder) encodeRaw(frames [][]byte, recs []walpb.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c := crc.New(e.crc.Sum32(), crcTable)
+	for i := range recs {
+		c.Write(recs[i].Data)
+		if recs[i].Crc != c.Sum32() {
+			return fmt.Errorf("%w: raw record %d", ErrCRCMismatch, i)
+		}
+	}
+	// the crc and size advance with each frame written, so they still match
+	// the frames written if one fails
+	for i, frame := range frames {
+		if err := writeFrame(e.bw, frame[:frameSizeBytes], frame[frameSizeBytes:]); err != nil {
+			return err
+		}
+		e.crc.Write(recs[i].Data)
+		e.encoded += int64(len(frame))
+	}
+	return nil
+}
 
-	return write(e.bw, e.uint64buf, data, lenField)
+// EncodeRecords writes recs to w laid out the way they are in a WAL file:
+// each record is length framed and padded to 8 bytes, and its crc is chained
+// from the one of the previous record, starting at prevCrc. The Crc field of
+// each record is set to the computed crc.
+func EncodeRecords(w io.Writer, prevCrc uint32, recs ...*walpb.Record) error {
+	e := newEncoder(w, prevCrc, 0)
+	for _, rec := range recs {
+		if err := e.encode(rec); err != nil {
+			return err
+		}
+	}
+	return e.flush()
 }
 
 func encodeFrameSize(dataBytes int) (lenField uint64, padBytes int) {
@@ -117,9 +210,44 @@ func prepareDataWithPadding(data []byte) ([]byte, uint64) {
 func write(w io.Writer, uint64buf, data []byte, lenField uint64) error {
 	// write padding info
 	binary.LittleEndian.PutUint64(uint64buf, lenField)
+	return writeFrame(w, uint64buf, data)
+}
+
+// writeSequenced writes a frame whose header is followed by the sequence
+// number of the record.
+func writeSequenced(w io.Writer, uint64buf, data []byte, lenField uint64, seq uint64) error {
+	binary.LittleEndian.PutUint64(uint64buf, lenField)
+	binary.LittleEndian.PutUint64(uint64buf[frameSizeBytes:], seq)
+	return writeFrame(w, uint64buf, data)
+}
+
+// fullWriter retries writes the underlying writer completed only partially
+// without an error, which the page writer doesn't, so a frame is never
+// silently cut short.
+type fullWriter struct {
+	w io.Writer
+}
+
+func (fw fullWriter) Write(p []byte) (int, error) {
+	var written int
+	for written < len(p) {
+		n, err := fw.w.Write(p[written:])
+		written += n
+		switch {
+		case err != nil && written > 0 && written < len(p):
+			return written, fmt.Errorf("%w, wrote %d of %d bytes: %w", ErrShortWrite, written, len(p), err)
+		case err != nil:
+			return written, err
+		case n == 0:
+			return written, fmt.Errorf("%w, wrote %d of %d bytes", ErrShortWrite, written, len(p))
+		}
+	}
+	return written, nil
+}
 
+func writeFrame(w io.Writer, header, data []byte) error {
 	start := time.Now()
-	nv, err := w.Write(uint64buf)
+	nv, err := w.Write(header)
 	walWriteBytes.Add(float64(nv))
 	if err != nil {
 		return err
@@ -0,0 +1,199 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+)
+
+// ErrSegmentFooterMismatch is returned by VerifySegmentFooters when the
+// records of a finalized segment don't match the crc recorded in its footer.
+var ErrSegmentFooterMismatch = errors.New("wal: segment data does not match its footer")
+
+// footerFrameSizes are the sizes of a footer frame, without and with a sequence
+// number. The footer record always fits in 16 bytes once padded.
+var footerFrameSizes = []int64{frameSizeBytes + 16, 2*frameSizeBytes + 16}
+
+// saveFooter appends the footer of the tail segment, holding the running crc
+// of the records preceding it, see WithSegmentFooters. It is the crc the
+// encoder chains records with, so nothing is read back.
+func (w *WAL) saveFooter() error {
+	data := binary.BigEndian.AppendUint32(nil, w.encoder.crc.Sum32())
+	if err := w.encoder.encode(&walpb.Record{Type: FooterType, Data: data}); err != nil {
+		return err
+	}
+	return w.encoder.flush()
+}
+
+// readSegmentFooter returns the crc recorded in the footer ending the given
+// segment, and the crc of the footer record itself, which the CrcType record
+// heading the next segment holds. ok is false if the segment doesn't end with
+// a footer.
+func readSegmentFooter(f *os.File, size int64) (sum uint32, footerCrc uint32, ok bool, err error) {
+	for _, frameSize := range footerFrameSizes {
+		if size < frameSize {
+			continue
+		}
+		frame := make([]byte, frameSize)
+		if _, err = f.ReadAt(frame, size-frameSize); err != nil {
+			return 0, 0, false, err
+		}
+		lenField := int64(binary.LittleEndian.Uint64(frame))
+		headerBytes := int64(frameSizeBytes)
+		if uint64(lenField)&frameSequencedFlag != 0 {
+			headerBytes += frameSizeBytes
+		}
+		recBytes, padBytes := decodeFrameSize(lenField)
+		if headerBytes+recBytes+padBytes != frameSize {
+			continue
+		}
+		var rec walpb.Record
+		if rec.Unmarshal(frame[headerBytes:headerBytes+recBytes]) != nil || rec.Type != FooterType || len(rec.Data) != 4 {
+			continue
+		}
+		return binary.BigEndian.Uint32(rec.Data), rec.Crc, true, nil
+	}
+	return 0, 0, false, nil
+}
+
+// readSegmentHead returns the crc held by the CrcType record heading the
+// given segment, the running crc of the records of the segments before it.
+func readSegmentHead(f *os.File) (uint32, error) {
+	rec := &walpb.Record{}
+	if err := NewDecoder(fileutil.NewFileReader(f)).Decode(rec); err != nil {
+		return 0, err
+	}
+	if rec.Type != CrcType {
+		return 0, fmt.Errorf("unexpected block type %d heading %q", rec.Type, filepath.Base(f.Name()))
+	}
+	return rec.Crc, nil
+}
+
+// skipFooteredSegments returns the index of the first of the segments names,
+// from start on, that Verify has to decode, and the running crc to decode it
+// with. Finalized segments ending with a footer are skipped without decoding
+// their records: each one is only checked to start with a CrcType record
+// chaining from the footer of the previous one, which reads a few bytes
+// whatever the size of the segment. The last segment, the tail, is never
+// skipped. It returns start if the segment at start doesn't end with a footer.
+func skipFooteredSegments(walDir string, names []string, start int) (next int, prevCrc uint32, err error) {
+	for next = start; next < len(names)-1; next++ {
+		footerCrc, ok, err := skipFooteredSegment(filepath.Join(walDir, names[next]), next > start, prevCrc)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok {
+			break
+		}
+		prevCrc = footerCrc
+	}
+	return next, prevCrc, nil
+}
+
+// skipFooteredSegment returns the crc of the footer ending the given segment,
+// if it ends with one, first checking that it starts chaining from prevCrc if
+// checkHead is set.
+func skipFooteredSegment(path string, checkHead bool, prevCrc uint32) (footerCrc uint32, ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	if checkHead {
+		headCrc, err := readSegmentHead(f)
+		if err != nil {
+			return 0, false, err
+		}
+		if headCrc != prevCrc {
+			return 0, false, fmt.Errorf("%w: %q doesn't chain from the footer of the previous segment", ErrCRCMismatch, filepath.Base(path))
+		}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	_, footerCrc, ok, err = readSegmentFooter(f, fi.Size())
+	return footerCrc, ok, err
+}
+
+// VerifySegmentFooters checks the records of each finalized segment of the WAL
+// in walDir against the crc recorded in its footer by WithSegmentFooters,
+// recomputing the crc of every record of the segment. Verify trusts footers to
+// skip finalized segments, so it can be run periodically to detect tampering
+// with them. Segments without a footer, including the tail, are skipped. It
+// returns the names of the segments it verified.
+func VerifySegmentFooters(lg *zap.Logger, walDir string) ([]string, error) {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	names, err := readWALNames(lg, walDir)
+	if err != nil {
+		return nil, err
+	}
+	var verified []string
+	for _, name := range names {
+		ok, err := verifySegmentFooter(filepath.Join(walDir, name))
+		if err != nil {
+			return verified, err
+		}
+		if ok {
+			verified = append(verified, name)
+		}
+	}
+	return verified, nil
+}
+
+func verifySegmentFooter(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	sum, _, ok, err := readSegmentFooter(f, fi.Size())
+	if err != nil || !ok {
+		return false, err
+	}
+	decoder := NewDecoder(fileutil.NewFileReader(f))
+	rec := &walpb.Record{}
+	for {
+		prevCrc := decoder.LastCRC()
+		if err = decoder.Decode(rec); err != nil {
+			return false, fmt.Errorf("%w: %q: %w", ErrSegmentFooterMismatch, filepath.Base(path), err)
+		}
+		switch rec.Type {
+		case CrcType:
+			decoder.UpdateCRC(rec.Crc)
+		case FooterType:
+			// the footer found at the end of the segment is the first one
+			if prevCrc != sum || decoder.LastOffset() != fi.Size() {
+				return false, fmt.Errorf("%w: %q", ErrSegmentFooterMismatch, filepath.Base(path))
+			}
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+)
+
+// ErrManifestMismatch is returned by VerifyManifest when the WAL directory
+// doesn't match the manifest.
+var ErrManifestMismatch = errors.New("wal: directory doesn't match manifest")
+
+// ManifestSegment describes a segment of a WAL in a manifest.
+type ManifestSegment struct {
+	Name string
+	Size int64
+	// FirstIndex is the first raft index expected in the segment, taken from
+	// its name.
+	FirstIndex uint64
+	// LastIndex is the highest raft index of the entries in the segment, or 0
+	// if it holds none.
+	LastIndex uint64
+	// CRC is the crc of the bytes of the segment file, computed with the same
+	// table as the record crcs.
+	CRC uint32
+}
+
+// WriteManifest writes a manifest of the segments of the WAL in dir to path,
+// e.g. to ship it along with a backup of the WAL, so that a restored copy can
+// be checked with VerifyManifest. The manifest is JSON, and is written to a
+// temporary file first so that path never holds a partial manifest. The WAL
+// should not be open for writing, as its tail would change afterwards.
+func WriteManifest(lg *zap.Logger, dir, path string) error {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	segments, err := manifestSegments(lg, dir)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(segments)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, fileutil.PrivateFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// VerifyManifest checks that the segments of the WAL in dir are exactly the
+// ones recorded in the manifest at path by WriteManifest, with the same size,
+// index range and crc. It returns an error wrapping ErrManifestMismatch for
+// the first difference found.
+func VerifyManifest(lg *zap.Logger, dir, path string) error {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var expected []ManifestSegment
+	if err = json.Unmarshal(data, &expected); err != nil {
+		return fmt.Errorf("wal: failed to decode manifest %q: %w", path, err)
+	}
+	segments, err := manifestSegments(lg, dir)
+	if err != nil {
+		return err
+	}
+	for i, s := range segments {
+		if i >= len(expected) {
+			return fmt.Errorf("%w: segment %q not in manifest", ErrManifestMismatch, s.Name)
+		}
+		if s != expected[i] {
+			return fmt.Errorf("%w: segment %+v, manifest has %+v", ErrManifestMismatch, s, expected[i])
+		}
+	}
+	if len(expected) > len(segments) {
+		return fmt.Errorf("%w: segment %q missing", ErrManifestMismatch, expected[len(segments)].Name)
+	}
+	return nil
+}
+
+func manifestSegments(lg *zap.Logger, dir string) ([]ManifestSegment, error) {
+	names, err := readWALNames(lg, dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]ManifestSegment, 0, len(names))
+	for i, name := range names {
+		_, index, err := parseWALName(name)
+		if err != nil {
+			return nil, err
+		}
+		size, crc, err := segmentCRC(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		last, err := segmentLastIndex(lg, dir, names[i:i+1])
+		if err != nil {
+			return nil, fmt.Errorf("wal: failed to read %q: %w", name, err)
+		}
+		segments = append(segments, ManifestSegment{Name: name, Size: size, FirstIndex: index, LastIndex: last, CRC: crc})
+	}
+	return segments, nil
+}
+
+// segmentCRC returns the size of the file at path and the crc of its bytes.
+func segmentCRC(path string) (int64, uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	h := crc32.New(crcTable)
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return size, h.Sum32(), nil
+}
+
+// segmentLastIndex returns the highest index of the entries in the segment.
+// A torn write at the end of the segment is tolerated, like when reading a
+// WAL not opened for writing.
+func segmentLastIndex(lg *zap.Logger, dir string, names []string) (uint64, error) {
+	rs, _, closer, err := openWALFiles(lg, dir, names, 0, false, false)
+	if err != nil {
+		return 0, err
+	}
+	defer closer()
+	var last uint64
+	decoder := NewDecoder(rs...)
+	rec := &walpb.Record{}
+	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
+		switch rec.Type {
+		case EntryType:
+			last = max(last, MustUnmarshalEntry(rec.Data).Index)
+		case CrcType:
+			decoder.UpdateCRC(rec.Crc)
+		}
+	}
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, err
+	}
+	return last, nil
+}
@@ -14,7 +14,11 @@
 
 package wal
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 var (
 	walFsyncSec = prometheus.NewHistogram(prometheus.HistogramOpts{
@@ -37,16 +41,88 @@ var (
 		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
 	})
 
+	walEncodeSec = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "wal_encode_duration_seconds",
+		Help:      "The latency distributions of computing the crc of and marshaling records written by WAL.",
+
+		// lowest bucket start of upper bound 0.00001 sec (10 us) with factor 2
+		// highest bucket start of 0.00001 sec * 2^13 == 81.92 ms
+		Buckets: prometheus.ExponentialBuckets(0.00001, 2, 14),
+	})
+
 	walWriteBytes = prometheus.NewGauge(prometheus.GaugeOpts{
 		Namespace: "etcd",
 		Subsystem: "disk",
 		Name:      "wal_write_bytes_total",
 		Help:      "Total number of bytes written in WAL.",
 	})
+
+	walSaveBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "wal_save_bytes",
+		Help:      "The size distributions of the records written by each save of WAL.",
+
+		// lowest bucket start of upper bound 256 bytes with factor 4
+		// highest bucket start of 256 bytes * 4^9 == 64 MiB
+		Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+	})
+
+	walCuts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd",
+		Subsystem: "disk",
+		Name:      "wal_cuts_total",
+		Help:      "Total number of WAL segments cut.",
+	})
+
+	collectors = []prometheus.Collector{
+		walFsyncSec,
+		walWriteSec,
+		walEncodeSec,
+		walWriteBytes,
+		walSaveBytes,
+		walCuts,
+	}
 )
 
-func init() {
-	prometheus.MustRegister(walFsyncSec)
-	prometheus.MustRegister(walWriteSec)
-	prometheus.MustRegister(walWriteBytes)
+var registerOnce sync.Once
+
+// registerDefaultMetrics registers the metrics of the WAL with the default
+// prometheus registerer, once a WAL is first created or opened, unless
+// RegisterMetrics was called before.
+func registerDefaultMetrics() {
+	registerOnce.Do(func() {
+		for _, c := range collectors {
+			prometheus.MustRegister(c)
+		}
+	})
+}
+
+// RegisterMetrics registers the metrics of the WAL with reg instead of the
+// default prometheus registerer, e.g. for a program embedding etcd that
+// registers collectors of the same names with the default registerer. Called
+// before a WAL is first created or opened, the metrics are never registered
+// with the default registerer, otherwise they are moved from it. With a nil
+// reg, the metrics are only unregistered. If registering one of them fails,
+// the ones already registered with reg are unregistered, so none is. They are
+// updated either way.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	registerOnce.Do(func() {})
+	for _, c := range collectors {
+		prometheus.Unregister(c)
+	}
+	if reg == nil {
+		return nil
+	}
+	for i, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			for _, registered := range collectors[:i] {
+				reg.Unregister(registered)
+			}
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,73 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+)
+
+var errMmapUnsupported = errors.New("wal: mmap is not supported on this platform")
+
+// bytesFileReader reads the content of a file held in memory, like a
+// read-only memory mapping or the decompressed content of the file.
+type bytesFileReader struct {
+	*bytes.Reader
+	fi fs.FileInfo
+}
+
+func (r *bytesFileReader) FileInfo() (fs.FileInfo, error) {
+	return r.fi, nil
+}
+
+// mmapFileReaders replaces the readers of rs with readers of read-only memory
+// mappings of the same files, keeping the readers that cannot be mapped. The
+// returned function unmaps the files; the replaced readers must not be used
+// after it is called. The files stay open and are closed by the caller.
+func mmapFileReaders(lg *zap.Logger, rs []fileutil.FileReader) ([]fileutil.FileReader, func()) {
+	var mapped [][]byte
+	mrs := make([]fileutil.FileReader, len(rs))
+	for i, r := range rs {
+		mrs[i] = r
+		fi, err := r.FileInfo()
+		if err != nil || fi.Size() == 0 {
+			continue
+		}
+		f, ok := r.(interface{ Fd() uintptr })
+		if !ok {
+			continue
+		}
+		data, err := mmapFile(f.Fd(), int(fi.Size()))
+		if err != nil {
+			lg.Info("failed to mmap WAL file, falling back to buffered reads", zap.String("name", fi.Name()), zap.Error(err))
+			continue
+		}
+		mapped = append(mapped, data)
+		mrs[i] = &bytesFileReader{Reader: bytes.NewReader(data), fi: fi}
+	}
+	unmap := func() {
+		for _, data := range mapped {
+			if err := munmapFile(data); err != nil {
+				lg.Warn("failed to munmap WAL file", zap.Error(err))
+			}
+		}
+	}
+	return mrs, unmap
+}
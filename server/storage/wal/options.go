@@ -0,0 +1,283 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// options holds the optional settings of a WAL.
+type options struct {
+	maxTotalSize   int64
+	purge          func(freed []string)
+	sequencing     bool
+	noAutoRepair   bool
+	crcSeed        uint32
+	watermark      string
+	mmapRead       bool
+	onCut          func(finalizedName string)
+	segmentFooters bool
+	logFields      []zap.Field
+	encodeTiming   bool
+	sharedLocks    bool
+	syncer         Syncer
+	minSize        int64
+	readRepair     bool
+	readAhead      int
+	segmentSize    int64
+	compression    bool
+}
+
+// Option configures a WAL when it is created or opened.
+type Option func(*options)
+
+func newOptions(opts ...Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.segmentSize <= 0 {
+		o.segmentSize = SegmentSizeBytes
+	}
+	return o
+}
+
+// logger returns the logger the WAL logs to, lg with the fields given by
+// WithLogFields, or a no-op logger if lg is nil.
+func (o options) logger(lg *zap.Logger) *zap.Logger {
+	if lg == nil {
+		return zap.NewNop()
+	}
+	if len(o.logFields) == 0 {
+		return lg
+	}
+	return lg.With(o.logFields...)
+}
+
+// WithMaxTotalSize sets a cap on the total size of the WAL files in the WAL
+// directory. After each cut, if the files exceed the cap, purge is called with
+// the paths of the oldest files the WAL no longer holds a lock on (see
+// ReleaseLockTo), just enough of them to bring the total size back under the
+// cap if possible. The WAL never removes files itself; purge is expected to.
+// purge is called while the WAL is being written to, so it must not call back
+// into the WAL.
+func WithMaxTotalSize(bytes int64, purge func(freed []string)) Option {
+	return func(o *options) {
+		o.maxTotalSize = bytes
+		o.purge = purge
+	}
+}
+
+// WithRecordSequencing makes the WAL write a sequence number, incremented by
+// one for each record across segments, into the frame of every record it
+// writes. The decoder checks the sequence numbers of consecutive sequenced
+// records and fails with a *SequenceError on a gap or a repeat, which catches
+// records spliced in from elsewhere that still carry a valid crc. Sequenced
+// frames are flagged in their header, so they can be read regardless of this
+// option. Each segment declares the format in a FormatType record before its
+// first sequenced frame, which etcd versions unaware of it fail on as an
+// unexpected record type, rather than taking the sequenced frames for a torn
+// write and repairing them away.
+func WithRecordSequencing() Option {
+	return func(o *options) {
+		o.sequencing = true
+	}
+}
+
+// WithoutAutoRepair stops ReadAll from zeroing out the data of a torn write
+// found after the last valid record of the WAL, which it does by default so
+// that new records can be appended. Instead ReadAll fails with
+// io.ErrUnexpectedEOF and leaves the file untouched, so it can be inspected.
+func WithoutAutoRepair() Option {
+	return func(o *options) {
+		o.noAutoRepair = true
+	}
+}
+
+// WithInitialCRC makes Create start the crc chain of the WAL from seed instead
+// of 0, so that a WAL continuing a prior log, e.g. after compaction, chains
+// onto the final crc of that log. It has no effect on Open, which continues
+// the chain found in the WAL files.
+func WithInitialCRC(seed uint32) Option {
+	return func(o *options) {
+		o.crcSeed = seed
+	}
+}
+
+// WithVerifyWatermark makes Verify resume from the position recorded in the
+// watermark file at path by a previous Verify of the same WAL and snapshot,
+// and record the position it reached there on success. The watermark is
+// ignored, and the WAL verified from the start, if any file preceding the
+// recorded one was modified since. It has no effect on Create and Open.
+func WithVerifyWatermark(path string) Option {
+	return func(o *options) {
+		o.watermark = path
+	}
+}
+
+// WithMmapRead makes ReadAll decode the WAL files from read-only memory
+// mappings of them instead of reading them through buffered file reads, which
+// saves syscalls when replaying large WALs. Files that cannot be mapped, e.g.
+// on platforms without mmap support, are still read through buffered reads.
+func WithMmapRead() Option {
+	return func(o *options) {
+		o.mmapRead = true
+	}
+}
+
+// WithOnCut makes the WAL call fn with the name of the segment finalized by
+// each cut, once the segment and the new tail are synced and renamed into
+// place, e.g. to ship finalized segments off-host without polling the WAL
+// directory. Segments are only durable at that point if syncing is enabled.
+// fn is called while the WAL is being written to, so it must not call back
+// into the WAL.
+func WithOnCut(fn func(finalizedName string)) Option {
+	return func(o *options) {
+		o.onCut = fn
+	}
+}
+
+// WithSegmentFooters makes each cut end the finalized segment with a footer
+// record holding the running crc of the records preceding it, which the WAL
+// keeps track of anyway. Once it found the snapshot, Verify skips finalized
+// segments ending with a footer, only checking that each one chains from the
+// footer of the previous one, rather than decoding their records.
+// VerifySegmentFooters recomputes the crc of the records of finalized
+// segments, to be run periodically to detect tampering with them. Footers can
+// only be read by etcd versions aware of them.
+func WithSegmentFooters() Option {
+	return func(o *options) {
+		o.segmentFooters = true
+	}
+}
+
+// WithLogFields adds fields, e.g. the cluster and member IDs, to every line the
+// WAL logs, including those logged while it is being created or opened.
+func WithLogFields(fields ...zap.Field) Option {
+	return func(o *options) {
+		o.logFields = append(o.logFields, fields...)
+	}
+}
+
+// WithEncodeTiming makes the WAL observe the time spent computing the crc of
+// and marshaling each record it writes, from marshaling the raft entry or hard
+// state it holds, in the etcd_disk_wal_encode_duration_seconds histogram, next
+// to the fsync and write latencies, to tell serialization cost apart from disk
+// cost. It is off by default to keep the clock reads off the write path.
+func WithEncodeTiming() Option {
+	return func(o *options) {
+		o.encodeTiming = true
+	}
+}
+
+// WithSharedLocks makes OpenForRead take a shared lock on each WAL file it
+// reads, so that a process tailing the WAL of a running etcd, e.g. a sidecar,
+// keeps the files it reads from being purged, while etcd keeps appending to
+// the WAL. The files the writer still holds locked exclusively, the tail ones
+// it hasn't released with ReleaseLockTo, are read without a lock, as are all
+// files on platforms without shared locks. Those reads may observe the last
+// record partially written, ReadAll stops at it. The locks are released once
+// ReadAll returns or the WAL is closed. While they are held, the writer can't
+// lock those files either: an Open of the WAL, e.g. by etcd restarting, that
+// reads them fails with fileutil.ErrLocked. It has no effect on Create and
+// Open.
+func WithSharedLocks() Option {
+	return func(o *options) {
+		o.sharedLocks = true
+	}
+}
+
+// WithMinExpectedSize makes Open and OpenForRead fail with ErrWALTooSmall if
+// the WAL files in the directory are smaller than size bytes in total, e.g. to
+// catch files lost by a truncated copy of a backup before decoding anything.
+// As the tail file is preallocated to the segment size, see WithSegmentSize, a
+// WAL is rarely smaller than that. It has no effect on Create.
+func WithMinExpectedSize(size int64) Option {
+	return func(o *options) {
+		o.minSize = size
+	}
+}
+
+// Syncer makes the data written to a WAL file durable.
+type Syncer interface {
+	Sync(f *os.File) error
+}
+
+// SyncerFunc adapts a function to a Syncer.
+type SyncerFunc func(f *os.File) error
+
+func (fn SyncerFunc) Sync(f *os.File) error { return fn(f) }
+
+// WithSyncer makes the WAL sync its tail file with s instead of fdatasync,
+// e.g. to count or batch syncs in tests, or to plug in another durability
+// strategy. The WAL still fsyncs its directory itself when creating and
+// cutting files. The fsync duration metric and slow sync warnings cover the
+// calls to s. It is ignored if SetUnsafeNoFsync was called.
+func WithSyncer(s Syncer) Option {
+	return func(o *options) {
+		o.syncer = s
+	}
+}
+
+// WithReadRepair makes Verify, and the scrub started by StartBackgroundScrub,
+// salvage the finalized segments they find corrupted with SalvageSegment,
+// keeping the originals with a .corrupt suffix. Verify still returns the
+// error it found, and the scrub still reports the corruption, as the records
+// past it are lost. Segments locked by a WAL open for writing, including the
+// one being scrubbed, are not salvaged: SalvageSegment fails with
+// fileutil.ErrLocked, which is logged, until the writer releases them with
+// ReleaseLockTo.
+func WithReadRepair() Option {
+	return func(o *options) {
+		o.readRepair = true
+	}
+}
+
+// WithReadAhead makes ReadAll read each WAL file through a buffer of bytes
+// bytes instead of the default 4KiB one, so the records are decoded out of
+// fewer, larger reads, which speeds up recovery on disks with costly reads,
+// like spinning ones. Files read through memory mappings, see WithMmapRead,
+// take no read syscalls either way. It has no effect on Create.
+func WithReadAhead(bytes int) Option {
+	return func(o *options) {
+		o.readAhead = bytes
+	}
+}
+
+// WithSegmentSize makes the WAL preallocate its segment files to size bytes,
+// and cut the tail once it reaches that size, instead of SegmentSizeBytes.
+// SegmentSizeBytes is read when the WAL is created or opened, so WALs with
+// different segment sizes can be used at the same time without changing it.
+// Segments written with another size are still read as they are.
+func WithSegmentSize(size int64) Option {
+	return func(o *options) {
+		o.segmentSize = size
+	}
+}
+
+// WithCompression makes the WAL compress the data of the entry records it
+// writes with zstd, to save disk space and write bandwidth on workloads with
+// large, compressible entries. Records that compression doesn't make smaller
+// are written as they are. The crc of a compressed record covers its data as
+// written, compressed. Compressed records are flagged in their type, so they
+// can be read regardless of this option, but not by etcd versions unaware of
+// the flag.
+func WithCompression() Option {
+	return func(o *options) {
+		o.compression = true
+	}
+}
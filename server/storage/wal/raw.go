@@ -0,0 +1,129 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/pkg/v3/pbutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+)
+
+// ErrMalformedRawRecord is returned by AppendRaw for a record that is not a
+// single length framed and padded record of a type it can append.
+var ErrMalformedRawRecord = errors.New("wal: malformed raw record")
+
+// AppendRaw appends records already framed the way they are in a WAL file,
+// e.g. by EncodeRecords, without decoding and encoding them again, then syncs
+// them like Save does, cutting the tail if it is full. It is meant for the
+// replication of WAL files byte for byte, e.g. to a learner.
+//
+// Each record has to be a single entry, state or snapshot record, and its crc
+// has to chain from the one of the previous record, starting at LastCRC. So
+// the records can only come from a WAL identical to this one up to them. If
+// any record is malformed or doesn't validate, nothing is appended. Records
+// can't be appended raw to a WAL writing sequenced frames, see
+// WithRecordSequencing, nor can records compressed by WithCompression.
+func (w *WAL) AppendRaw(records [][]byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.writeErr(); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	if w.encoder.sequenced {
+		return fmt.Errorf("%w: the WAL writes sequenced frames", ErrMalformedRawRecord)
+	}
+
+	recs := make([]walpb.Record, len(records))
+	for i, frame := range records {
+		if err := decodeRawRecord(frame, &recs[i]); err != nil {
+			return fmt.Errorf("%w: raw record %d: %w", ErrMalformedRawRecord, i, err)
+		}
+	}
+	if err := w.encoder.encodeRaw(records, recs); err != nil {
+		if errors.Is(err, ErrCRCMismatch) {
+			return err
+		}
+		return w.fail(err)
+	}
+	for _, rec := range recs {
+		switch rec.Type {
+		case EntryType:
+			w.enti = MustUnmarshalEntry(rec.Data).Index
+		case StateType:
+			w.state = MustUnmarshalState(rec.Data)
+		case SnapshotType:
+			var snap walpb.Snapshot
+			pbutil.MustUnmarshal(&snap, rec.Data)
+			// like SaveSnapshot, only when the snapshot is ahead of last index
+			if w.enti < snap.Index {
+				w.enti = snap.Index
+			}
+		}
+	}
+
+	remaining, err := w.bytesUntilCut()
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		if err := w.sync(); err != nil {
+			return w.fail(err)
+		}
+		return nil
+	}
+	return w.cut()
+}
+
+// LastCRC returns the crc of the last record written to the WAL, which the
+// crc of the next record chains from, or 0 if the WAL isn't open for writing,
+// like when opened with OpenForRead.
+func (w *WAL) LastCRC() uint32 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.encoder == nil {
+		return 0
+	}
+	return w.encoder.crc.Sum32()
+}
+
+// decodeRawRecord checks the framing of a raw record and unmarshals it.
+func decodeRawRecord(frame []byte, rec *walpb.Record) error {
+	if len(frame) < frameSizeBytes {
+		return errors.New("frame header too short")
+	}
+	lenField := binary.LittleEndian.Uint64(frame)
+	if lenField&frameSequencedFlag != 0 {
+		return errors.New("sequenced frame")
+	}
+	recBytes, padBytes := decodeFrameSize(int64(lenField))
+	if int64(len(frame)) != frameSizeBytes+recBytes+padBytes || padBytes != (8-recBytes%8)%8 {
+		return fmt.Errorf("frame of %d bytes doesn't match its length field", len(frame))
+	}
+	if err := rec.Unmarshal(frame[frameSizeBytes : frameSizeBytes+recBytes]); err != nil {
+		return err
+	}
+	switch rec.Type {
+	case EntryType, StateType, SnapshotType:
+		return nil
+	default:
+		return fmt.Errorf("unexpected record type %d", rec.Type)
+	}
+}
@@ -16,15 +16,23 @@ package wal
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/pkg/v3/pbutil"
 	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+	"go.etcd.io/raft/v3/raftpb"
 )
 
 var (
@@ -94,6 +102,262 @@ func TestWriteRecord(t *testing.T) {
 	}
 }
 
+// TestWriteRecordCRC32C ensures records are checksummed with CRC32C, which
+// WAL files of all versions are written with.
+func TestWriteRecordCRC32C(t *testing.T) {
+	d := []byte("Hello world!")
+	buf := new(bytes.Buffer)
+	e := newEncoder(buf, 0, 0)
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: d}))
+	require.NoError(t, e.flush())
+
+	f, err := createFileWithData(t, buf)
+	require.NoError(t, err)
+	rec := &walpb.Record{}
+	require.NoError(t, NewDecoder(fileutil.NewFileReader(f)).Decode(rec))
+	require.Equal(t, crc32.Checksum(d, crc32.MakeTable(crc32.Castagnoli)), rec.Crc)
+}
+
+// shortWriter writes at most max bytes per call, and fails to write anything
+// once limit bytes were written, without returning an error.
+type shortWriter struct {
+	w     io.Writer
+	max   int
+	limit int
+}
+
+func (sw *shortWriter) Write(p []byte) (int, error) {
+	n := min(len(p), sw.max, sw.limit)
+	sw.limit -= n
+	return sw.w.Write(p[:n])
+}
+
+func TestWriteRecordShortWrite(t *testing.T) {
+	data := make([]byte, 2*walPageBytes)
+	buf := new(bytes.Buffer)
+	e := newEncoder(&shortWriter{w: buf, max: 3, limit: math.MaxInt}, 0, 0)
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: data}))
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: []byte("data")}))
+	require.NoError(t, e.flush())
+
+	f, err := createFileWithData(t, buf)
+	require.NoError(t, err)
+	decoder := NewDecoder(fileutil.NewFileReader(f))
+	rec := &walpb.Record{}
+	require.NoError(t, decoder.Decode(rec))
+	require.Equal(t, data, rec.Data)
+	require.NoError(t, decoder.Decode(rec))
+	require.Equal(t, []byte("data"), rec.Data)
+
+	e = newEncoder(&shortWriter{w: new(bytes.Buffer), max: 3, limit: 5}, 0, 0)
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: []byte("data")}))
+	require.ErrorIs(t, e.flush(), ErrShortWrite)
+}
+
+func TestEncodeRawShortWrite(t *testing.T) {
+	var frames [][]byte
+	var recs []walpb.Record
+	var prevCrc uint32
+	// the first frame is buffered, the second one is written past the buffer
+	for _, size := range []int{100, 64 * walPageBytes} {
+		rec := &walpb.Record{Type: EntryType, Data: make([]byte, size)}
+		var buf bytes.Buffer
+		require.NoError(t, EncodeRecords(&buf, prevCrc, rec))
+		frames = append(frames, buf.Bytes())
+		recs = append(recs, *rec)
+		prevCrc = rec.Crc
+	}
+	e := newEncoder(&shortWriter{w: new(bytes.Buffer), max: math.MaxInt, limit: 2 * walPageBytes}, 0, 0)
+	require.ErrorIs(t, e.encodeRaw(frames, recs), ErrShortWrite)
+	// the crc and size only cover the frame written
+	require.Equal(t, recs[0].Crc, e.crc.Sum32())
+	require.Equal(t, int64(len(frames[0])), e.encoded)
+}
+
+func TestEncodeRecords(t *testing.T) {
+	recs := []*walpb.Record{
+		{Type: MetadataType, Data: []byte("metadata")},
+		{Type: EntryType, Data: pbutil.MustMarshal(&raftpb.Entry{Index: 1, Data: []byte("data")})},
+		{Type: EntryType, Data: pbutil.MustMarshal(&raftpb.Entry{Index: 2})},
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, EncodeRecords(buf, 0, recs...))
+	require.Zero(t, buf.Len()%8)
+
+	f, err := createFileWithData(t, buf)
+	require.NoError(t, err)
+	decoder := NewDecoder(fileutil.NewFileReader(f))
+	for _, want := range recs {
+		rec := &walpb.Record{}
+		require.NoError(t, decoder.Decode(rec))
+		require.Equal(t, want, rec)
+	}
+	require.ErrorIs(t, decoder.Decode(&walpb.Record{}), io.EOF)
+}
+
+func TestReadRecordSequenced(t *testing.T) {
+	buf := new(bytes.Buffer)
+	e := newEncoder(buf, 0, 0)
+	e.sequenced = true
+	require.NoError(t, e.encode(&walpb.Record{Type: MetadataType, Data: []byte("metadata")}))
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: []byte("data1")}))
+	// a gap in the sequence with an otherwise valid record
+	e.seq += 3
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: []byte("data2")}))
+	require.NoError(t, e.flush())
+
+	f, err := createFileWithData(t, buf)
+	require.NoError(t, err)
+	decoder := NewDecoder(fileutil.NewFileReader(f))
+	rec := &walpb.Record{}
+	require.NoError(t, decoder.Decode(rec))
+	require.Equal(t, []byte("metadata"), rec.Data)
+	require.NoError(t, decoder.Decode(rec))
+	require.Equal(t, []byte("data1"), rec.Data)
+
+	var seqErr *SequenceError
+	require.ErrorAs(t, decoder.Decode(rec), &seqErr)
+	require.Equal(t, SequenceError{Expected: 3, Actual: 6}, *seqErr)
+}
+
+func TestReadRecordUnknownFormat(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, EncodeRecords(buf, 0,
+		&walpb.Record{Type: FormatType, Data: binary.BigEndian.AppendUint32(nil, formatSequenced)},
+		&walpb.Record{Type: FormatType, Data: binary.BigEndian.AppendUint32(nil, formatSequenced+1)},
+	))
+	f, err := createFileWithData(t, buf)
+	require.NoError(t, err)
+	decoder := NewDecoder(fileutil.NewFileReader(f))
+	rec := &walpb.Record{}
+	require.NoError(t, decoder.Decode(rec))
+	require.Equal(t, formatSequenced, lastFormat(decoder))
+	require.ErrorIs(t, decoder.Decode(rec), ErrUnknownFormat)
+}
+
+func TestReadRecordTolerateZeroPadding(t *testing.T) {
+	// a partial, all-zero frame header after the last record can only come from padding
+	padded := append(append([]byte{}, infoRecord...), make([]byte, 5)...)
+	tests := []struct {
+		name string
+		cfg  DecoderConfig
+		data []byte
+		we   error
+	}{
+		{"strict zero padding", DecoderConfig{}, padded, io.ErrUnexpectedEOF},
+		{"tolerant zero padding", DecoderConfig{TolerateZeroPadding: true}, padded, io.EOF},
+		{"tolerant torn header", DecoderConfig{TolerateZeroPadding: true}, append(append([]byte{}, infoRecord...), 0x0e, 0x00, 0x00), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := createFileWithData(t, bytes.NewBuffer(tt.data))
+			require.NoError(t, err)
+			decoder := NewDecoderWithConfig(tt.cfg, fileutil.NewFileReader(f))
+			rec := &walpb.Record{}
+			require.NoError(t, decoder.Decode(rec))
+			require.Equal(t, infoData, rec.Data)
+			require.ErrorIs(t, decoder.Decode(rec), tt.we)
+		})
+	}
+}
+
+func TestReadRecordSkipCorrupted(t *testing.T) {
+	buf := new(bytes.Buffer)
+	e := newEncoder(buf, 0, 0)
+	require.NoError(t, e.encode(&walpb.Record{Type: MetadataType, Data: []byte("metadata")}))
+	require.NoError(t, e.flush())
+	corruptOff := int64(buf.Len())
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: []byte("data1")}))
+	require.NoError(t, e.flush())
+	corruptSize := int64(buf.Len()) - corruptOff
+	require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: []byte("data2")}))
+	require.NoError(t, e.flush())
+	data := buf.Bytes()
+	// flip the last byte of "data1", keeping the frame intact
+	data[bytes.Index(data, []byte("data1"))+4] ^= 0xff
+
+	tests := []struct {
+		name string
+		cfg  DecoderConfig
+	}{
+		{"strict", DecoderConfig{}},
+		{"skip one", DecoderConfig{MaxSkippableErrors: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := createFileWithData(t, bytes.NewBuffer(data))
+			require.NoError(t, err)
+			decoder := NewDecoderWithConfig(tt.cfg, fileutil.NewFileReader(f))
+			rec := &walpb.Record{}
+			require.NoError(t, decoder.Decode(rec))
+			require.Equal(t, []byte("metadata"), rec.Data)
+			if tt.cfg.MaxSkippableErrors == 0 {
+				require.ErrorIs(t, decoder.Decode(rec), walpb.ErrCRCMismatch)
+				require.Empty(t, decoder.SkippedRegions())
+				return
+			}
+			require.NoError(t, decoder.Decode(rec))
+			require.Equal(t, []byte("data2"), rec.Data)
+			require.ErrorIs(t, decoder.Decode(rec), io.EOF)
+			require.Equal(t, []SkippedRegion{{File: filepath.Base(f.Name()), Offset: corruptOff, Size: corruptSize}}, decoder.SkippedRegions())
+		})
+	}
+}
+
+func TestReadRecordSkipCorruptedDeterministic(t *testing.T) {
+	buf := new(bytes.Buffer)
+	e := newEncoder(buf, 0, 0)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, e.encode(&walpb.Record{Type: EntryType, Data: []byte(fmt.Sprintf("data%d", i))}))
+	}
+	require.NoError(t, e.flush())
+	data := buf.Bytes()
+	for _, corrupted := range []string{"data2", "data5", "data6"} {
+		data[bytes.Index(data, []byte(corrupted))+4] ^= 0xff
+	}
+
+	salvage := func() ([]string, []SkippedRegion) {
+		f, err := createFileWithData(t, bytes.NewBuffer(data))
+		require.NoError(t, err)
+		decoder := NewDecoderWithConfig(DecoderConfig{MaxSkippableErrors: 3}, fileutil.NewFileReader(f))
+		var decoded []string
+		rec := &walpb.Record{}
+		for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
+			decoded = append(decoded, string(rec.Data))
+		}
+		require.ErrorIs(t, err, io.EOF)
+		regions := decoder.SkippedRegions()
+		for i := range regions {
+			// files differ between runs, only their content is the same
+			regions[i].File = ""
+		}
+		return decoded, regions
+	}
+	decoded, regions := salvage()
+	require.Equal(t, []string{"data0", "data1", "data3", "data4", "data7", "data8", "data9"}, decoded)
+	require.Len(t, regions, 3)
+	for i := 0; i < 3; i++ {
+		again, againRegions := salvage()
+		require.Equal(t, decoded, again)
+		require.Equal(t, regions, againRegions)
+	}
+}
+
+func TestFrameSizeBeyond32Bits(t *testing.T) {
+	for _, dataBytes := range []int{0, 1, 7, 8, math.MaxUint32, math.MaxUint32 + 1, 5 << 30, 1<<56 - 8, 1<<56 - 1} {
+		lenField, padBytes := encodeFrameSize(dataBytes)
+		require.Zero(t, (dataBytes+padBytes)%8)
+		recBytes, decodedPad := decodeFrameSize(int64(lenField))
+		require.Equalf(t, int64(dataBytes), recBytes, "record of %d bytes", dataBytes)
+		require.Equalf(t, int64(padBytes), decodedPad, "record of %d bytes", dataBytes)
+
+		// the sequenced flag doesn't change the size
+		recBytes, decodedPad = decodeFrameSize(int64(lenField | frameSequencedFlag))
+		require.Equal(t, int64(dataBytes), recBytes)
+		require.Equal(t, int64(padBytes), decodedPad)
+	}
+}
+
 func createFileWithData(t *testing.T, bf *bytes.Buffer) (*os.File, error) {
 	f, err := os.CreateTemp(t.TempDir(), "wal")
 	if err != nil {
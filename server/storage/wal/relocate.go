@@ -0,0 +1,187 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+)
+
+// Relocate moves the WAL in srcDir to dstDir, which must not exist yet.
+// All segments are locked for the duration of the move, so Relocate fails
+// if the WAL is currently opened for writing. The directory is renamed when
+// both paths are on the same filesystem. Otherwise the segments are copied
+// into a temporary directory next to dstDir, which is then renamed to dstDir
+// so that a partially copied WAL is never visible; the segments are only
+// removed from srcDir once the copy is durable, and srcDir itself only if
+// nothing else is left in it. The locks on the segments are held on the copies too,
+// so in either case the WAL can't be opened for writing at dstDir until
+// Relocate returns. The parent directories are fsynced to persist the move.
+func Relocate(lg *zap.Logger, srcDir, dstDir string) error {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	srcDir, dstDir = filepath.Clean(srcDir), filepath.Clean(dstDir)
+	if fileutil.Exist(dstDir) {
+		return os.ErrExist
+	}
+	names, err := readWALNames(lg, srcDir)
+	if err != nil {
+		return err
+	}
+
+	locks := make([]io.ReadCloser, 0, len(names))
+	defer func() { closeAll(lg, locks...) }()
+	for _, name := range names {
+		l, err := fileutil.TryLockFile(filepath.Join(srcDir, name), os.O_RDWR, fileutil.PrivateFileMode)
+		if err != nil {
+			return fmt.Errorf("wal: failed to lock %q, is the WAL open? %w", name, err)
+		}
+		locks = append(locks, l)
+	}
+
+	if err = os.Rename(srcDir, dstDir); err != nil {
+		if !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+		lg.Info(
+			"failed to rename WAL directory, copying segments instead",
+			zap.String("from", srcDir),
+			zap.String("to", dstDir),
+			zap.Error(err),
+		)
+		copied, err := copyWALDir(lg, srcDir, dstDir, names)
+		locks = append(locks, copied...)
+		if err != nil {
+			return err
+		}
+		if err = syncDir(filepath.Dir(dstDir)); err != nil {
+			return err
+		}
+		if err = removeRelocatedWAL(lg, srcDir, names); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range []string{filepath.Dir(srcDir), filepath.Dir(dstDir)} {
+		if err = syncDir(dir); err != nil {
+			lg.Warn("failed to fsync the parent data directory", zap.String("parent-dir-path", dir), zap.Error(err))
+			return err
+		}
+	}
+	lg.Info("relocated WAL", zap.String("from", srcDir), zap.String("to", dstDir))
+	return nil
+}
+
+// copyWALDir copies the given segments of srcDir into dstDir through a
+// temporary directory, so dstDir only appears once all segments are durable.
+// The copies are returned locked; the caller must close them.
+func copyWALDir(lg *zap.Logger, srcDir, dstDir string, names []string) ([]io.ReadCloser, error) {
+	tmpDir := dstDir + ".tmp"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return nil, err
+	}
+	if err := fileutil.CreateDirAll(lg, tmpDir); err != nil {
+		return nil, err
+	}
+	locks := make([]io.ReadCloser, 0, len(names))
+	fail := func(err error) ([]io.ReadCloser, error) {
+		closeAll(lg, locks...)
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	for _, name := range names {
+		l, err := copyWALFile(filepath.Join(srcDir, name), filepath.Join(tmpDir, name))
+		if err != nil {
+			return fail(err)
+		}
+		locks = append(locks, l)
+	}
+	if err := syncDir(tmpDir); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpDir, dstDir); err != nil {
+		return fail(err)
+	}
+	return locks, nil
+}
+
+// removeRelocatedWAL removes the given segments copied out of srcDir, and the
+// .tmp files the WAL preallocates or writes through in it, then srcDir if it
+// is left empty. Any other file, e.g. a segment kept by SalvageSegment with a
+// .corrupt suffix, is left in place along with srcDir.
+func removeRelocatedWAL(lg *zap.Logger, srcDir string, names []string) error {
+	copied := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		copied[name] = struct{}{}
+	}
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for _, e := range entries {
+		if _, ok := copied[e.Name()]; !ok && !strings.HasSuffix(e.Name(), ".tmp") {
+			kept = append(kept, e.Name())
+			continue
+		}
+		if err = os.Remove(filepath.Join(srcDir, e.Name())); err != nil {
+			return err
+		}
+	}
+	if len(kept) > 0 {
+		lg.Warn("keeping WAL directory with files that were not relocated", zap.String("dir", srcDir), zap.Strings("files", kept))
+		return syncDir(srcDir)
+	}
+	return os.Remove(srcDir)
+}
+
+func copyWALFile(src, dst string) (*fileutil.LockedFile, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	out, err := createNewWALFile[*fileutil.LockedFile](dst, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(out, in); err == nil {
+		err = fileutil.Fsync(out.File)
+	}
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+func syncDir(dir string) error {
+	d, err := fileutil.OpenDir(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return fileutil.Fsync(d)
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+func TestRelocate(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	src := filepath.Join(t.TempDir(), "wal")
+	dst := filepath.Join(t.TempDir(), "wal")
+
+	w, err := Create(lg, src, []byte("metadata"))
+	require.NoError(t, err)
+	ents := []raftpb.Entry{{Index: 1, Term: 1, Data: []byte("data")}}
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, ents))
+
+	// an open WAL must not be moved
+	require.ErrorIs(t, Relocate(lg, src, dst), fileutil.ErrLocked)
+	require.NoError(t, w.Close())
+
+	require.NoError(t, Relocate(lg, src, dst))
+	require.NoDirExists(t, src)
+
+	w, err = Open(lg, dst, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	metadata, _, gotEnts, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []byte("metadata"), metadata)
+	require.Equal(t, ents, gotEnts)
+}
+
+func TestRelocateDstExists(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	src := filepath.Join(t.TempDir(), "wal")
+	w, err := Create(lg, src, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	require.ErrorIs(t, Relocate(lg, src, t.TempDir()), os.ErrExist)
+}
+
+func TestRelocateRenameError(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	src := filepath.Join(t.TempDir(), "wal")
+	w, err := Create(lg, src, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// only a rename across filesystems falls back to copying
+	require.ErrorIs(t, Relocate(lg, src, filepath.Join(t.TempDir(), "missing", "wal")), os.ErrNotExist)
+	require.DirExists(t, src)
+}
+
+func TestRemoveRelocatedWAL(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	dir := filepath.Join(t.TempDir(), "wal")
+	w, err := Create(lg, dir, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Close())
+	names, err := readWALNames(lg, dir)
+	require.NoError(t, err)
+
+	// a file the WAL doesn't own keeps the directory
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0.tmp"), nil, fileutil.PrivateFileMode))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), nil, fileutil.PrivateFileMode))
+	require.NoError(t, removeRelocatedWAL(lg, dir, names))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "other", entries[0].Name())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "other")))
+	require.NoError(t, removeRelocatedWAL(lg, dir, nil))
+	require.NoDirExists(t, dir)
+}
+
+func TestCopyWALDir(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	src := filepath.Join(t.TempDir(), "wal")
+	dst := filepath.Join(t.TempDir(), "wal")
+
+	w, err := Create(lg, src, nil)
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: uint64(i)}}))
+		require.NoError(t, w.cut())
+	}
+	require.NoError(t, w.Close())
+
+	names, err := readWALNames(lg, src)
+	require.NoError(t, err)
+	locks, err := copyWALDir(lg, src, dst, names)
+	require.NoError(t, err)
+	require.NoDirExists(t, dst+".tmp")
+
+	// the copies stay locked until the caller is done
+	_, err = Open(lg, dst, walpb.Snapshot{})
+	require.ErrorIs(t, err, fileutil.ErrLocked)
+	require.NoError(t, closeAll(lg, locks...))
+
+	w, err = Open(lg, dst, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, ents, 3)
+}
@@ -16,6 +16,7 @@ package wal
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -27,17 +28,52 @@ import (
 	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
 )
 
+// RepairReport describes a repair of the last WAL file, see RepairWithReport.
+type RepairReport struct {
+	// File is the path of the last WAL file, the one checked for a repair.
+	File string `json:"file"`
+	// Repaired is set if the file ended with a torn record and was truncated.
+	Repaired bool `json:"repaired"`
+	// Offset is the size the file was truncated to, the offset of the first
+	// record that couldn't be decoded.
+	Offset int64 `json:"offset,omitempty"`
+	// DroppedRecords is the number of records found past Offset, the torn one
+	// included, and DroppedBytes the size of the data past it.
+	DroppedRecords int   `json:"droppedRecords,omitempty"`
+	DroppedBytes   int64 `json:"droppedBytes,omitempty"`
+	// Backup is the path of the copy of the file made before truncating it.
+	Backup string `json:"backup,omitempty"`
+}
+
 // Repair tries to repair ErrUnexpectedEOF in the
 // last wal file by truncating.
 func Repair(lg *zap.Logger, dirpath string) bool {
 	if lg == nil {
 		lg = zap.NewNop()
 	}
+	if _, err := RepairWithReport(lg, dirpath); err != nil {
+		lg.Warn("failed to repair", zap.String("dir", dirpath), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// RepairWithReport is Repair, but returns what was repaired, or why the WAL
+// couldn't be repaired. The last WAL file is truncated at the first record
+// that can't be decoded because it was torn, after being copied next to it
+// with a .broken suffix. It doesn't require the WAL to be opened, so it can be
+// used to repair the WAL of a stopped member offline.
+func RepairWithReport(lg *zap.Logger, dirpath string) (RepairReport, error) {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	var report RepairReport
 	f, err := openLast(lg, dirpath)
 	if err != nil {
-		return false
+		return report, err
 	}
 	defer f.Close()
+	report.File = f.Name()
 
 	lg.Info("repairing", zap.String("path", f.Name()))
 
@@ -54,7 +90,7 @@ func Repair(lg *zap.Logger, dirpath string) bool {
 				// current crc of decoder must match the crc of the record.
 				// do no need to match 0 crc, since the decoder is a new one at this case.
 				if crc != 0 && rec.Validate(crc) != nil {
-					return false
+					return report, ErrCRCMismatch
 				}
 				decoder.UpdateCRC(rec.Crc)
 			}
@@ -62,45 +98,55 @@ func Repair(lg *zap.Logger, dirpath string) bool {
 
 		case errors.Is(err, io.EOF):
 			lg.Info("repaired", zap.String("path", f.Name()), zap.Error(io.EOF))
-			return true
+			return report, nil
 
 		case errors.Is(err, io.ErrUnexpectedEOF):
 			brokenName := f.Name() + ".broken"
 			bf, bferr := createNewWALFile[*os.File](brokenName, true)
 			if bferr != nil {
-				lg.Warn("failed to create backup file", zap.String("path", brokenName), zap.Error(bferr))
-				return false
+				return report, fmt.Errorf("failed to create backup file %q: %w", brokenName, bferr)
 			}
 			defer bf.Close()
 
 			if _, err = f.Seek(0, io.SeekStart); err != nil {
-				lg.Warn("failed to read file", zap.String("path", f.Name()), zap.Error(err))
-				return false
+				return report, fmt.Errorf("failed to read file %q: %w", f.Name(), err)
+			}
+
+			size, err := io.Copy(bf, f)
+			if err != nil {
+				return report, fmt.Errorf("failed to copy %q to %q: %w", f.Name(), brokenName, err)
 			}
 
-			if _, err = io.Copy(bf, f); err != nil {
-				lg.Warn("failed to copy", zap.String("from", f.Name()), zap.String("to", brokenName), zap.Error(err))
-				return false
+			if _, err = f.Seek(lastOffset, io.SeekStart); err != nil {
+				return report, fmt.Errorf("failed to read file %q: %w", f.Name(), err)
 			}
+			_, dropped, _ := scanTornTail(f)
 
 			if err = f.Truncate(lastOffset); err != nil {
-				lg.Warn("failed to truncate", zap.String("path", f.Name()), zap.Error(err))
-				return false
+				return report, fmt.Errorf("failed to truncate %q: %w", f.Name(), err)
 			}
 
 			start := time.Now()
 			if err = fileutil.Fsync(f.File); err != nil {
-				lg.Warn("failed to fsync", zap.String("path", f.Name()), zap.Error(err))
-				return false
+				return report, fmt.Errorf("failed to fsync %q: %w", f.Name(), err)
 			}
 			walFsyncSec.Observe(time.Since(start).Seconds())
 
-			lg.Info("repaired", zap.String("path", f.Name()), zap.Error(io.ErrUnexpectedEOF))
-			return true
+			report.Repaired = true
+			report.Offset = lastOffset
+			report.DroppedRecords = dropped
+			report.DroppedBytes = size - lastOffset
+			report.Backup = brokenName
+			lg.Info("repaired",
+				zap.String("path", f.Name()),
+				zap.Int64("offset", lastOffset),
+				zap.Int("dropped-records", dropped),
+				zap.Error(io.ErrUnexpectedEOF),
+			)
+			return report, nil
 
 		default:
-			lg.Warn("failed to repair", zap.String("path", f.Name()), zap.Error(err))
-			return false
+			return report, err
 		}
 	}
 }
@@ -15,6 +15,7 @@
 package wal
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -46,6 +47,50 @@ func TestRepairTruncate(t *testing.T) {
 	testRepair(t, makeEnts(10), corruptf, 9)
 }
 
+func TestRepairWithReport(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, nil)
+	require.NoError(t, err)
+	for _, es := range makeEnts(10) {
+		require.NoError(t, w.Save(raftpb.HardState{}, es))
+	}
+	offset, err := w.tail().Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	name := filepath.Join(p, filepath.Base(w.tail().Name()))
+	require.NoError(t, w.Close())
+
+	report, err := RepairWithReport(lg, p)
+	require.NoError(t, err)
+	require.Equal(t, RepairReport{File: name}, report, "nothing to repair")
+
+	// tear the last entry record
+	require.NoError(t, os.Truncate(name, offset-4))
+	report, err = RepairWithReport(lg, p)
+	require.NoError(t, err)
+	require.True(t, report.Repaired)
+	require.Equal(t, name, report.File)
+	require.Equal(t, name+".broken", report.Backup)
+	require.Equal(t, 1, report.DroppedRecords)
+	require.Equal(t, offset-4, report.Offset+report.DroppedBytes)
+	fi, err := os.Stat(name)
+	require.NoError(t, err)
+	require.Equal(t, report.Offset, fi.Size())
+
+	b, err := json.Marshal(report)
+	require.NoError(t, err)
+	var decoded RepairReport
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, report, decoded)
+
+	w, err = Open(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, ents, 9)
+}
+
 func testRepair(t *testing.T, ents [][]raftpb.Entry, corrupt corruptFunc, expectedEnts int) {
 	lg := zaptest.NewLogger(t)
 	p := t.TempDir()
@@ -74,13 +119,14 @@ func testRepair(t *testing.T, ents [][]raftpb.Entry, corrupt corruptFunc, expect
 
 	_, _, _, err = w.ReadAll()
 	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	tailName := filepath.Base(w.tail().Name())
 	require.NoError(t, w.Close())
 
 	// repair the wal
 	require.True(t, Repair(lg, p))
 
 	// verify the broken wal has correct permissions
-	bf := filepath.Join(p, filepath.Base(w.tail().Name())+".broken")
+	bf := filepath.Join(p, tailName+".broken")
 	fi, err := os.Stat(bf)
 	require.NoError(t, err)
 	expectedPerms := fmt.Sprintf("%o", os.FileMode(fileutil.PrivateFileMode))
@@ -166,16 +212,11 @@ func TestRepairWriteTearMiddle(t *testing.T) {
 func TestRepairFailDeleteDir(t *testing.T) {
 	p := t.TempDir()
 
-	w, err := Create(zaptest.NewLogger(t), p, nil)
+	w, err := Create(zaptest.NewLogger(t), p, nil, WithSegmentSize(64))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	oldSegmentSizeBytes := SegmentSizeBytes
-	SegmentSizeBytes = 64
-	defer func() {
-		SegmentSizeBytes = oldSegmentSizeBytes
-	}()
 	for _, es := range makeEnts(50) {
 		if err = w.Save(raftpb.HardState{}, es); err != nil {
 			t.Fatal(err)
@@ -0,0 +1,193 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+)
+
+// SalvageReport describes what SalvageSegment recovered from a segment.
+type SalvageReport struct {
+	// Segment is the file name of the segment.
+	Segment string
+	// Records is the number of records recovered, LastIndex the raft index
+	// of the last entry among them, 0 if there is none.
+	Records   int
+	LastIndex uint64
+	// RecoveredBytes is the size of the salvaged segment, LostBytes the size
+	// of the data past the corruption that was dropped.
+	RecoveredBytes int64
+	LostBytes      int64
+	// Cause is the error the records stopped being readable at, nil if the
+	// whole segment could be read.
+	Cause error
+}
+
+// SalvageSegment rebuilds a corrupted finalized segment of the WAL in dir out
+// of the records preceding the first one that can't be read. The original is
+// kept next to it with a .corrupt suffix, and the report logged. If all the
+// records can be read, e.g. when only the footer doesn't match, the segment is
+// left as is and LostBytes is 0.
+//
+// A salvaged segment is not complete: the records past the corruption are lost
+// and the crc chain with the following segment is broken, so the WAL fails to
+// open past it rather than silently missing entries. Salvaging is meant to
+// recover as much data as possible for an operator, not to repair the WAL.
+// Compressed segments can't be salvaged.
+//
+// The segment is locked exclusively while it is salvaged, like purge does
+// before removing a file, so a segment still locked by a WAL open for writing,
+// i.e. not yet released with ReleaseLockTo, is left as is and an error
+// wrapping fileutil.ErrLocked is returned.
+func SalvageSegment(lg *zap.Logger, dir, name string) (*SalvageReport, error) {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	path := filepath.Join(dir, name)
+	l, err := fileutil.TryLockFile(path, os.O_WRONLY, fileutil.PrivateFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("wal: can't lock segment %q to salvage it: %w", name, err)
+	}
+	defer l.Close()
+	report, err := readSalvageable(lg, dir, name)
+	if err != nil || report.LostBytes == 0 {
+		return report, err
+	}
+	corruptPath := path + ".corrupt"
+	if _, err = os.Stat(corruptPath); err == nil {
+		return nil, fmt.Errorf("wal: %q already exists: %w", corruptPath, os.ErrExist)
+	}
+
+	tmpPath := path + ".tmp"
+	if err = copySalvaged(path, tmpPath, report.RecoveredBytes); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err = os.Rename(path, corruptPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return nil, err
+	}
+	if err = syncDir(dir); err != nil {
+		return nil, err
+	}
+	lg.Warn(
+		"salvaged corrupted WAL segment, records past the corruption are lost",
+		zap.String("segment", name),
+		zap.String("original", corruptPath),
+		zap.Int("recovered-records", report.Records),
+		zap.Uint64("last-recovered-index", report.LastIndex),
+		zap.Int64("recovered-bytes", report.RecoveredBytes),
+		zap.Int64("lost-bytes", report.LostBytes),
+		zap.Error(report.Cause),
+	)
+	return report, nil
+}
+
+// readSalvageable decodes the records of the segment until the first one that
+// can't be read.
+func readSalvageable(lg *zap.Logger, dir, name string) (*SalvageReport, error) {
+	path := filepath.Join(dir, name)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(zstdMagic))
+	n, err := f.ReadAt(magic, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if bytes.HasPrefix(magic[:n], gzipMagic) || bytes.HasPrefix(magic[:n], zstdMagic) {
+		return nil, fmt.Errorf("wal: can't salvage compressed segment %q", name)
+	}
+
+	rs, _, closer, err := openWALFiles(lg, dir, []string{name}, 0, false, false)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	report := &SalvageReport{Segment: name}
+	decoder := NewDecoder(rs...)
+	rec := &walpb.Record{}
+	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
+		report.Records++
+		switch rec.Type {
+		case EntryType:
+			report.LastIndex = MustUnmarshalEntry(rec.Data).Index
+		case CrcType:
+			decoder.UpdateCRC(rec.Crc)
+		}
+	}
+	if !errors.Is(err, io.EOF) {
+		report.Cause = err
+	}
+	report.RecoveredBytes = decoder.LastOffset()
+	report.LostBytes = fi.Size() - report.RecoveredBytes
+	return report, nil
+}
+
+// copySalvaged writes the first size bytes of the file at from into a new
+// synced file at to.
+func copySalvaged(from, to string, size int64) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := createNewWALFile[*os.File](to, true)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if _, err = io.CopyN(dst, src, size); err != nil {
+		return err
+	}
+	return fileutil.Fsync(dst)
+}
+
+// salvageCorruptedSegments salvages the corrupted finalized segments of the
+// WAL in dir, see WithReadRepair.
+func salvageCorruptedSegments(lg *zap.Logger, dir string) {
+	names, err := readWALNames(lg, dir)
+	if err != nil {
+		return
+	}
+	for _, name := range names[:len(names)-1] {
+		var cerr *CorruptionError
+		if !errors.As(scrubSegment(lg, dir, name), &cerr) {
+			continue
+		}
+		if _, err = SalvageSegment(lg, dir, name); err != nil {
+			lg.Warn("failed to salvage corrupted WAL segment", zap.String("segment", name), zap.Error(err))
+		}
+	}
+}
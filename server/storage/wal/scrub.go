@@ -0,0 +1,131 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+)
+
+// CorruptionError reports a finalized segment found corrupted by a scrub, see
+// StartBackgroundScrub.
+type CorruptionError struct {
+	// Segment is the file name of the segment.
+	Segment string
+	Err     error
+	// Salvage is set when the segment was salvaged, see WithReadRepair.
+	Salvage *SalvageReport
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("wal: segment %q is corrupted: %v", e.Segment, e.Err)
+}
+
+func (e *CorruptionError) Unwrap() error {
+	return e.Err
+}
+
+// StartBackgroundScrub checks the finalized segments of the WAL every
+// interval, until ctx is canceled, calling onError for each corrupted segment
+// found. Segments with a footer, see WithSegmentFooters, are checked against
+// the crc of the footer, the others by decoding their records. The tail
+// segment, the only one being written, is never read, and the segments are
+// read through their own descriptors without holding the WAL lock, so the
+// scrub doesn't contend with the write path. Segments removed while scrubbing,
+// e.g. by purge, are skipped. A corrupted segment is reported again on each
+// scrub. With WithReadRepair, only the segments the WAL released with
+// ReleaseLockTo are salvaged, see SalvageSegment.
+func (w *WAL) StartBackgroundScrub(ctx context.Context, interval time.Duration, onError func(*CorruptionError)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			w.scrub(ctx, onError)
+		}
+	}()
+}
+
+func (w *WAL) scrub(ctx context.Context, onError func(*CorruptionError)) {
+	names, err := readWALNames(w.lg, w.dir)
+	if err != nil {
+		w.lg.Warn("failed to list WAL segments to scrub", zap.String("dir", w.dir), zap.Error(err))
+		return
+	}
+	// the last segment is the tail, the segments before it are finalized as
+	// cut only renames the new tail once the previous one is synced
+	for _, name := range names[:len(names)-1] {
+		if ctx.Err() != nil {
+			return
+		}
+		err = scrubSegment(w.lg, w.dir, name)
+		var cerr *CorruptionError
+		switch {
+		case err == nil, errors.Is(err, os.ErrNotExist):
+		case errors.As(err, &cerr):
+			if w.opts.readRepair {
+				if cerr.Salvage, err = SalvageSegment(w.lg, w.dir, name); err != nil {
+					w.lg.Warn("failed to salvage corrupted WAL segment", zap.String("segment", name), zap.Error(err))
+				}
+			}
+			onError(cerr)
+		default:
+			w.lg.Warn("failed to scrub WAL segment", zap.String("segment", name), zap.Error(err))
+		}
+	}
+}
+
+// scrubSegment checks a finalized segment, returning a *CorruptionError if
+// it is corrupted.
+func scrubSegment(lg *zap.Logger, dir, name string) error {
+	ok, err := verifySegmentFooter(filepath.Join(dir, name))
+	if errors.Is(err, ErrSegmentFooterMismatch) {
+		return &CorruptionError{Segment: name, Err: err}
+	}
+	if err != nil || ok {
+		return err
+	}
+	rs, _, closer, err := openWALFiles(lg, dir, []string{name}, 0, false, false)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	decoder := NewDecoder(rs...)
+	rec := &walpb.Record{}
+	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
+		if rec.Type == CrcType {
+			decoder.UpdateCRC(rec.Crc)
+		}
+	}
+	// finalized segments are truncated and synced when cut, so unlike the
+	// tail they can't end with a torn write
+	if !errors.Is(err, io.EOF) {
+		return &CorruptionError{Segment: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/pkg/v3/pbutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+// SnapshotCache speeds up repeated ValidSnapshotEntries calls on the same WAL,
+// e.g. by a tool polling it. The snapshot and state records of the finalized
+// segments are kept, keyed by segment name, and only decoded again if the
+// modification time or size of the segment changed. The tail segment is
+// always decoded, as it's still being written. A SnapshotCache is safe for
+// concurrent use, and must only be used for a single WAL directory.
+type SnapshotCache struct {
+	mu       sync.Mutex
+	segments map[string]cachedSegment
+}
+
+type cachedSegment struct {
+	modTime time.Time
+	size    int64
+	// records holds the snapshot and state records, in the order they were
+	// written.
+	records []snapshotRecord
+	// firstCRC is the crc of the first crc record, the one chaining the
+	// segment to the previous one, lastCRC the crc at the end of the segment.
+	firstCRC, lastCRC uint32
+	// torn is set if the segment ends with a torn record, the records of
+	// the following segments are not read.
+	torn bool
+}
+
+type snapshotRecord struct {
+	snap  *walpb.Snapshot
+	state *raftpb.HardState
+}
+
+func NewSnapshotCache() *SnapshotCache {
+	return &SnapshotCache{segments: map[string]cachedSegment{}}
+}
+
+// ValidSnapshotEntries is ValidSnapshotEntries using the cache.
+func (c *SnapshotCache) ValidSnapshotEntries(lg *zap.Logger, walDir string) ([]walpb.Snapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names, err := readWALNames(lg, walDir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make(map[string]cachedSegment, len(names))
+	var snaps []walpb.Snapshot
+	var state raftpb.HardState
+	var lastCRC uint32
+	for i, name := range names {
+		fi, err := os.Stat(filepath.Join(walDir, name))
+		if err != nil {
+			return nil, err
+		}
+		tail := i == len(names)-1
+		seg, ok := c.segments[name]
+		if tail || !ok || !seg.modTime.Equal(fi.ModTime()) || seg.size != fi.Size() {
+			if seg, err = decodeSegmentSnapshotRecords(lg, walDir, name); err != nil {
+				return nil, err
+			}
+			seg.modTime, seg.size = fi.ModTime(), fi.Size()
+		}
+		if !tail {
+			segments[name] = seg
+		}
+		// same check of the crc chain as decodeSnapshotRecords does
+		if lastCRC != 0 && seg.firstCRC != lastCRC {
+			return nil, ErrCRCMismatch
+		}
+		lastCRC = seg.lastCRC
+		for _, rec := range seg.records {
+			if rec.snap != nil {
+				snaps = append(snaps, *rec.snap)
+			} else {
+				state = *rec.state
+			}
+		}
+		if seg.torn {
+			break
+		}
+	}
+	// purged segments are dropped with the entries of the previous call
+	c.segments = segments
+
+	// snaps that are newer than the committed hardstate are orphaned
+	var valid []walpb.Snapshot
+	for _, snap := range snaps {
+		if snap.Index <= state.Commit {
+			valid = append(valid, snap)
+		}
+	}
+	return valid, nil
+}
+
+// decodeSegmentSnapshotRecords decodes the snapshot and state records of a
+// single segment of the WAL in walDir.
+func decodeSegmentSnapshotRecords(lg *zap.Logger, walDir, name string) (cachedSegment, error) {
+	var seg cachedSegment
+	rs, _, closer, err := openWALFiles(lg, walDir, []string{name}, 0, false, false)
+	if err != nil {
+		return seg, err
+	}
+	defer closer()
+	decoder := NewDecoder(rs...)
+	rec := &walpb.Record{}
+	firstCRC := true
+	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
+		switch rec.Type {
+		case SnapshotType:
+			var snap walpb.Snapshot
+			pbutil.MustUnmarshal(&snap, rec.Data)
+			seg.records = append(seg.records, snapshotRecord{snap: &snap})
+		case StateType:
+			state := MustUnmarshalState(rec.Data)
+			seg.records = append(seg.records, snapshotRecord{state: &state})
+		case CrcType:
+			crc := decoder.LastCRC()
+			if firstCRC {
+				seg.firstCRC, firstCRC = rec.Crc, false
+			} else if crc != 0 && rec.Validate(crc) != nil {
+				return seg, ErrCRCMismatch
+			}
+			decoder.UpdateCRC(rec.Crc)
+		}
+	}
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		seg.torn = true
+	case !errors.Is(err, io.EOF):
+		return seg, err
+	}
+	seg.lastCRC = decoder.LastCRC()
+	return seg, nil
+}
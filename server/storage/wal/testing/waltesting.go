@@ -15,6 +15,7 @@
 package testing
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"testing"
@@ -22,6 +23,7 @@ import (
 	"go.uber.org/zap/zaptest"
 
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
 	"go.etcd.io/etcd/pkg/v3/pbutil"
 	"go.etcd.io/etcd/server/v3/storage/wal"
 	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
@@ -90,3 +92,37 @@ func Reopen(tb testing.TB, walPath string) *wal.WAL {
 	}
 	return w
 }
+
+// NewInMemoryWAL returns a buffer holding records framed and crc chained the
+// way they are in a WAL file, for tests that decode or corrupt a WAL.
+func NewInMemoryWAL(records []*walpb.Record) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	if err := wal.EncodeRecords(buf, 0, records...); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// FailingSyncer is a wal.Syncer, see wal.WithSyncer, that fdatasyncs files like
+// the WAL does, except for the Nth call, counting from 1, that returns Err
+// without syncing. It simulates a disk failing in the middle of a Save, to
+// test the handling of sync errors. N is 0 to never fail.
+type FailingSyncer struct {
+	N   int
+	Err error
+
+	calls int
+}
+
+func (s *FailingSyncer) Sync(f *os.File) error {
+	s.calls++
+	if s.calls == s.N {
+		return s.Err
+	}
+	return fileutil.Fdatasync(f)
+}
+
+// Calls returns the number of syncs so far, including the failed one.
+func (s *FailingSyncer) Calls() int {
+	return s.calls
+}
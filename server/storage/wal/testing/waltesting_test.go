@@ -0,0 +1,64 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"go.etcd.io/etcd/server/v3/storage/wal"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+func TestFailingSyncer(t *testing.T) {
+	dir := t.TempDir()
+	lg := zaptest.NewLogger(t)
+	syncErr := errors.New("disk failure")
+	syncer := &FailingSyncer{Err: syncErr}
+	w, err := wal.Create(lg, dir, nil, wal.WithSyncer(syncer))
+	require.NoError(t, err)
+	// fail the sync of the third Save
+	syncer.N = syncer.Calls() + 3
+
+	var saved uint64
+	for index := uint64(1); index <= 5; index++ {
+		err = w.Save(raftpb.HardState{Term: 1, Commit: index}, []raftpb.Entry{{Index: index, Term: 1, Data: []byte("data")}})
+		if err != nil {
+			break
+		}
+		saved = index
+	}
+	require.ErrorIs(t, err, syncErr)
+	require.Equal(t, uint64(2), saved)
+	require.NoError(t, w.Close())
+
+	// The entries of the failed Save might have reached the disk or not,
+	// either way the WAL has to read back as a prefix of the saved entries.
+	w, err = wal.Open(lg, dir, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, state, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(ents), int(saved))
+	require.LessOrEqual(t, len(ents), int(saved)+1)
+	for i, ent := range ents {
+		require.Equal(t, uint64(i+1), ent.Index)
+	}
+	require.LessOrEqual(t, state.Commit, uint64(len(ents)))
+}
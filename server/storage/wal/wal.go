@@ -16,12 +16,16 @@ package wal
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -41,6 +45,15 @@ const (
 	StateType
 	CrcType
 	SnapshotType
+	// FooterType ends a finalized segment, see WithSegmentFooters.
+	FooterType
+	// FormatType declares the format version of the records following it in
+	// a segment, see WithRecordSequencing.
+	FormatType
+
+	// formatSequenced is the format version of records written with a
+	// sequence number in their frame, the latest version known.
+	formatSequenced uint32 = 1
 
 	// warnSyncDuration is the amount of time allotted to an fsync before
 	// logging a warning
@@ -51,7 +64,8 @@ var (
 	// SegmentSizeBytes is the preallocated size of each wal segment file.
 	// The actual size might be larger than this. In general, the default
 	// value should be used, but this is defined as an exported variable
-	// so that tests can set a different segment size.
+	// so that tests can set a different segment size. It is the default of
+	// WithSegmentSize.
 	SegmentSizeBytes int64 = 64 * 1000 * 1000 // 64MB
 
 	ErrMetadataConflict = errors.New("wal: conflicting metadata found")
@@ -61,7 +75,21 @@ var (
 	ErrSnapshotNotFound = errors.New("wal: snapshot not found")
 	ErrSliceOutOfRange  = errors.New("wal: slice bounds out of range")
 	ErrDecoderNotFound  = errors.New("wal: decoder not found")
-	crcTable            = crc32.MakeTable(crc32.Castagnoli)
+	ErrTermRegression   = errors.New("wal: hard state term regression")
+	ErrNotWritable      = errors.New("wal: not writable")
+	ErrWALClosed        = errors.New("wal: closed")
+	ErrWALFailed        = errors.New("wal: failed by an earlier write error")
+	ErrNotClosedCleanly = errors.New("wal: not closed cleanly")
+	ErrReadLimited      = errors.New("wal: read stopped at limit")
+	ErrWALTooSmall      = errors.New("wal: smaller than expected")
+	ErrSeekWritable     = errors.New("wal: cannot seek a WAL opened for writing")
+	ErrUnknownFormat    = errors.New("wal: unknown format version")
+
+	// crcTable is the table of the crc of the records. CRC32C has been the
+	// checksum of WAL records since the first format, and is hardware
+	// accelerated by hash/crc32 on amd64, arm64 and s390x, so there is no
+	// other algorithm to select.
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
 )
 
 // WAL is a logical representation of the stable storage.
@@ -81,30 +109,45 @@ type WAL struct {
 	state    raftpb.HardState // hardstate recorded at the head of WAL
 
 	start     walpb.Snapshot // snapshot to start reading
+	resume    *ReadPosition  // position reading resumes from, set by Seek
+	limited   *ReadPosition  // position a limited read stopped at, see ReadAllLimit
 	decoder   Decoder        // decoder to Decode records
 	readClose func() error   // closer for Decode reader
+	unmap     func()         // unmaps the files mapped for decoding, see WithMmapRead
+	segments  []string       // names of the files selected when opening, see Segments
 
 	unsafeNoSync bool // if set, do not fsync
 
 	mu      sync.Mutex
 	enti    uint64   // index of the last entry saved to the wal
 	encoder *encoder // encoder to encode records
+	closed  bool     // set by Close and Abort, writes fail with ErrWALClosed
+	// failed is the error of a write to the tail that failed, further writes
+	// fail with ErrWALFailed, see fail. flushed is the offset of the end of
+	// the records last synced to the tail.
+	failed  error
+	flushed int64
+	// finalCRC is the crc of the last record, set by a successful Close of a
+	// writable WAL, see FinalCRC.
+	finalCRC *uint32
 
 	locks []*fileutil.LockedFile // the locked files the WAL holds (the name is increasing)
 	fp    *filePipeline
+
+	opts options // optional settings given to Create or Open
 }
 
 // Create creates a WAL ready for appending records. The given metadata is
 // recorded at the head of each WAL file, and can be retrieved with ReadAll
 // after the file is Open.
-func Create(lg *zap.Logger, dirpath string, metadata []byte) (*WAL, error) {
+func Create(lg *zap.Logger, dirpath string, metadata []byte, opts ...Option) (*WAL, error) {
 	if Exist(dirpath) {
 		return nil, os.ErrExist
 	}
+	registerDefaultMetrics()
 
-	if lg == nil {
-		lg = zap.NewNop()
-	}
+	o := newOptions(opts...)
+	lg = o.logger(lg)
 
 	// keep temporary wal directory so WAL initialization appears atomic
 	tmpdirpath := filepath.Clean(dirpath) + ".tmp"
@@ -143,11 +186,11 @@ func Create(lg *zap.Logger, dirpath string, metadata []byte) (*WAL, error) {
 		)
 		return nil, err
 	}
-	if err = fileutil.Preallocate(f.File, SegmentSizeBytes, true); err != nil {
+	if err = fileutil.Preallocate(f.File, o.segmentSize, true); err != nil {
 		lg.Warn(
 			"failed to preallocate an initial WAL file",
 			zap.String("path", p),
-			zap.Int64("segment-bytes", SegmentSizeBytes),
+			zap.Int64("segment-bytes", o.segmentSize),
 			zap.Error(err),
 		)
 		return nil, err
@@ -157,13 +200,17 @@ func Create(lg *zap.Logger, dirpath string, metadata []byte) (*WAL, error) {
 		lg:       lg,
 		dir:      dirpath,
 		metadata: metadata,
+		segments: []string{walName(0, 0)},
+		opts:     o,
 	}
-	w.encoder, err = newFileEncoder(f.File, 0)
-	if err != nil {
+	if err = w.setEncoder(f.File, w.opts.crcSeed, 0); err != nil {
 		return nil, err
 	}
 	w.locks = append(w.locks, f)
-	if err = w.saveCrc(0); err != nil {
+	if err = w.saveCrc(w.opts.crcSeed); err != nil {
+		return nil, err
+	}
+	if err = w.sequenceRecords(false); err != nil {
 		return nil, err
 	}
 	if err = w.encoder.encode(&walpb.Record{Type: MetadataType, Data: metadata}); err != nil {
@@ -263,7 +310,7 @@ func (w *WAL) Reopen(lg *zap.Logger, snap walpb.Snapshot) (*WAL, error) {
 	if err != nil {
 		lg.Panic("failed to close WAL during reopen", zap.Error(err))
 	}
-	return Open(lg, w.dir, snap)
+	return open(w.opts.logger(lg), w.dir, snap, w.opts)
 }
 
 func (w *WAL) SetUnsafeNoFsync() {
@@ -303,7 +350,7 @@ func (w *WAL) renameWAL(tmpdirpath string) (*WAL, error) {
 		}
 		return nil, err
 	}
-	w.fp = newFilePipeline(w.lg, w.dir, SegmentSizeBytes)
+	w.fp = newFilePipeline(w.lg, w.dir, w.opts.segmentSize)
 	df, err := fileutil.OpenDir(w.dir)
 	w.dirFile = df
 	return w, err
@@ -324,7 +371,7 @@ func (w *WAL) renameWALUnlock(tmpdirpath string) (*WAL, error) {
 	}
 
 	// reopen and relock
-	newWAL, oerr := Open(w.lg, w.dir, walpb.Snapshot{})
+	newWAL, oerr := open(w.lg, w.dir, walpb.Snapshot{}, w.opts)
 	if oerr != nil {
 		return nil, oerr
 	}
@@ -341,8 +388,13 @@ func (w *WAL) renameWALUnlock(tmpdirpath string) (*WAL, error) {
 // The returned WAL is ready to read and the first record will be the one after
 // the given snap. The WAL cannot be appended to before reading out all of its
 // previous records.
-func Open(lg *zap.Logger, dirpath string, snap walpb.Snapshot) (*WAL, error) {
-	w, err := openAtIndex(lg, dirpath, snap, true)
+func Open(lg *zap.Logger, dirpath string, snap walpb.Snapshot, opts ...Option) (*WAL, error) {
+	o := newOptions(opts...)
+	return open(o.logger(lg), dirpath, snap, o)
+}
+
+func open(lg *zap.Logger, dirpath string, snap walpb.Snapshot, opts options) (*WAL, error) {
+	w, err := openAtIndex(lg, dirpath, snap, true, opts)
 	if err != nil {
 		return nil, fmt.Errorf("openAtIndex failed: %w", err)
 	}
@@ -352,34 +404,78 @@ func Open(lg *zap.Logger, dirpath string, snap walpb.Snapshot) (*WAL, error) {
 	return w, nil
 }
 
+// OpenOrCreate opens the WAL at the given snap if dirpath holds one, and
+// creates it with the given metadata otherwise. created reports which of the
+// two happened: a created WAL is ready to be appended to, while an opened one
+// must be read out with ReadAll first, as with Open.
+// Create removes what an interrupted creation left behind, which would be the
+// WAL being created by a concurrent caller, so concurrent calls for the same
+// dirpath are serialized by an exclusive lock on the "<dirpath>.lock" file,
+// which is left in place. The WAL created by one of them is opened by the
+// others once the lock is released.
+func OpenOrCreate(lg *zap.Logger, dirpath string, metadata []byte, snap walpb.Snapshot, opts ...Option) (w *WAL, created bool, err error) {
+	dirpath = filepath.Clean(dirpath)
+	if err = os.MkdirAll(filepath.Dir(dirpath), fileutil.PrivateDirMode); err != nil {
+		return nil, false, err
+	}
+	l, err := fileutil.LockFile(dirpath+".lock", os.O_WRONLY|os.O_CREATE, fileutil.PrivateFileMode)
+	if err != nil {
+		return nil, false, err
+	}
+	defer l.Close()
+	if !Exist(dirpath) {
+		w, err = Create(lg, dirpath, metadata, opts...)
+		if err != nil {
+			return nil, false, err
+		}
+		return w, true, nil
+	}
+	w, err = Open(lg, dirpath, snap, opts...)
+	return w, false, err
+}
+
 // OpenForRead only opens the wal files for read.
 // Write on a read only wal panics.
-func OpenForRead(lg *zap.Logger, dirpath string, snap walpb.Snapshot) (*WAL, error) {
-	return openAtIndex(lg, dirpath, snap, false)
+func OpenForRead(lg *zap.Logger, dirpath string, snap walpb.Snapshot, opts ...Option) (*WAL, error) {
+	o := newOptions(opts...)
+	return openAtIndex(o.logger(lg), dirpath, snap, false, o)
 }
 
-func openAtIndex(lg *zap.Logger, dirpath string, snap walpb.Snapshot, write bool) (*WAL, error) {
+func openAtIndex(lg *zap.Logger, dirpath string, snap walpb.Snapshot, write bool, opts options) (*WAL, error) {
 	if lg == nil {
 		lg = zap.NewNop()
 	}
+	registerDefaultMetrics()
 	names, nameIndex, err := selectWALFiles(lg, dirpath, snap)
 	if err != nil {
 		return nil, fmt.Errorf("[openAtIndex] selectWALFiles failed: %w", err)
 	}
+	if opts.minSize > 0 {
+		if err = checkMinSize(dirpath, names, opts.minSize); err != nil {
+			return nil, err
+		}
+	}
 
-	rs, ls, closer, err := openWALFiles(lg, dirpath, names, nameIndex, write)
+	rs, ls, closer, err := openWALFiles(lg, dirpath, names, nameIndex, write, opts.sharedLocks)
 	if err != nil {
 		return nil, fmt.Errorf("[openAtIndex] openWALFiles failed: %w", err)
 	}
+	var unmap func()
+	if opts.mmapRead {
+		rs, unmap = mmapFileReaders(lg, rs)
+	}
 
 	// create a WAL ready for reading
 	w := &WAL{
 		lg:        lg,
 		dir:       dirpath,
 		start:     snap,
-		decoder:   NewDecoder(rs...),
+		decoder:   NewDecoderWithConfig(DecoderConfig{ReadAhead: opts.readAhead}, rs...),
 		readClose: closer,
+		unmap:     unmap,
+		segments:  names[nameIndex:],
 		locks:     ls,
+		opts:      opts,
 	}
 
 	if write {
@@ -387,15 +483,35 @@ func openAtIndex(lg *zap.Logger, dirpath string, snap walpb.Snapshot, write bool
 		// WAL can append without dropping the file lock
 		w.readClose = nil
 		if _, _, err := parseWALName(filepath.Base(w.tail().Name())); err != nil {
+			if unmap != nil {
+				unmap()
+			}
 			closer()
 			return nil, fmt.Errorf("[openAtIndex] parseWALName failed: %w", err)
 		}
-		w.fp = newFilePipeline(lg, w.dir, SegmentSizeBytes)
+		w.fp = newFilePipeline(lg, w.dir, opts.segmentSize)
 	}
 
 	return w, nil
 }
 
+// checkMinSize returns ErrWALTooSmall if the named files of dirpath are
+// smaller than minSize bytes in total.
+func checkMinSize(dirpath string, names []string, minSize int64) error {
+	var size int64
+	for _, name := range names {
+		fi, err := os.Stat(filepath.Join(dirpath, name))
+		if err != nil {
+			return err
+		}
+		size += fi.Size()
+	}
+	if size < minSize {
+		return fmt.Errorf("%w: %d bytes in %d files, expected at least %d bytes", ErrWALTooSmall, size, len(names), minSize)
+	}
+	return nil
+}
+
 func selectWALFiles(lg *zap.Logger, dirpath string, snap walpb.Snapshot) ([]string, int, error) {
 	names, err := readWALNames(lg, dirpath)
 	if err != nil {
@@ -414,13 +530,18 @@ func selectWALFiles(lg *zap.Logger, dirpath string, snap walpb.Snapshot) ([]stri
 	return names, nameIndex, nil
 }
 
-func openWALFiles(lg *zap.Logger, dirpath string, names []string, nameIndex int, write bool) ([]fileutil.FileReader, []*fileutil.LockedFile, func() error, error) {
+// openWALFiles opens the WAL files starting from names[nameIndex]. Files opened
+// for write are locked exclusively and returned as locks. Files opened for read
+// are not locked unless sharedLocks is set, in which case a shared lock is taken
+// on those that aren't locked exclusively by a writer. Shared locks are released
+// by the returned closer.
+func openWALFiles(lg *zap.Logger, dirpath string, names []string, nameIndex int, write bool, sharedLocks bool) ([]fileutil.FileReader, []*fileutil.LockedFile, func() error, error) {
 	rcs := make([]io.ReadCloser, 0)
 	rs := make([]fileutil.FileReader, 0)
 	ls := make([]*fileutil.LockedFile, 0)
 	for _, name := range names[nameIndex:] {
 		p := filepath.Join(dirpath, name)
-		var f *os.File
+		var fileReader fileutil.FileReader
 		if write {
 			l, err := fileutil.TryLockFile(p, os.O_RDWR, fileutil.PrivateFileMode)
 			if err != nil {
@@ -429,18 +550,21 @@ func openWALFiles(lg *zap.Logger, dirpath string, names []string, nameIndex int,
 			}
 			ls = append(ls, l)
 			rcs = append(rcs, l)
-			f = l.File
+			fileReader = fileutil.NewFileReader(l.File)
 		} else {
-			rf, err := os.OpenFile(p, os.O_RDONLY, fileutil.PrivateFileMode)
+			rf, err := openReadOnly(lg, p, sharedLocks)
 			if err != nil {
 				closeAll(lg, rcs...)
 				return nil, nil, nil, fmt.Errorf("[openWALFiles] os.OpenFile failed (%q): %w", p, err)
 			}
 			ls = append(ls, nil)
 			rcs = append(rcs, rf)
-			f = rf
+			// only read paths accept compressed files, they can't be appended to
+			if fileReader, err = newReadFileReader(rf); err != nil {
+				closeAll(lg, rcs...)
+				return nil, nil, nil, fmt.Errorf("[openWALFiles] failed to read %q: %w", p, err)
+			}
 		}
-		fileReader := fileutil.NewFileReader(f)
 		rs = append(rs, fileReader)
 	}
 
@@ -449,6 +573,27 @@ func openWALFiles(lg *zap.Logger, dirpath string, names []string, nameIndex int,
 	return rs, ls, closer, nil
 }
 
+// openReadOnly opens the WAL file at p for read. With sharedLocks it holds a
+// shared lock on the file, so that it can't be purged while being read, unless
+// a writer holds the file locked or the platform doesn't support shared locks.
+// In both cases the file is read without a lock.
+func openReadOnly(lg *zap.Logger, p string, sharedLocks bool) (*os.File, error) {
+	if sharedLocks {
+		l, err := fileutil.TryRLockFile(p, os.O_RDONLY, fileutil.PrivateFileMode)
+		switch {
+		case err == nil:
+			return l.File, nil
+		case errors.Is(err, fileutil.ErrLocked):
+			lg.Debug("reading WAL file locked by a writer without a lock", zap.String("path", p))
+		case errors.Is(err, fileutil.ErrSharedLockUnsupported):
+			lg.Debug("shared locks are not supported, reading WAL file without a lock", zap.String("path", p))
+		default:
+			return nil, err
+		}
+	}
+	return os.OpenFile(p, os.O_RDONLY, fileutil.PrivateFileMode)
+}
+
 // ReadAll reads out records of the current WAL.
 // If opened in write mode, it must read out all records until EOF. Or an error
 // will be returned.
@@ -467,34 +612,100 @@ func openWALFiles(lg *zap.Logger, dirpath string, names []string, nameIndex int,
 // ReadAll may return uncommitted yet entries, that are subject to be overridden.
 // Do not apply entries that have index > state.commit, as they are subject to change.
 func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.Entry, err error) {
+	metadata, state, ents, _, err = w.readAll(0, nil)
+	return metadata, state, ents, err
+}
+
+// Iterate reads out records of the current WAL like ReadAll, but passes the
+// entries to fn one at a time instead of returning them, so that a WAL of any
+// size can be read with bounded memory. The entries are passed in the order
+// they were written, so like in ReadAll an entry replaces the previously
+// passed entries with the same or a higher index, written in an older term
+// and never committed. If fn returns an error, reading stops and the error is
+// returned, the WAL staying in read mode as when ReadAll fails.
+func (w *WAL) Iterate(fn func(ent raftpb.Entry) error) (metadata []byte, state raftpb.HardState, err error) {
+	metadata, state, _, _, err = w.readAll(0, fn)
+	return metadata, state, err
+}
+
+// ReadAllLimit reads out records of the current WAL like ReadAll, but stops
+// before decoding more than n entries, e.g. to inspect a large WAL with
+// bounded memory. more reports whether it stopped before the end of the WAL.
+// In that case, the metadata and hard state returned are the last ones read
+// before the limit, not the latest of the WAL, the snapshot is not checked,
+// and the WAL stays in read mode. The next entries can be read by opening the
+// WAL again at the same snapshot and seeking to the position returned by
+// ReadPosition. Further reads of this WAL fail with ErrReadLimited.
+//
+// A limit of 0 or less reads all entries, as ReadAll does.
+func (w *WAL) ReadAllLimit(n int) (metadata []byte, state raftpb.HardState, ents []raftpb.Entry, more bool, err error) {
+	return w.readAll(n, nil)
+}
+
+// readAll reads out the records of the WAL, passing the entries to onEntry
+// if set, else returning them.
+func (w *WAL) readAll(limit int, onEntry func(raftpb.Entry) error) (metadata []byte, state raftpb.HardState, ents []raftpb.Entry, more bool, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	rec := &walpb.Record{}
 
 	if w.decoder == nil {
-		return nil, state, nil, ErrDecoderNotFound
+		return nil, state, nil, false, ErrDecoderNotFound
+	}
+	if w.limited != nil {
+		return nil, state, nil, false, ErrReadLimited
 	}
 	decoder := w.decoder
 
-	var match bool
+	// entries up to startIndex are not returned
+	startIndex := w.start.Index
+	match := w.resume != nil
+	if match && w.resume.Index > startIndex {
+		startIndex = w.resume.Index
+	}
+	// pos is the position preceding the record being decoded, where a
+	// limited read stops
+	var pos ReadPosition
+	var decoded int
+	// read is the number of entries read past startIndex, len(ents) when
+	// they are returned
+	var read uint64
+	if limit > 0 {
+		pos = ReadPosition{Offset: decoder.Offset(), CRC: decoder.LastCRC(), Index: w.enti}
+	}
 	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
 		switch rec.Type {
 		case EntryType:
 			e := MustUnmarshalEntry(rec.Data)
-			// 0 <= e.Index-w.start.Index - 1 < len(ents)
-			if e.Index > w.start.Index {
+			if limit > 0 && e.Index > startIndex {
+				if decoded == limit {
+					w.limited = &pos
+					return metadata, state, ents, true, nil
+				}
+				decoded++
+			}
+			// 0 <= e.Index-startIndex - 1 < len(ents)
+			if e.Index > startIndex {
 				// prevent "panic: runtime error: slice bounds out of range [:13038096702221461992] with capacity 0"
-				offset := e.Index - w.start.Index - 1
-				if offset > uint64(len(ents)) {
+				offset := e.Index - startIndex - 1
+				if offset > read {
 					// return error before append call causes runtime panic.
 					// We still return the continuous WAL entries that have already been read.
 					// Refer to https://github.com/etcd-io/etcd/pull/19038#issuecomment-2557414292.
-					return nil, state, ents, fmt.Errorf("%w, snapshot[Index: %d, Term: %d], current entry[Index: %d, Term: %d], len(ents): %d",
-						ErrSliceOutOfRange, w.start.Index, w.start.Term, e.Index, e.Term, len(ents))
+					return nil, state, ents, false, fmt.Errorf("%w, snapshot[Index: %d, Term: %d], current entry[Index: %d, Term: %d], len(ents): %d",
+						ErrSliceOutOfRange, w.start.Index, w.start.Term, e.Index, e.Term, read)
 				}
-				// The line below is potentially overriding some 'uncommitted' entries.
-				ents = append(ents[:offset], e)
+				if onEntry != nil {
+					if err = onEntry(e); err != nil {
+						w.enti = e.Index
+						return metadata, state, nil, false, err
+					}
+				} else {
+					// The line below is potentially overriding some 'uncommitted' entries.
+					ents = append(ents[:offset], e)
+				}
+				read = offset + 1
 			}
 			w.enti = e.Index
 
@@ -504,7 +715,7 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 		case MetadataType:
 			if metadata != nil && !bytes.Equal(metadata, rec.Data) {
 				state.Reset()
-				return nil, state, nil, ErrMetadataConflict
+				return nil, state, nil, false, ErrMetadataConflict
 			}
 			metadata = rec.Data
 
@@ -514,7 +725,7 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 			// do no need to match 0 crc, since the decoder is a new one at this case.
 			if crc != 0 && rec.Validate(crc) != nil {
 				state.Reset()
-				return nil, state, nil, ErrCRCMismatch
+				return nil, state, nil, false, ErrCRCMismatch
 			}
 			decoder.UpdateCRC(rec.Crc)
 
@@ -524,14 +735,23 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 			if snap.Index == w.start.Index {
 				if snap.Term != w.start.Term {
 					state.Reset()
-					return nil, state, nil, ErrSnapshotMismatch
+					return nil, state, nil, false, ErrSnapshotMismatch
 				}
 				match = true
 			}
 
+		case FooterType:
+			// covered by the crc chain like any other record
+
+		case FormatType:
+			// checked by the decoder
+
 		default:
 			state.Reset()
-			return nil, state, nil, fmt.Errorf("unexpected block type %d", rec.Type)
+			return nil, state, nil, false, fmt.Errorf("unexpected block type %d", rec.Type)
+		}
+		if limit > 0 {
+			pos = ReadPosition{Offset: decoder.Offset(), CRC: decoder.LastCRC(), Index: w.enti}
 		}
 	}
 
@@ -542,13 +762,13 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 		// `io.ErrUnexpectedEOF` might be returned.
 		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
 			state.Reset()
-			return nil, state, nil, err
+			return nil, state, nil, false, err
 		}
 	default:
 		// We must read all the entries if WAL is opened in write mode.
 		if !errors.Is(err, io.EOF) {
 			state.Reset()
-			return nil, state, nil, err
+			return nil, state, nil, false, err
 		}
 		// decodeRecord() will return io.EOF if it detects a zero record,
 		// but this zero record may be followed by non-zero records from
@@ -556,11 +776,28 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 		// not all, will cause CRC errors on WAL open. Since the records
 		// were never fully synced to disk in the first place, it's safe
 		// to zero them out to avoid any CRC errors from new writes.
-		if _, err = w.tail().Seek(w.decoder.LastOffset(), io.SeekStart); err != nil {
-			return nil, state, nil, err
+		off := w.decoder.LastOffset()
+		if _, err = w.tail().Seek(off, io.SeekStart); err != nil {
+			return nil, state, nil, false, err
+		}
+		if discarded, _, torn := scanTornTail(w.tail()); torn {
+			if w.opts.noAutoRepair {
+				state.Reset()
+				return nil, state, nil, false, fmt.Errorf("%w: torn write in %q after offset %d, %d entries lost",
+					io.ErrUnexpectedEOF, w.tail().Name(), off, discarded)
+			}
+			w.lg.Warn(
+				"truncating torn write at the end of WAL",
+				zap.String("path", w.tail().Name()),
+				zap.Int64("offset", off),
+				zap.Int("discarded-entries", discarded),
+			)
+		}
+		if _, err = w.tail().Seek(off, io.SeekStart); err != nil {
+			return nil, state, nil, false, err
 		}
 		if err = fileutil.ZeroToEnd(w.tail().File); err != nil {
-			return nil, state, nil, err
+			return nil, state, nil, false, err
 		}
 	}
 
@@ -570,44 +807,217 @@ func (w *WAL) ReadAll() (metadata []byte, state raftpb.HardState, ents []raftpb.
 	}
 
 	// close decoder, disable reading
+	if w.unmap != nil {
+		w.unmap()
+		w.unmap = nil
+	}
 	if w.readClose != nil {
 		w.readClose()
 		w.readClose = nil
 	}
 	w.start = walpb.Snapshot{}
+	w.resume = nil
 
 	w.metadata = metadata
 
 	if w.tail() != nil {
-		// create encoder (chain crc with the decoder), enable appending
-		w.encoder, err = newFileEncoder(w.tail().File, w.decoder.LastCRC())
-		if err != nil {
-			return nil, state, nil, err
+		// create encoder (chain crc and sequence with the decoder), enable appending
+		if err = w.setEncoder(w.tail().File, w.decoder.LastCRC(), lastSequence(w.decoder)); err != nil {
+			return nil, state, nil, false, err
+		}
+		if err = w.sequenceRecords(lastFormat(w.decoder) == formatSequenced); err != nil {
+			return nil, state, nil, false, err
 		}
 	}
 	w.decoder = nil
 
-	return metadata, state, ents, err
+	return metadata, state, ents, false, err
+}
+
+// Segments returns the names of the WAL files selected when the WAL was
+// opened, in the order they are read, from the one holding the start snapshot
+// to the tail. For a created WAL, it is the initial file. It isn't updated by
+// later cuts nor lock releases.
+func (w *WAL) Segments() []string {
+	return slices.Clone(w.segments)
+}
+
+// ReadPosition is a point in the records of a WAL opened at a given snapshot,
+// from which reading can be resumed by a WAL opened later at the same snapshot.
+// It stays valid as long as none of the WAL files it spans are purged.
+type ReadPosition struct {
+	// Offset is the byte offset following the last record read, counted from
+	// the beginning of the first file of the WAL selected by the snapshot.
+	Offset int64
+	// CRC is the running crc of the records up to Offset.
+	CRC uint32
+	// Index is the index of the last entry read before Offset.
+	Index uint64
+}
+
+// ReadPosition returns the position following the last record read from the
+// WAL. It is meant for reads that stopped early, e.g. ReadAll returning an
+// error or ReadAllLimit reaching its limit, in which case it is the position
+// of the first entry not returned, and must be called before the WAL is ready
+// for appending.
+func (w *WAL) ReadPosition() (ReadPosition, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.decoder == nil {
+		return ReadPosition{}, ErrDecoderNotFound
+	}
+	if w.limited != nil {
+		return *w.limited, nil
+	}
+	return ReadPosition{Offset: w.decoder.Offset(), CRC: w.decoder.LastCRC(), Index: w.enti}, nil
+}
+
+// Seek moves a just opened WAL to a position returned by ReadPosition of a
+// WAL opened at the same snapshot, so that the following ReadAll only returns
+// the entries past that position. The snapshot is considered found as it was
+// read before the position was captured. The metadata and hard state read
+// before the position aren't part of it, so only a WAL opened by OpenForRead
+// can be moved; others fail with ErrSeekWritable.
+func (w *WAL) Seek(pos ReadPosition) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fp != nil {
+		return ErrSeekWritable
+	}
+	d, ok := w.decoder.(*decoder)
+	if !ok {
+		return ErrDecoderNotFound
+	}
+	if err := d.seek(pos.Offset); err != nil {
+		return err
+	}
+	d.UpdateCRC(pos.CRC)
+	w.enti = pos.Index
+	w.resume = &pos
+	return nil
 }
 
 // ValidSnapshotEntries returns all the valid snapshot entries in the wal logs in the given directory.
 // Snapshot entries are valid if their index is less than or equal to the most recent committed hardstate.
+// Callers invoking it repeatedly on the same WAL can use a SnapshotCache.
 func ValidSnapshotEntries(lg *zap.Logger, walDir string) ([]walpb.Snapshot, error) {
+	entries, err := SnapshotIndexes(lg, walDir)
+	if err != nil {
+		return nil, err
+	}
 	var snaps []walpb.Snapshot
+	for _, e := range entries {
+		if e.Valid {
+			snaps = append(snaps, e.Snapshot)
+		}
+	}
+	return snaps, nil
+}
+
+// SnapshotEntry is a snapshot record of the WAL.
+type SnapshotEntry struct {
+	walpb.Snapshot
+	// Valid is false for orphaned records, of snapshots newer than the last
+	// committed index, which ValidSnapshotEntries skips.
+	Valid bool
+}
+
+// SnapshotIndexes returns all snapshot records in the WAL, including the
+// orphaned ones, in the order they were written. It allows cross-checking the
+// WAL with the snapshot files, finding snap files without a WAL record or
+// records without a snap file.
+func SnapshotIndexes(lg *zap.Logger, walDir string) ([]SnapshotEntry, error) {
+	var snaps []SnapshotEntry
 	var state raftpb.HardState
-	var err error
+	err := decodeSnapshotRecords(lg, walDir, func(snap walpb.Snapshot) error {
+		snaps = append(snaps, SnapshotEntry{Snapshot: snap})
+		return nil
+	}, func(st raftpb.HardState) error {
+		state = st
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	// snaps that are newer than the committed hardstate are orphaned
+	for i := range snaps {
+		snaps[i].Valid = snaps[i].Index <= state.Commit
+	}
+	return snaps, nil
+}
+
+// EncodeValidSnapshotEntries is a streaming variant of ValidSnapshotEntries,
+// writing each valid snapshot entry to w as a JSON object on its own line,
+// with its index, term and conf state voters. A snapshot record is written
+// once a later hardstate commits its index, so only the snapshots not yet
+// committed are held in memory, not all the snapshots of the WAL.
+func EncodeValidSnapshotEntries(lg *zap.Logger, walDir string, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	encode := func(snap walpb.Snapshot) error {
+		voters := []uint64{}
+		if snap.ConfState != nil {
+			voters = append(voters, snap.ConfState.Voters...)
+		}
+		return enc.Encode(snapshotJSON{Index: snap.Index, Term: snap.Term, Voters: voters})
+	}
+	var pending []walpb.Snapshot
+	// flush writes the pending snapshots up to the first one not committed,
+	// keeping the order they were recorded in.
+	flush := func(commit uint64) error {
+		for len(pending) > 0 && pending[0].Index <= commit {
+			if err := encode(pending[0]); err != nil {
+				return err
+			}
+			pending = pending[1:]
+		}
+		return nil
+	}
+	var commit uint64
+	err := decodeSnapshotRecords(lg, walDir, func(snap walpb.Snapshot) error {
+		pending = append(pending, snap)
+		return flush(commit)
+	}, func(st raftpb.HardState) error {
+		commit = st.Commit
+		return flush(commit)
+	})
+	if err != nil {
+		return err
+	}
+	// Like in SnapshotIndexes, the last hardstate decides which of the
+	// remaining snapshots are valid.
+	for _, snap := range pending {
+		if snap.Index > commit {
+			continue
+		}
+		if err = encode(snap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type snapshotJSON struct {
+	Index  uint64   `json:"index"`
+	Term   uint64   `json:"term"`
+	Voters []uint64 `json:"voters"`
+}
+
+// decodeSnapshotRecords calls onSnap and onState for the snapshot and state
+// records of the WAL in walDir, in the order they were written, stopping at
+// the first error returned by either of them.
+func decodeSnapshotRecords(lg *zap.Logger, walDir string, onSnap func(walpb.Snapshot) error, onState func(raftpb.HardState) error) error {
 	rec := &walpb.Record{}
 	names, err := readWALNames(lg, walDir)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// open wal files in read mode, so that there is no conflict
 	// when the same WAL is opened elsewhere in write mode
-	rs, _, closer, err := openWALFiles(lg, walDir, names, 0, false)
+	rs, _, closer, err := openWALFiles(lg, walDir, names, 0, false, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() {
 		if closer != nil {
@@ -623,15 +1033,19 @@ func ValidSnapshotEntries(lg *zap.Logger, walDir string) ([]walpb.Snapshot, erro
 		case SnapshotType:
 			var loadedSnap walpb.Snapshot
 			pbutil.MustUnmarshal(&loadedSnap, rec.Data)
-			snaps = append(snaps, loadedSnap)
+			if err = onSnap(loadedSnap); err != nil {
+				return err
+			}
 		case StateType:
-			state = MustUnmarshalState(rec.Data)
+			if err = onState(MustUnmarshalState(rec.Data)); err != nil {
+				return err
+			}
 		case CrcType:
 			crc := decoder.LastCRC()
 			// current crc of decoder must match the crc of the record.
 			// do no need to match 0 crc, since the decoder is a new one at this case.
 			if crc != 0 && rec.Validate(crc) != nil {
-				return nil, ErrCRCMismatch
+				return ErrCRCMismatch
 			}
 			decoder.UpdateCRC(rec.Crc)
 		}
@@ -639,19 +1053,26 @@ func ValidSnapshotEntries(lg *zap.Logger, walDir string) ([]walpb.Snapshot, erro
 	// We do not have to read out all the WAL entries
 	// as the decoder is opened in read mode.
 	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
-		return nil, err
+		return err
 	}
+	return nil
+}
 
-	// filter out any snaps that are newer than the committed hardstate
-	n := 0
-	for _, s := range snaps {
-		if s.Index <= state.Commit {
-			snaps[n] = s
-			n++
+// HasSnapshot reports whether the WAL holds a valid record of the snapshot
+// with the index and term of snap. Unlike Verify, it only decodes the records
+// needed to find snapshots, which is much faster to confirm that a snapshot
+// was recorded.
+func HasSnapshot(lg *zap.Logger, walDir string, snap walpb.Snapshot) (bool, error) {
+	entries, err := SnapshotIndexes(lg, walDir)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if e.Valid && e.Index == snap.Index && e.Term == snap.Term {
+			return true, nil
 		}
 	}
-	snaps = snaps[:n:n]
-	return snaps, nil
+	return false, nil
 }
 
 // Verify reads through the given WAL and verifies that it is not corrupted.
@@ -661,7 +1082,20 @@ func ValidSnapshotEntries(lg *zap.Logger, walDir string) ([]walpb.Snapshot, erro
 // If it cannot read out the expected snap, it will return ErrSnapshotNotFound.
 // If the loaded snap doesn't match with the expected one, it will
 // return error ErrSnapshotMismatch.
-func Verify(lg *zap.Logger, walDir string, snap walpb.Snapshot) (*raftpb.HardState, error) {
+// With WithVerifyWatermark, only the records past the watermark are verified.
+// With WithReadRepair, corrupted finalized segments that no WAL open for
+// writing holds locked are salvaged.
+func Verify(lg *zap.Logger, walDir string, snap walpb.Snapshot, opts ...Option) (*raftpb.HardState, error) {
+	o := newOptions(opts...)
+	lg = o.logger(lg)
+	state, err := verify(lg, walDir, snap, o)
+	if err != nil && o.readRepair {
+		salvageCorruptedSegments(lg, walDir)
+	}
+	return state, err
+}
+
+func verify(lg *zap.Logger, walDir string, snap walpb.Snapshot, o options) (*raftpb.HardState, error) {
 	var metadata []byte
 	var err error
 	var match bool
@@ -669,17 +1103,24 @@ func Verify(lg *zap.Logger, walDir string, snap walpb.Snapshot) (*raftpb.HardSta
 
 	rec := &walpb.Record{}
 
-	if lg == nil {
-		lg = zap.NewNop()
-	}
 	names, nameIndex, err := selectWALFiles(lg, walDir, snap)
 	if err != nil {
 		return nil, err
 	}
 
+	var wm *verifyWatermark
+	startIndex := nameIndex
+	if o.watermark != "" {
+		wm = loadVerifyWatermark(lg, o.watermark, walDir, snap, names[nameIndex:])
+	}
+	if wm != nil {
+		startIndex = nameIndex + wm.segmentIndex(names[nameIndex:])
+		metadata, state, match = wm.Metadata, wm.State, wm.SnapshotFound
+	}
+
 	// open wal files in read mode, so that there is no conflict
 	// when the same WAL is opened elsewhere in write mode
-	rs, _, closer, err := openWALFiles(lg, walDir, names, nameIndex, false)
+	rs, _, closer, err := openWALFiles(lg, walDir, names, startIndex, false, false)
 	if err != nil {
 		return nil, err
 	}
@@ -690,7 +1131,34 @@ func Verify(lg *zap.Logger, walDir string, snap walpb.Snapshot) (*raftpb.HardSta
 	}()
 
 	// create a new decoder from the readers on the WAL files
-	decoder := NewDecoder(rs...)
+	var decoder Decoder
+	if wm != nil {
+		if decoder, err = newDecoderAt(wm.Offset, wm.CRC, rs...); err != nil {
+			return nil, err
+		}
+	} else {
+		decoder = NewDecoder(rs...)
+	}
+
+	// Once the snapshot is found, finalized segments ending with a footer are
+	// skipped rather than decoded, from the one being decoded on, the tail
+	// heading with the last state.
+	skipped := false
+	skipFootered := func() error {
+		skipped = true
+		current := len(rs) - remainingFiles(decoder)
+		next, prevCrc, err := skipFooteredSegments(walDir, names[startIndex:], current)
+		if err != nil || next == current {
+			return err
+		}
+		decoder, err = newDecoderAt(0, prevCrc, rs[next:]...)
+		return err
+	}
+	if match {
+		if err = skipFootered(); err != nil {
+			return nil, err
+		}
+	}
 
 	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
 		switch rec.Type {
@@ -716,9 +1184,14 @@ func Verify(lg *zap.Logger, walDir string, snap walpb.Snapshot) (*raftpb.HardSta
 				}
 				match = true
 			}
+			if match && !skipped {
+				if err = skipFootered(); err != nil {
+					return nil, err
+				}
+			}
 		// We ignore all entry and state type records as these
 		// are not necessary for validating the WAL contents
-		case EntryType:
+		case EntryType, FooterType, FormatType:
 		case StateType:
 			pbutil.MustUnmarshal(&state, rec.Data)
 		default:
@@ -736,13 +1209,140 @@ func Verify(lg *zap.Logger, walDir string, snap walpb.Snapshot) (*raftpb.HardSta
 		return nil, ErrSnapshotNotFound
 	}
 
+	// A torn tail can still be completed, only a clean end is recorded.
+	if o.watermark != "" && errors.Is(err, io.EOF) {
+		last := names[len(names)-1]
+		err = saveVerifyWatermark(o.watermark, walDir, names[nameIndex:], verifyWatermark{
+			SnapshotIndex: snap.Index,
+			SnapshotTerm:  snap.Term,
+			Segment:       last,
+			Offset:        decoder.LastOffset(),
+			CRC:           decoder.LastCRC(),
+			Metadata:      metadata,
+			State:         state,
+			SnapshotFound: match,
+		})
+		if err != nil {
+			lg.Warn("failed to save verify watermark", zap.String("path", o.watermark), zap.Error(err))
+		}
+	}
+
 	return &state, nil
 }
 
+// VerifyTermMonotonicity reads through the hard states recorded in the given
+// WAL, starting from the file containing snap, and verifies that their term
+// never decreases. A regressing term means the WAL is corrupted or was spliced
+// from different logs; ErrTermRegression is returned along with the offending
+// pair of hard states.
+// Like Verify, it does not conflict with any open WAL.
+func VerifyTermMonotonicity(lg *zap.Logger, walDir string, snap walpb.Snapshot) error {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	names, nameIndex, err := selectWALFiles(lg, walDir, snap)
+	if err != nil {
+		return err
+	}
+
+	// open wal files in read mode, so that there is no conflict
+	// when the same WAL is opened elsewhere in write mode
+	rs, _, closer, err := openWALFiles(lg, walDir, names, nameIndex, false, false)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	decoder := NewDecoder(rs...)
+	rec := &walpb.Record{}
+	var prev *raftpb.HardState
+	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
+		switch rec.Type {
+		case CrcType:
+			crc := decoder.LastCRC()
+			// current crc of decoder must match the crc of the record.
+			// do no need to match 0 crc, since the decoder is a new one at this case.
+			if crc != 0 && rec.Validate(crc) != nil {
+				return ErrCRCMismatch
+			}
+			decoder.UpdateCRC(rec.Crc)
+		case StateType:
+			state := MustUnmarshalState(rec.Data)
+			if prev != nil && state.Term < prev.Term {
+				return fmt.Errorf("%w: hard state with term %d and commit %d followed by hard state with term %d and commit %d",
+					ErrTermRegression, prev.Term, prev.Commit, state.Term, state.Commit)
+			}
+			prev = &state
+		}
+	}
+	// We do not have to read out all the WAL entries
+	// as the decoder is opened in read mode.
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	return nil
+}
+
+// ReadAllRecords returns, in order, every record of the given WAL starting
+// from the file containing snap, including the crc, metadata and snapshot
+// records that ReadAll consumes. The crc chain is verified on the way.
+// Like Verify, it does not conflict with any open WAL.
+func ReadAllRecords(lg *zap.Logger, walDir string, snap walpb.Snapshot) ([]walpb.Record, error) {
+	if lg == nil {
+		lg = zap.NewNop()
+	}
+	names, nameIndex, err := selectWALFiles(lg, walDir, snap)
+	if err != nil {
+		return nil, err
+	}
+
+	// open wal files in read mode, so that there is no conflict
+	// when the same WAL is opened elsewhere in write mode
+	rs, _, closer, err := openWALFiles(lg, walDir, names, nameIndex, false, false)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	decoder := NewDecoder(rs...)
+	var recs []walpb.Record
+	for {
+		var rec walpb.Record
+		if err = decoder.Decode(&rec); err != nil {
+			break
+		}
+		if rec.Type == CrcType {
+			crc := decoder.LastCRC()
+			// current crc of decoder must match the crc of the record.
+			// do no need to match 0 crc, since the decoder is a new one at this case.
+			if crc != 0 && rec.Validate(crc) != nil {
+				return nil, ErrCRCMismatch
+			}
+			decoder.UpdateCRC(rec.Crc)
+		}
+		recs = append(recs, rec)
+	}
+	// We do not have to read out all the WAL entries
+	// as the decoder is opened in read mode.
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return recs, nil
+}
+
 // cut closes current file written and creates a new one ready to append.
 // cut first creates a temp wal file and writes necessary headers into it.
 // Then cut atomically rename temp wal file to a wal file.
 func (w *WAL) cut() error {
+	if err := w.writeErr(); err != nil {
+		return err
+	}
+	finalized := filepath.Base(w.tail().Name())
+	if w.opts.segmentFooters {
+		if err := w.saveFooter(); err != nil {
+			return err
+		}
+	}
 	// close old wal file; truncate to avoid wasting space if an early cut
 	off, serr := w.tail().Seek(0, io.SeekCurrent)
 	if serr != nil {
@@ -768,8 +1368,7 @@ func (w *WAL) cut() error {
 	// update writer and save the previous crc
 	w.locks = append(w.locks, newTail)
 	prevCrc := w.encoder.crc.Sum32()
-	w.encoder, err = newFileEncoder(w.tail().File, prevCrc)
-	if err != nil {
+	if err = w.setEncoder(w.tail().File, prevCrc, w.encoder.seq); err != nil {
 		return err
 	}
 
@@ -777,6 +1376,10 @@ func (w *WAL) cut() error {
 		return err
 	}
 
+	if err = w.sequenceRecords(false); err != nil {
+		return err
+	}
+
 	if err = w.encoder.encode(&walpb.Record{Type: MetadataType, Data: w.metadata}); err != nil {
 		return err
 	}
@@ -817,15 +1420,88 @@ func (w *WAL) cut() error {
 	w.locks[len(w.locks)-1] = newTail
 
 	prevCrc = w.encoder.crc.Sum32()
-	w.encoder, err = newFileEncoder(w.tail().File, prevCrc)
-	if err != nil {
+	if err = w.setEncoder(w.tail().File, prevCrc, w.encoder.seq); err != nil {
+		return err
+	}
+	if err = w.sequenceRecords(true); err != nil {
 		return err
 	}
 
+	walCuts.Inc()
 	w.lg.Info("created a new WAL segment", zap.String("path", fpath))
+	if w.opts.onCut != nil {
+		w.opts.onCut(finalized)
+	}
+	w.checkTotalSize()
+	return nil
+}
+
+// setEncoder makes the WAL append to f, chaining the crc and the record
+// sequence number with the given ones.
+func (w *WAL) setEncoder(f *os.File, prevCrc uint32, seq uint64) error {
+	e, err := newFileEncoder(f, prevCrc)
+	if err != nil {
+		return err
+	}
+	e.seq = seq
+	e.timed = w.opts.encodeTiming
+	e.compressed = w.opts.compression
+	w.encoder = e
+	w.flushed = e.offset
 	return nil
 }
 
+// checkTotalSize calls the purge callback given by WithMaxTotalSize with the
+// oldest released WAL files if the WAL files exceed the configured size.
+func (w *WAL) checkTotalSize() {
+	if w.opts.maxTotalSize <= 0 || w.opts.purge == nil {
+		return
+	}
+	names, err := readWALNames(w.lg, w.dir)
+	if err != nil {
+		w.lg.Warn("failed to list WAL files", zap.String("dir-path", w.dir), zap.Error(err))
+		return
+	}
+	locked := make(map[string]struct{}, len(w.locks))
+	for _, l := range w.locks {
+		if l != nil {
+			locked[filepath.Base(l.Name())] = struct{}{}
+		}
+	}
+	sizes := make([]int64, len(names))
+	var total int64
+	for i, name := range names {
+		fi, err := os.Stat(filepath.Join(w.dir, name))
+		if err != nil {
+			w.lg.Warn("failed to stat WAL file", zap.String("path", name), zap.Error(err))
+			return
+		}
+		sizes[i] = fi.Size()
+		total += fi.Size()
+	}
+	var freed []string
+	for i, name := range names {
+		if total <= w.opts.maxTotalSize {
+			break
+		}
+		if _, ok := locked[name]; ok {
+			// files are released in order, so newer files are locked too
+			break
+		}
+		freed = append(freed, filepath.Join(w.dir, name))
+		total -= sizes[i]
+	}
+	if len(freed) == 0 {
+		return
+	}
+	w.lg.Info(
+		"WAL files exceed maximum total size",
+		zap.Int64("max-total-bytes", w.opts.maxTotalSize),
+		zap.Strings("releasable-paths", freed),
+	)
+	w.opts.purge(freed)
+}
+
 func (w *WAL) sync() error {
 	if w.encoder != nil {
 		if err := w.encoder.flush(); err != nil {
@@ -838,7 +1514,12 @@ func (w *WAL) sync() error {
 	}
 
 	start := time.Now()
-	err := fileutil.Fdatasync(w.tail().File)
+	var err error
+	if w.opts.syncer != nil {
+		err = w.opts.syncer.Sync(w.tail().File)
+	} else {
+		err = fileutil.Fdatasync(w.tail().File)
+	}
 
 	took := time.Since(start)
 	if took > warnSyncDuration {
@@ -850,6 +1531,34 @@ func (w *WAL) sync() error {
 	}
 	walFsyncSec.Observe(took.Seconds())
 
+	if err == nil && w.encoder != nil {
+		w.flushed = w.encoder.offset + w.encoder.encoded
+	}
+	return err
+}
+
+// writeErr returns the error writes to the WAL fail with, if it was closed or
+// failed.
+func (w *WAL) writeErr() error {
+	if w.closed {
+		return ErrWALClosed
+	}
+	if w.failed != nil {
+		return fmt.Errorf("%w: %w", ErrWALFailed, w.failed)
+	}
+	return nil
+}
+
+// fail marks the WAL failed after writing to the tail failed with err. A frame
+// may have been partially written, and the crc and the buffered records of
+// the encoder no longer match the tail, so the tail is truncated back to the
+// end of the records last synced, and further writes fail with ErrWALFailed.
+// The WAL has to be closed and opened again to recover.
+func (w *WAL) fail(err error) error {
+	w.failed = err
+	if terr := w.tail().Truncate(w.flushed); terr != nil {
+		w.lg.Warn("failed to truncate WAL tail after write error", zap.String("path", w.tail().Name()), zap.Int64("offset", w.flushed), zap.Error(terr))
+	}
 	return err
 }
 
@@ -904,17 +1613,181 @@ func (w *WAL) ReleaseLockTo(index uint64) error {
 	return nil
 }
 
+// TruncateToSnapshot removes the segments holding only records before the
+// given snapshot, to reclaim disk space once the snapshot is saved, keeping
+// the segment the snapshot is recorded in. The remaining segments each start
+// with the crc and metadata records, so the WAL can still be opened at the
+// snapshot, or any later one. Nothing is removed unless the snapshot is
+// recorded in the WAL as valid, ErrSnapshotNotFound is returned otherwise.
+// Segments locked by another process, e.g. being read with WithSharedLocks,
+// are kept along with all the segments after them.
+func (w *WAL) TruncateToSnapshot(snap walpb.Snapshot) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.writeErr(); err != nil {
+		return err
+	}
+	if w.tail() == nil || w.encoder == nil {
+		return ErrNotWritable
+	}
+	// the snapshot record and the segments keeping the WAL openable have to
+	// be durable before the segments before them are removed
+	if err := w.sync(); err != nil {
+		return err
+	}
+	if err := fileutil.Fsync(w.dirFile); err != nil {
+		return err
+	}
+	found, err := HasSnapshot(w.lg, w.dir, snap)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSnapshotNotFound
+	}
+	names, err := readWALNames(w.lg, w.dir)
+	if err != nil {
+		return err
+	}
+	nameIndex, ok := searchIndex(w.lg, names, snap.Index)
+	if !ok {
+		return ErrFileNotFound
+	}
+
+	held := make(map[string]*fileutil.LockedFile, len(w.locks))
+	for _, l := range w.locks {
+		if l != nil {
+			held[filepath.Base(l.Name())] = l
+		}
+	}
+	removed := map[string]bool{}
+	defer func() {
+		locks := w.locks[:0]
+		for _, l := range w.locks {
+			if l == nil || !removed[filepath.Base(l.Name())] {
+				locks = append(locks, l)
+			}
+		}
+		w.locks = locks
+	}()
+	for _, name := range names[:nameIndex] {
+		p := filepath.Join(w.dir, name)
+		l, ok := held[name]
+		if !ok {
+			if l, err = fileutil.TryLockFile(p, os.O_WRONLY, fileutil.PrivateFileMode); err != nil {
+				w.lg.Warn("failed to lock WAL file, keeping it", zap.String("path", p), zap.Error(err))
+				break
+			}
+		}
+		if err = os.Remove(p); err != nil {
+			l.Close()
+			return err
+		}
+		removed[name] = true
+		if err = l.Close(); err != nil {
+			w.lg.Warn("failed to unlock removed WAL file", zap.String("path", p), zap.Error(err))
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err = fileutil.Fsync(w.dirFile); err != nil {
+		return err
+	}
+	w.lg.Info("truncated WAL to snapshot", zap.Uint64("snapshot-index", snap.Index), zap.Int("removed-segments", len(removed)))
+	return nil
+}
+
+// HealthCheck reports whether the WAL is ready to be appended to: it is in
+// append mode, still holds the lock on its tail file, and the records of the
+// tail file decode cleanly. Only the tail file is read, so it is much cheaper
+// than Verify. Appends are only blocked while the buffered records are
+// flushed; the records written so far are then decoded through a separate
+// read-only file, while the WAL keeps being appended to.
+func (w *WAL) HealthCheck() error {
+	name, end, err := w.flushTail()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	decoder := NewDecoder(fileutil.NewFileReader(f))
+	rec := &walpb.Record{}
+	for decoder.LastOffset() < end {
+		if err = decoder.Decode(rec); err != nil {
+			return fmt.Errorf("wal: failed to decode %q: %w", name, err)
+		}
+		if rec.Type == CrcType {
+			decoder.UpdateCRC(rec.Crc)
+		}
+	}
+	return nil
+}
+
+// flushTail flushes the buffered records to the tail file after checking that
+// its lock is still held, and returns the path of the tail file and the offset
+// its records end at.
+func (w *WAL) flushTail() (name string, end int64, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tail := w.tail()
+	if tail == nil || w.encoder == nil {
+		return "", 0, ErrNotWritable
+	}
+	if _, err = tail.Stat(); err != nil {
+		return "", 0, fmt.Errorf("wal: tail file is not open: %w", err)
+	}
+	if err = w.encoder.flush(); err != nil {
+		return "", 0, err
+	}
+	name = filepath.Join(w.dir, filepath.Base(tail.Name()))
+	if l, err := fileutil.TryLockFile(name, os.O_RDWR, fileutil.PrivateFileMode); err == nil {
+		l.Close()
+		return "", 0, fmt.Errorf("%w: lock on %q is not held", ErrNotWritable, name)
+	} else if !errors.Is(err, fileutil.ErrLocked) {
+		return "", 0, err
+	}
+	if end, err = tail.Seek(0, io.SeekCurrent); err != nil {
+		return "", 0, err
+	}
+	return name, end, nil
+}
+
 // Close closes the current WAL file and directory.
 func (w *WAL) Close() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	return w.close(true)
+}
+
+// close releases the files and locks of the WAL, first syncing the tail if
+// sync is set and the WAL didn't fail. Released resources are cleared, so
+// closing again, with Close or Abort, doesn't release them twice.
+func (w *WAL) close(sync bool) error {
+	w.closed = true
+
+	if w.unmap != nil {
+		w.unmap()
+		w.unmap = nil
+	}
+	// the files opened for read, and their shared locks, if not read out
+	if w.readClose != nil {
+		w.readClose()
+		w.readClose = nil
+	}
 
 	if w.fp != nil {
 		w.fp.Close()
 		w.fp = nil
 	}
 
-	if w.tail() != nil {
+	// the records of a failed WAL are dropped like by Abort
+	if sync && w.tail() != nil && w.failed == nil {
 		if err := w.sync(); err != nil {
 			return err
 		}
@@ -927,15 +1800,53 @@ func (w *WAL) Close() error {
 			w.lg.Error("failed to close WAL", zap.Error(err))
 		}
 	}
+	w.locks = nil
 
-	return w.dirFile.Close()
+	if w.dirFile != nil {
+		err := w.dirFile.Close()
+		w.dirFile = nil
+		if err != nil {
+			return err
+		}
+	}
+	if sync && w.encoder != nil && w.failed == nil {
+		crc := w.encoder.crc.Sum32()
+		w.finalCRC = &crc
+	}
+	return nil
+}
+
+// FinalCRC returns the crc of the last record written to the WAL, to chain a
+// successor WAL onto it with WithInitialCRC, e.g. after compaction. It is only
+// known once a writable WAL was closed by Close without error, otherwise
+// ErrNotClosedCleanly is returned.
+func (w *WAL) FinalCRC() (uint32, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finalCRC == nil {
+		return 0, ErrNotClosedCleanly
+	}
+	return *w.finalCRC, nil
+}
+
+// Abort closes the WAL like a crash of the process would: records buffered by
+// the encoder are dropped instead of being flushed, and nothing is synced.
+// Data already written to the files is left as it is. Otherwise it releases
+// what Close does, the files and locks, including those of a WAL opened but
+// not read out, so the WAL can be opened again.
+func (w *WAL) Abort() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.encoder = nil
+	return w.close(false)
 }
 
 func (w *WAL) saveEntry(e *raftpb.Entry) error {
+	start := w.encoder.now()
 	// TODO: add MustMarshalTo to reduce one allocation.
 	b := pbutil.MustMarshal(e)
 	rec := &walpb.Record{Type: EntryType, Data: b}
-	if err := w.encoder.encode(rec); err != nil {
+	if err := w.encoder.encodeSince(rec, start); err != nil {
 		return err
 	}
 	w.enti = e.Index
@@ -947,14 +1858,56 @@ func (w *WAL) saveState(s *raftpb.HardState) error {
 		return nil
 	}
 	w.state = *s
+	start := w.encoder.now()
 	b := pbutil.MustMarshal(s)
 	rec := &walpb.Record{Type: StateType, Data: b}
-	return w.encoder.encode(rec)
+	return w.encoder.encodeSince(rec, start)
 }
 
 func (w *WAL) Save(st raftpb.HardState, ents []raftpb.Entry) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	return w.save(st, ents)
+}
+
+// SaveWithContext is Save, but returns ctx.Err() if ctx is done before the
+// WAL is ready to write, e.g. while another Save holds it waiting on a slow
+// disk, to bound how long a graceful stop waits on the WAL. ctx is only
+// checked before any record is written: once the save started, it returns
+// after the records are synced like Save does, so an error from ctx always
+// means nothing was written.
+func (w *WAL) SaveWithContext(ctx context.Context, st raftpb.HardState, ents []raftpb.Entry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !w.mu.TryLock() {
+		locked := make(chan struct{})
+		go func() {
+			w.mu.Lock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-ctx.Done():
+			// release the WAL once the pending Lock acquires it
+			go func() {
+				<-locked
+				w.mu.Unlock()
+			}()
+			return ctx.Err()
+		}
+	}
+	defer w.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.save(st, ents)
+}
+
+func (w *WAL) save(st raftpb.HardState, ents []raftpb.Entry) error {
+	if err := w.writeErr(); err != nil {
+		return err
+	}
 
 	// short cut, do not call sync
 	if raft.IsEmptyHardState(st) && len(ents) == 0 {
@@ -963,26 +1916,30 @@ func (w *WAL) Save(st raftpb.HardState, ents []raftpb.Entry) error {
 
 	mustSync := raft.MustSync(st, w.state, len(ents))
 
+	encoded := w.encoder.encoded
 	// TODO(xiangli): no more reference operator
 	for i := range ents {
 		if err := w.saveEntry(&ents[i]); err != nil {
-			return err
+			return w.fail(err)
 		}
 	}
 	if err := w.saveState(&st); err != nil {
-		return err
+		return w.fail(err)
 	}
+	walSaveBytes.Observe(float64(w.encoder.encoded - encoded))
 
-	curOff, err := w.tail().Seek(0, io.SeekCurrent)
+	remaining, err := w.bytesUntilCut()
 	if err != nil {
 		return err
 	}
-	if curOff < SegmentSizeBytes {
+	if remaining > 0 {
 		if mustSync {
 			// gofail: var walBeforeSync struct{}
 			err = w.sync()
 			// gofail: var walAfterSync struct{}
-			return err
+			if err != nil {
+				return w.fail(err)
+			}
 		}
 		return nil
 	}
@@ -990,31 +1947,113 @@ func (w *WAL) Save(st raftpb.HardState, ents []raftpb.Entry) error {
 	return w.cut()
 }
 
+// BytesUntilCut returns how many more bytes can be written to the tail
+// segment before Save cuts it, 0 if the next Save will or if the WAL isn't
+// open for writing. Records take their framed size: the length field, the
+// data and its padding to 8 bytes, so a record with n bytes of data takes
+// 8 + n rounded up to a multiple of 8, plus 8 with WithRecordSequencing. Like
+// Save, it only accounts for records flushed to the segment file, which
+// happens at the latest when Save syncs, so the Save crossing the boundary may
+// leave the cut to the next one.
+func (w *WAL) BytesUntilCut() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.tail() == nil {
+		return 0
+	}
+	remaining, err := w.bytesUntilCut()
+	if err != nil || remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// bytesUntilCut returns the bytes left before the tail reaches the segment
+// size, Save cuts it once it's not positive.
+func (w *WAL) bytesUntilCut() (int64, error) {
+	curOff, err := w.tail().Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return w.opts.segmentSize - curOff, nil
+}
+
 func (w *WAL) SaveSnapshot(e walpb.Snapshot) error {
+	_, err := w.SaveSnapshotWithPosition(e)
+	return err
+}
+
+// SnapshotPosition is where a snapshot record was written in the WAL.
+type SnapshotPosition struct {
+	// Segment is the name of the WAL file holding the record.
+	Segment string
+	// Offset is the byte offset of the record frame within Segment.
+	Offset int64
+}
+
+// SaveSnapshotWithPosition is like SaveSnapshot, but also returns where the
+// snapshot record was written.
+func (w *WAL) SaveSnapshotWithPosition(e walpb.Snapshot) (SnapshotPosition, error) {
 	if err := walpb.ValidateSnapshotForWrite(&e); err != nil {
-		return err
+		return SnapshotPosition{}, err
 	}
 
 	b := pbutil.MustMarshal(&e)
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	if err := w.writeErr(); err != nil {
+		return SnapshotPosition{}, err
+	}
 
 	rec := &walpb.Record{Type: SnapshotType, Data: b}
+	encoded := w.encoder.encoded
 	if err := w.encoder.encode(rec); err != nil {
-		return err
+		return SnapshotPosition{}, w.fail(err)
 	}
+	walSaveBytes.Observe(float64(w.encoder.encoded - encoded))
 	// update enti only when snapshot is ahead of last index
 	if w.enti < e.Index {
 		w.enti = e.Index
 	}
-	return w.sync()
+	if err := w.sync(); err != nil {
+		return SnapshotPosition{}, w.fail(err)
+	}
+	// the record is the last one flushed to the tail; the tail cannot be cut
+	// while w.mu is held
+	end, err := w.tail().Seek(0, io.SeekCurrent)
+	if err != nil {
+		return SnapshotPosition{}, err
+	}
+	return SnapshotPosition{
+		Segment: filepath.Base(w.tail().Name()),
+		Offset:  end - (w.encoder.encoded - encoded),
+	}, nil
 }
 
 func (w *WAL) saveCrc(prevCrc uint32) error {
 	return w.encoder.encode(&walpb.Record{Type: CrcType, Crc: prevCrc})
 }
 
+// sequenceRecords makes the encoder write sequenced frames if the WAL was
+// opened WithRecordSequencing. Unless the tail already declares the format, a
+// FormatType record is written first, in a frame any etcd version can parse,
+// so versions unaware of sequencing stop at its type instead of taking the
+// following frames for a torn write.
+func (w *WAL) sequenceRecords(declared bool) error {
+	if !w.opts.sequencing {
+		return nil
+	}
+	if !declared {
+		data := binary.BigEndian.AppendUint32(nil, formatSequenced)
+		if err := w.encoder.encode(&walpb.Record{Type: FormatType, Data: data}); err != nil {
+			return err
+		}
+	}
+	w.encoder.sequenced = true
+	return nil
+}
+
 func (w *WAL) tail() *fileutil.LockedFile {
 	if len(w.locks) > 0 {
 		return w.locks[len(w.locks)-1]
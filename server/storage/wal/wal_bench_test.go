@@ -15,11 +15,15 @@
 package wal
 
 import (
+	"fmt"
 	"testing"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
 	"go.etcd.io/raft/v3/raftpb"
 )
 
@@ -61,3 +65,196 @@ func benchmarkWriteEntry(b *testing.B, size int, batch int) {
 		}
 	}
 }
+
+var (
+	benchSegmentSizes = []int64{64 * 1024, 1024 * 1024, 16 * 1024 * 1024}
+	benchEntrySizes   = []int{100, 1000, 10000}
+)
+
+// BenchmarkSave measures Save throughput, including the cuts triggered
+// once the tail reaches the segment size, like TestSaveWithCut does.
+func BenchmarkSave(b *testing.B) {
+	for _, segmentSize := range benchSegmentSizes {
+		for _, entrySize := range benchEntrySizes {
+			b.Run(fmt.Sprintf("segment=%d/entry=%d", segmentSize, entrySize), func(b *testing.B) {
+				benchmarkSave(b, segmentSize, entrySize)
+			})
+		}
+	}
+}
+
+func benchmarkSave(b *testing.B, segmentSize int64, entrySize int) {
+	w, err := Create(zap.NewNop(), b.TempDir(), []byte("metadata"), WithSegmentSize(segmentSize))
+	require.NoError(b, err)
+	defer w.Close()
+
+	data := make([]byte, entrySize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	state := raftpb.HardState{Term: 1}
+	ents := []raftpb.Entry{{Term: 1, Data: data}}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(ents[0].Size()))
+	syncsBefore := fsyncCount(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ents[0].Index = uint64(i + 1)
+		state.Commit = uint64(i + 1)
+		if err := w.Save(state, ents); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(fsyncCount(b)-syncsBefore)/float64(b.N), "fsyncs/op")
+}
+
+// BenchmarkCut measures the cost of finalizing the tail segment and
+// switching to a new one after writing a segment's worth of entries.
+func BenchmarkCut(b *testing.B) {
+	for _, segmentSize := range benchSegmentSizes {
+		for _, entrySize := range benchEntrySizes {
+			b.Run(fmt.Sprintf("segment=%d/entry=%d", segmentSize, entrySize), func(b *testing.B) {
+				benchmarkCut(b, segmentSize, entrySize)
+			})
+		}
+	}
+}
+
+func benchmarkCut(b *testing.B, segmentSize int64, entrySize int) {
+	w, err := Create(zap.NewNop(), b.TempDir(), []byte("metadata"), WithSegmentSize(segmentSize))
+	require.NoError(b, err)
+	defer w.Close()
+
+	data := make([]byte, entrySize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	e := &raftpb.Entry{Term: 1, Data: data}
+	// fill most of a segment so each cut truncates and syncs a realistic tail
+	perSegment := int(segmentSize) / e.Size()
+	if perSegment > 1024 {
+		perSegment = 1024
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(perSegment * e.Size()))
+	var syncs uint64
+	index := uint64(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := 0; j < perSegment; j++ {
+			index++
+			e.Index = index
+			if err := w.saveEntry(e); err != nil {
+				b.Fatal(err)
+			}
+		}
+		syncsBefore := fsyncCount(b)
+		b.StartTimer()
+		if err := w.cut(); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		syncs += fsyncCount(b) - syncsBefore
+		// keep the number of locked files bounded over long runs
+		if err := w.ReleaseLockTo(index); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(syncs)/float64(b.N), "fsyncs/op")
+}
+
+// fsyncCount returns the number of fsyncs observed by the WAL so far.
+func fsyncCount(tb testing.TB) uint64 {
+	m := &dto.Metric{}
+	require.NoError(tb, walFsyncSec.Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+// BenchmarkReadAll measures replaying a WAL of large entries, like TestRecover
+// does, with buffered reads, with a larger read-ahead and with memory mapped
+// reads.
+func BenchmarkReadAll(b *testing.B) {
+	b.Run("buffered", func(b *testing.B) { benchmarkReadAll(b) })
+	b.Run("read-ahead", func(b *testing.B) { benchmarkReadAll(b, WithReadAhead(1024*1024)) })
+	b.Run("mmap", func(b *testing.B) { benchmarkReadAll(b, WithMmapRead()) })
+}
+
+func benchmarkReadAll(b *testing.B, opts ...Option) {
+	p := b.TempDir()
+	w, err := Create(zap.NewNop(), p, []byte("metadata"))
+	require.NoError(b, err)
+	data := make([]byte, 1024*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for i := 0; i < 40; i++ {
+		require.NoError(b, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: uint64(i + 1), Data: data}}))
+	}
+	require.NoError(b, w.Close())
+
+	b.ReportAllocs()
+	b.SetBytes(40 * int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w, err := OpenForRead(zap.NewNop(), p, walpb.Snapshot{}, opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, _, _, err = w.ReadAll(); err != nil {
+			b.Fatal(err)
+		}
+		w.Close()
+	}
+}
+
+// BenchmarkReadAllSegments measures replaying the same amount of data split
+// into many small segments or a few large ones, to quantify the cost of
+// opening and traversing files. It reports the files opened per replay.
+func BenchmarkReadAllSegments(b *testing.B) {
+	for _, totalSize := range []int{4 * 1024 * 1024, 32 * 1024 * 1024} {
+		for _, segmentSize := range benchSegmentSizes {
+			b.Run(fmt.Sprintf("total=%d/segment=%d", totalSize, segmentSize), func(b *testing.B) {
+				benchmarkReadAllSegments(b, segmentSize, totalSize)
+			})
+		}
+	}
+}
+
+func benchmarkReadAllSegments(b *testing.B, segmentSize int64, totalSize int) {
+	p := b.TempDir()
+	w, err := Create(zap.NewNop(), p, []byte("metadata"), WithSegmentSize(segmentSize))
+	require.NoError(b, err)
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	entries := totalSize / len(data)
+	for i := 0; i < entries; i++ {
+		require.NoError(b, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i + 1)}, []raftpb.Entry{{Term: 1, Index: uint64(i + 1), Data: data}}))
+	}
+	require.NoError(b, w.Close())
+
+	var files int
+	b.ReportAllocs()
+	b.SetBytes(int64(entries * len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w, err := OpenForRead(zap.NewNop(), p, walpb.Snapshot{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		files += len(w.Segments())
+		if _, _, _, err = w.ReadAll(); err != nil {
+			b.Fatal(err)
+		}
+		w.Close()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(files)/float64(b.N), "files/op")
+}
@@ -16,9 +16,13 @@ package wal
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"math"
 	"os"
@@ -28,12 +32,20 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 
 	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/pkg/v3/ioutil"
 	"go.etcd.io/etcd/pkg/v3/pbutil"
 	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
 	"go.etcd.io/raft/v3/raftpb"
@@ -87,6 +99,30 @@ func TestNew(t *testing.T) {
 	}
 }
 
+// TestReadPreallocatedTail ensures that decoding a whole preallocated tail
+// file, padding included, stops cleanly at the end of the written data.
+func TestReadPreallocatedTail(t *testing.T) {
+	p := t.TempDir()
+
+	w, err := Create(zaptest.NewLogger(t), p, []byte("somedata"))
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+
+	f, err := os.Open(filepath.Join(p, filepath.Base(w.tail().Name())))
+	require.NoError(t, err)
+	defer f.Close()
+
+	decoder := NewDecoderWithConfig(DecoderConfig{TolerateZeroPadding: true}, fileutil.NewFileReader(f))
+	rec := &walpb.Record{}
+	var types []int64
+	for err = decoder.Decode(rec); err == nil; err = decoder.Decode(rec) {
+		types = append(types, rec.Type)
+	}
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, []int64{CrcType, MetadataType, SnapshotType, EntryType, StateType}, types)
+}
+
 func TestCreateNewWALFile(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -207,6 +243,72 @@ func TestNewForInitedDir(t *testing.T) {
 	}
 }
 
+func TestOpenOrCreate(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := filepath.Join(t.TempDir(), "wal")
+
+	w, created, err := OpenOrCreate(lg, p, []byte("metadata"), walpb.Snapshot{})
+	require.NoError(t, err)
+	require.True(t, created)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	require.NoError(t, w.Close())
+
+	w, created, err = OpenOrCreate(lg, p, []byte("other"), walpb.Snapshot{})
+	require.NoError(t, err)
+	require.False(t, created)
+	defer w.Close()
+	metadata, state, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []byte("metadata"), metadata)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 1}, state)
+	require.Len(t, ents, 1)
+}
+
+func TestOpenOrCreateConcurrently(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	for i := 0; i < 10; i++ {
+		p := filepath.Join(t.TempDir(), "wal")
+		type result struct {
+			w       *WAL
+			created bool
+			err     error
+		}
+		results := make(chan result, 2)
+		for j := 0; j < 2; j++ {
+			go func() {
+				w, created, err := OpenOrCreate(lg, p, []byte("metadata"), walpb.Snapshot{})
+				if created {
+					// the WAL must not be removed by the other caller
+					err = w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}})
+				}
+				results <- result{w, created, err}
+			}()
+		}
+		// both calls are done before the created WAL is closed, so the other
+		// one could only fail to open it
+		rs := []result{<-results, <-results}
+		var creators int
+		for _, r := range rs {
+			if r.created {
+				creators++
+				require.NoError(t, r.err)
+				require.NoError(t, r.w.Close())
+			} else {
+				require.ErrorIs(t, r.err, fileutil.ErrLocked)
+			}
+		}
+		require.Equal(t, 1, creators)
+
+		w, err := Open(lg, p, walpb.Snapshot{})
+		require.NoError(t, err)
+		metadata, _, ents, err := w.ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, []byte("metadata"), metadata)
+		require.Len(t, ents, 1)
+		require.NoError(t, w.Close())
+	}
+}
+
 func TestOpenAtIndex(t *testing.T) {
 	dir := t.TempDir()
 
@@ -301,6 +403,73 @@ func TestVerify(t *testing.T) {
 	}
 }
 
+func TestVerifyWithWatermark(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	walDir := t.TempDir()
+	watermark := filepath.Join(t.TempDir(), "watermark")
+
+	w, err := Create(lg, walDir, nil)
+	require.NoError(t, err)
+	defer w.Close()
+	for i := 0; i < 2; i++ {
+		es := []raftpb.Entry{{Index: uint64(i + 1), Data: []byte(fmt.Sprintf("waldata%d", i+1))}}
+		require.NoError(t, w.Save(raftpb.HardState{}, es))
+		require.NoError(t, w.cut())
+	}
+	hs := raftpb.HardState{Term: 1, Commit: 2}
+	require.NoError(t, w.Save(hs, nil))
+
+	hardstate, err := Verify(lg, walDir, walpb.Snapshot{}, WithVerifyWatermark(watermark))
+	require.NoError(t, err)
+	require.Equal(t, hs, *hardstate)
+	require.FileExists(t, watermark)
+
+	// corrupt a verified record, keeping the modification time of the file,
+	// so that only a full verification notices it
+	first := filepath.Join(walDir, walName(0, 0))
+	fi, err := os.Stat(first)
+	require.NoError(t, err)
+	data, err := os.ReadFile(first)
+	require.NoError(t, err)
+	data[bytes.Index(data, []byte("waldata1"))] ^= 0xff
+	require.NoError(t, os.WriteFile(first, data, fileutil.PrivateFileMode))
+	require.NoError(t, os.Chtimes(first, fi.ModTime(), fi.ModTime()))
+	_, err = Verify(lg, walDir, walpb.Snapshot{})
+	require.Error(t, err)
+
+	hs = raftpb.HardState{Term: 2, Commit: 2}
+	require.NoError(t, w.Save(hs, nil))
+	hardstate, err = Verify(lg, walDir, walpb.Snapshot{}, WithVerifyWatermark(watermark))
+	require.NoError(t, err)
+	require.Equal(t, hs, *hardstate)
+
+	// a modified earlier segment invalidates the watermark
+	require.NoError(t, os.Chtimes(first, fi.ModTime(), fi.ModTime().Add(time.Second)))
+	_, err = Verify(lg, walDir, walpb.Snapshot{}, WithVerifyWatermark(watermark))
+	require.Error(t, err)
+}
+
+func TestVerifyTermMonotonicity(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	walDir := t.TempDir()
+
+	w, err := Create(lg, walDir, nil)
+	require.NoError(t, err)
+	defer w.Close()
+
+	for term := uint64(1); term <= 3; term++ {
+		es := []raftpb.Entry{{Index: term, Term: term}}
+		require.NoError(t, w.Save(raftpb.HardState{Term: term, Commit: term}, es))
+		require.NoError(t, w.cut())
+	}
+	require.NoError(t, VerifyTermMonotonicity(lg, walDir, walpb.Snapshot{}))
+
+	require.NoError(t, w.Save(raftpb.HardState{Term: 2, Commit: 3}, nil))
+	err = VerifyTermMonotonicity(lg, walDir, walpb.Snapshot{})
+	require.ErrorIs(t, err, ErrTermRegression)
+	require.ErrorContains(t, err, "term 3 and commit 3 followed by hard state with term 2 and commit 3")
+}
+
 // TestCut tests cut
 // TODO: split it into smaller tests for better readability
 func TestCut(t *testing.T) {
@@ -364,7 +533,9 @@ func TestCut(t *testing.T) {
 func TestSaveWithCut(t *testing.T) {
 	p := t.TempDir()
 
-	w, err := Create(zaptest.NewLogger(t), p, []byte("metadata"))
+	// use a small segment size, else the test takes too long to complete
+	const segmentSize int64 = 2 * 1024
+	w, err := Create(zaptest.NewLogger(t), p, []byte("metadata"), WithSegmentSize(segmentSize))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -376,13 +547,9 @@ func TestSaveWithCut(t *testing.T) {
 	bigData := make([]byte, 500)
 	strdata := "Hello World!!"
 	copy(bigData, strdata)
-	// set a lower value for SegmentSizeBytes, else the test takes too long to complete
-	restoreLater := SegmentSizeBytes
 	const EntrySize int = 500
-	SegmentSizeBytes = 2 * 1024
-	defer func() { SegmentSizeBytes = restoreLater }()
 	index := uint64(0)
-	for totalSize := 0; totalSize < int(SegmentSizeBytes); totalSize += EntrySize {
+	for totalSize := 0; totalSize < int(segmentSize); totalSize += EntrySize {
 		ents := []raftpb.Entry{{Index: index, Term: 1, Data: bigData}}
 		if err = w.Save(state, ents); err != nil {
 			t.Fatal(err)
@@ -408,8 +575,8 @@ func TestSaveWithCut(t *testing.T) {
 	if !reflect.DeepEqual(newhardstate, state) {
 		t.Errorf("Hard State = %+v, want %+v", newhardstate, state)
 	}
-	if len(entries) != int(SegmentSizeBytes/int64(EntrySize)) {
-		t.Errorf("Number of entries = %d, expected = %d", len(entries), int(SegmentSizeBytes/int64(EntrySize)))
+	if len(entries) != int(segmentSize/int64(EntrySize)) {
+		t.Errorf("Number of entries = %d, expected = %d", len(entries), int(segmentSize/int64(EntrySize)))
 	}
 	for _, oneent := range entries {
 		if !bytes.Equal(oneent.Data, bigData) {
@@ -418,6 +585,214 @@ func TestSaveWithCut(t *testing.T) {
 	}
 }
 
+func TestOpenWithMmapRead(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, []byte("metadata"))
+	require.NoError(t, err)
+	var ents []raftpb.Entry
+	for i := 1; i <= 3; i++ {
+		es := []raftpb.Entry{{Index: uint64(i), Term: 1, Data: []byte(fmt.Sprintf("data%d", i))}}
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, es))
+		require.NoError(t, w.cut())
+		ents = append(ents, es...)
+	}
+	require.NoError(t, w.Close())
+
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithMmapRead())
+	require.NoError(t, err)
+	metadata, state, gotEnts, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []byte("metadata"), metadata)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 3}, state)
+	require.Equal(t, ents, gotEnts)
+	require.Nil(t, w.unmap)
+
+	// the WAL is appendable after reading from the mapped files
+	es := []raftpb.Entry{{Index: 4, Term: 1, Data: []byte("data4")}}
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 4}, es))
+	ents = append(ents, es...)
+	require.NoError(t, w.Close())
+
+	w, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, gotEnts, err = w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, ents, gotEnts)
+}
+
+func TestWithSegmentSize(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	data := make([]byte, 512)
+	sizes := []int64{2 * 1024, 64 * 1024}
+	var wals []*WAL
+	for _, size := range sizes {
+		w, err := Create(lg, t.TempDir(), []byte("metadata"), WithSegmentSize(size))
+		require.NoError(t, err)
+		defer w.Close()
+		fi, err := w.tail().Stat()
+		require.NoError(t, err)
+		require.Equal(t, size, fi.Size(), "the initial segment should be preallocated to the segment size")
+		wals = append(wals, w)
+	}
+	// save to both WALs in turn, so they are used at the same time
+	for i := uint64(1); i <= 32; i++ {
+		for _, w := range wals {
+			require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: i}, []raftpb.Entry{{Index: i, Term: 1, Data: data}}))
+		}
+	}
+
+	var segments []int
+	for i, w := range wals {
+		names, err := readWALNames(lg, w.dir)
+		require.NoError(t, err)
+		segments = append(segments, len(names))
+		// finalized segments are truncated at the first record crossing the
+		// segment size
+		for _, name := range names[:len(names)-1] {
+			fi, err := os.Stat(filepath.Join(w.dir, name))
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, fi.Size(), sizes[i])
+			require.Less(t, fi.Size(), sizes[i]+1024)
+		}
+	}
+	require.Greater(t, segments[0], 1)
+	require.Equal(t, 1, segments[1])
+	require.Equal(t, int64(64*1000*1000), SegmentSizeBytes, "the default should be left untouched")
+}
+
+func TestOpenWithReadAhead(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, []byte("metadata"))
+	require.NoError(t, err)
+	data := make([]byte, 16*1024)
+	var ents []raftpb.Entry
+	for i := 1; i <= 8; i++ {
+		es := []raftpb.Entry{{Index: uint64(i), Term: 1, Data: data}}
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, es))
+		if i%3 == 0 {
+			require.NoError(t, w.cut())
+		}
+		ents = append(ents, es...)
+	}
+	require.NoError(t, w.Close())
+
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithReadAhead(64*1024))
+	require.NoError(t, err)
+	for _, br := range w.decoder.(*decoder).brs {
+		require.Equal(t, 64*1024, br.Size())
+	}
+	metadata, state, gotEnts, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []byte("metadata"), metadata)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 8}, state)
+	require.Equal(t, ents, gotEnts)
+
+	// reading ahead doesn't move where records are appended
+	es := []raftpb.Entry{{Index: 9, Term: 1, Data: data}}
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 9}, es))
+	ents = append(ents, es...)
+	require.NoError(t, w.Close())
+
+	w, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, gotEnts, err = w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, ents, gotEnts)
+}
+
+func TestWithCompression(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	compressible := bytes.Repeat([]byte("etcd"), 4*1024)
+	incompressible := make([]byte, 1024)
+	_, err := rand.Read(incompressible)
+	require.NoError(t, err)
+	ents := []raftpb.Entry{
+		{Index: 1, Term: 1, Data: compressible},
+		{Index: 2, Term: 1, Data: incompressible},
+		{Index: 3, Term: 1},
+	}
+
+	// write writes the entries to a new WAL, returning the size of its data.
+	write := func(p string, opts ...Option) int64 {
+		w, err := Create(lg, p, []byte("metadata"), opts...)
+		require.NoError(t, err)
+		for _, e := range ents {
+			require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: e.Index}, []raftpb.Entry{e}))
+		}
+		off, err := w.tail().Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return off
+	}
+	p := t.TempDir()
+	compressed := write(p, WithCompression())
+	uncompressed := write(t.TempDir())
+	require.Less(t, compressed, uncompressed-int64(len(compressible))/2)
+
+	// the records on disk are flagged, and checksummed as written
+	f, err := os.Open(filepath.Join(p, walName(0, 0)))
+	require.NoError(t, err)
+	defer f.Close()
+	var types []int64
+	for {
+		var lenField uint64
+		if err = binary.Read(f, binary.LittleEndian, &lenField); err != nil || lenField == 0 {
+			break
+		}
+		recBytes, padBytes := decodeFrameSize(int64(lenField))
+		data := make([]byte, recBytes+padBytes)
+		_, err = io.ReadFull(f, data)
+		require.NoError(t, err)
+		var rec walpb.Record
+		require.NoError(t, rec.Unmarshal(data[:recBytes]))
+		if rec.Type&^compressedTypeFlag == EntryType {
+			types = append(types, rec.Type)
+		}
+	}
+	require.Equal(t, []int64{EntryType | compressedTypeFlag, EntryType, EntryType}, types)
+
+	// compressed records are read regardless of the option
+	w, err := Open(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	metadata, state, gotEnts, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []byte("metadata"), metadata)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 3}, state)
+	require.Equal(t, ents, gotEnts)
+
+	// appending without the option keeps the records readable
+	es := []raftpb.Entry{{Index: 4, Term: 1, Data: compressible}}
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 4}, es))
+	ents = append(ents, es...)
+	require.NoError(t, w.Close())
+
+	w, err = OpenForRead(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, gotEnts, err = w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, ents, gotEnts)
+}
+
+// TestDecompressRecordSizeLimit ensures records larger than
+// maxCompressedRecordBytes are written uncompressed, and compressed records
+// decompressing past it fail to decode.
+func TestDecompressRecordSizeLimit(t *testing.T) {
+	data := make([]byte, maxCompressedRecordBytes+1)
+
+	rec := &walpb.Record{Type: EntryType, Data: data}
+	compressRecord(rec)
+	require.Equal(t, EntryType, rec.Type)
+	require.Len(t, rec.Data, len(data))
+
+	enc, _ := zstdCoders()
+	rec = &walpb.Record{Type: EntryType | compressedTypeFlag, Data: enc.EncodeAll(data, nil)}
+	err := decompressRecord(rec)
+	require.ErrorIs(t, err, zstd.ErrDecoderSizeExceeded)
+}
+
 func TestRecover(t *testing.T) {
 	cases := []struct {
 		name string
@@ -671,6 +1046,10 @@ func TestOpenForRead(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer w2.Close()
+	names, err := readWALNames(zaptest.NewLogger(t), p)
+	require.NoError(t, err)
+	require.Len(t, names, 11)
+	require.Equal(t, names, w2.Segments())
 	_, _, ents, err := w2.ReadAll()
 	require.NoErrorf(t, err, "err = %v, want nil", err)
 	if g := ents[len(ents)-1].Index; g != 9 {
@@ -678,6 +1057,138 @@ func TestOpenForRead(t *testing.T) {
 	}
 }
 
+func TestSegments(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{walName(0, 0)}, w.Segments())
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1}}))
+		if i == 2 {
+			require.NoError(t, w.SaveSnapshot(walpb.Snapshot{Index: 2, Term: 1, ConfState: &confState}))
+		}
+		require.NoError(t, w.cut())
+	}
+	// cuts don't change the segments selected when opening
+	require.Equal(t, []string{walName(0, 0)}, w.Segments())
+	require.NoError(t, w.Close())
+
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{Index: 2, Term: 1})
+	require.NoError(t, err)
+	defer w.Close()
+	require.Equal(t, []string{walName(1, 2), walName(2, 3), walName(3, 4)}, w.Segments())
+}
+
+// TestOpenForReadWithSharedLocks ensures that WithSharedLocks reads files
+// still locked by the writer, and keeps released files from being purged.
+func TestOpenForReadWithSharedLocks(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	defer w.Close()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: uint64(i)}}))
+		require.NoError(t, w.cut())
+	}
+	require.NoError(t, w.ReleaseLockTo(3))
+	names, err := readWALNames(zaptest.NewLogger(t), p)
+	require.NoError(t, err)
+
+	// the files still locked by the writer are read without a lock
+	r, err := OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithSharedLocks())
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: 6}}))
+
+	// released files can't be purged while they're being read
+	_, err = fileutil.TryLockFile(filepath.Join(p, names[0]), os.O_WRONLY, fileutil.PrivateFileMode)
+	require.ErrorIs(t, err, fileutil.ErrLocked)
+
+	_, _, ents, err := r.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), ents[len(ents)-1].Index)
+
+	// ReadAll releases the shared locks
+	l, err := fileutil.TryLockFile(filepath.Join(p, names[0]), os.O_WRONLY, fileutil.PrivateFileMode)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	r.Close()
+}
+
+func TestOpenWithSharedLocksHeld(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: 1}}))
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Close())
+
+	r, err := OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithSharedLocks())
+	require.NoError(t, err)
+	defer r.Close()
+
+	// the writer can't lock the files the reader holds shared locks on
+	_, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.ErrorIs(t, err, fileutil.ErrLocked)
+
+	_, _, _, err = r.ReadAll()
+	require.NoError(t, err)
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+// TestSeekReadPosition ensures that a read interrupted at some position can be
+// resumed by another WAL opened at the same snapshot.
+func TestSeekReadPosition(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		es := []raftpb.Entry{{Index: uint64(i), Term: 1}}
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, es))
+		if i%3 == 0 {
+			require.NoError(t, w.cut())
+		}
+	}
+	require.NoError(t, w.Close())
+
+	w, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	// read records up to and including the entry at index 5
+	rec := &walpb.Record{}
+	for w.enti != 5 {
+		require.NoError(t, w.decoder.Decode(rec))
+		switch rec.Type {
+		case CrcType:
+			w.decoder.UpdateCRC(rec.Crc)
+		case EntryType:
+			w.enti = MustUnmarshalEntry(rec.Data).Index
+		}
+	}
+	pos, err := w.ReadPosition()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), pos.Index)
+	w.Close()
+
+	w, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Seek(pos))
+	_, state, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 10}, state)
+	require.Len(t, ents, 5)
+	for i, e := range ents {
+		require.Equal(t, uint64(i+6), e.Index)
+	}
+
+	// a WAL opened for writing would lose the metadata and state before pos
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	require.ErrorIs(t, w.Seek(pos), ErrSeekWritable)
+}
+
 func TestOpenWithMaxIndex(t *testing.T) {
 	p := t.TempDir()
 	// create WAL
@@ -789,6 +1300,54 @@ func TestReleaseLockTo(t *testing.T) {
 	}
 }
 
+func TestTruncateToSnapshot(t *testing.T) {
+	p := t.TempDir()
+	// syncedFirst records whether the first segment was still there when the
+	// tail was last synced
+	var syncedFirst bool
+	syncer := SyncerFunc(func(f *os.File) error {
+		_, err := os.Stat(filepath.Join(p, walName(0, 0)))
+		syncedFirst = err == nil
+		return fileutil.Fdatasync(f)
+	})
+	w, err := Create(zaptest.NewLogger(t), p, nil, WithSyncer(syncer))
+	require.NoError(t, err)
+	defer w.Close()
+	for i := 1; i <= 6; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1}}))
+		require.NoError(t, w.cut())
+	}
+	snap := walpb.Snapshot{Index: 4, Term: 1, ConfState: &confState}
+	require.ErrorIs(t, w.TruncateToSnapshot(snap), ErrSnapshotNotFound)
+	names, err := readWALNames(zaptest.NewLogger(t), p)
+	require.NoError(t, err)
+	require.Len(t, names, 7)
+
+	require.NoError(t, w.SaveSnapshot(snap))
+	require.NoError(t, w.ReleaseLockTo(2))
+	syncedFirst = false
+	require.NoError(t, w.TruncateToSnapshot(snap))
+	// the snapshot record is synced before any segment is removed
+	require.True(t, syncedFirst)
+	names, err = readWALNames(zaptest.NewLogger(t), p)
+	require.NoError(t, err)
+	// the segment with the entry at the snapshot index is kept
+	require.Equal(t, []string{walName(3, 4), walName(4, 5), walName(5, 6), walName(6, 7)}, names)
+	require.Len(t, w.locks, 4)
+
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 7}, []raftpb.Entry{{Index: 7, Term: 1}}))
+	require.NoError(t, w.Close())
+	_, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.Error(t, err)
+	w, err = Open(zaptest.NewLogger(t), p, snap)
+	require.NoError(t, err)
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), ents[0].Index)
+	require.Equal(t, uint64(7), ents[len(ents)-1].Index)
+	require.NoError(t, w.Close())
+}
+
 // TestTailWriteNoSlackSpace ensures that tail writes append if there's no preallocated space.
 func TestTailWriteNoSlackSpace(t *testing.T) {
 	p := t.TempDir()
@@ -915,7 +1474,7 @@ func TestOpenOnTornWrite(t *testing.T) {
 	w.Close()
 
 	// clobber some entry with 0's to simulate a torn write
-	f, ferr := os.OpenFile(fn, os.O_WRONLY, fileutil.PrivateFileMode)
+	f, ferr := os.OpenFile(fn, os.O_RDWR, fileutil.PrivateFileMode)
 	if ferr != nil {
 		t.Fatal(ferr)
 	}
@@ -929,6 +1488,18 @@ func TestOpenOnTornWrite(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	// the entries following the clobbered one are reported as discarded
+	_, err = f.Seek(offsets[clobberIdx], io.SeekStart)
+	require.NoError(t, err)
+	discarded, _, torn := scanTornTail(f)
+	require.True(t, torn)
+	require.Equal(t, maxEntries-clobberIdx-2, discarded)
+	_, err = f.Seek(offsets[maxEntries-1], io.SeekStart)
+	require.NoError(t, err)
+	discarded, _, torn = scanTornTail(f)
+	require.False(t, torn)
+	require.Zero(t, discarded)
 	f.Close()
 
 	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
@@ -966,37 +1537,135 @@ func TestOpenOnTornWrite(t *testing.T) {
 	require.Equalf(t, len(ents), wEntries, "expected len(ents) = %d, got %d", wEntries, len(ents))
 }
 
-func TestRenameFail(t *testing.T) {
+func TestAbort(t *testing.T) {
 	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 1}}))
+	// the entry stays in the encoder buffer, as Save would sync it
+	require.NoError(t, w.saveEntry(&raftpb.Entry{Index: 2}))
+	require.NoError(t, w.Abort())
 
-	oldSegmentSizeBytes := SegmentSizeBytes
-	defer func() {
-		SegmentSizeBytes = oldSegmentSizeBytes
-	}()
-	SegmentSizeBytes = math.MaxInt64
+	// opening for writing requires the locks to be released
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, state, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, raftpb.HardState{Term: 1}, state)
+	require.Equal(t, []raftpb.Entry{{Index: 1}}, ents)
+}
 
-	tp := t.TempDir()
-	os.RemoveAll(tp)
+// TestAbortUnread ensures Abort releases the files and shared locks of a WAL
+// opened but not read out, and that closing it again is harmless.
+func TestAbortUnread(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 1}}))
+	require.NoError(t, w.Close())
 
-	w := &WAL{
-		lg:  zaptest.NewLogger(t),
-		dir: p,
-	}
-	w2, werr := w.renameWAL(tp)
-	if w2 != nil || werr == nil { // os.Rename should fail from 'no such file or directory'
-		t.Fatalf("expected error, got %v", werr)
-	}
+	r, err := OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithSharedLocks())
+	require.NoError(t, err)
+	require.NoError(t, r.Abort())
+	require.NoError(t, r.Abort())
+	require.NoError(t, r.Close())
+
+	// the writer can lock the files the reader held shared locks on
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	require.NoError(t, w.Abort())
+	require.NoError(t, w.Close())
 }
 
-// TestReadAllFail ensure ReadAll error if used without opening the WAL
-func TestReadAllFail(t *testing.T) {
-	dir := t.TempDir()
+func TestSaveShortWrite(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	off, err := w.tail().Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
 
-	// create initial WAL
-	f, err := Create(zaptest.NewLogger(t), dir, []byte("metadata"))
-	if err != nil {
-		t.Fatal(err)
-	}
+	// the tail takes only part of the next frame
+	w.encoder.bw = ioutil.NewPageWriter(fullWriter{&shortWriter{w: w.tail().File, max: 3, limit: 100}}, walPageBytes, int(off))
+	err = w.Save(raftpb.HardState{Term: 1, Commit: 2}, []raftpb.Entry{{Index: 2, Term: 1, Data: make([]byte, 1000)}})
+	require.ErrorIs(t, err, ErrShortWrite)
+
+	// no half frame is left in the tail
+	fi, err := w.tail().Stat()
+	require.NoError(t, err)
+	require.Equal(t, off, fi.Size())
+
+	// further writes fail until the WAL is opened again
+	err = w.Save(raftpb.HardState{Term: 1, Commit: 3}, []raftpb.Entry{{Index: 3, Term: 1}})
+	require.ErrorIs(t, err, ErrWALFailed)
+	require.ErrorIs(t, err, ErrShortWrite)
+	_, err = w.SaveSnapshotWithPosition(walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState})
+	require.ErrorIs(t, err, ErrWALFailed)
+	require.NoError(t, w.Close())
+
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, state, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 1}, state)
+	require.Equal(t, []raftpb.Entry{{Index: 1, Term: 1}}, ents)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 2}, []raftpb.Entry{{Index: 2, Term: 1}}))
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		close func(w *WAL) error
+	}{
+		{name: "Close", close: (*WAL).Close},
+		{name: "Abort", close: (*WAL).Abort},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := Create(zaptest.NewLogger(t), t.TempDir(), nil)
+			require.NoError(t, err)
+			require.NoError(t, tc.close(w))
+
+			require.ErrorIs(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 1}}), ErrWALClosed)
+			require.ErrorIs(t, w.Save(raftpb.HardState{}, nil), ErrWALClosed)
+			require.ErrorIs(t, w.SaveSnapshot(walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState}), ErrWALClosed)
+			require.ErrorIs(t, w.cut(), ErrWALClosed)
+		})
+	}
+}
+
+func TestRenameFail(t *testing.T) {
+	p := t.TempDir()
+
+	oldSegmentSizeBytes := SegmentSizeBytes
+	defer func() {
+		SegmentSizeBytes = oldSegmentSizeBytes
+	}()
+	SegmentSizeBytes = math.MaxInt64
+
+	tp := t.TempDir()
+	os.RemoveAll(tp)
+
+	w := &WAL{
+		lg:  zaptest.NewLogger(t),
+		dir: p,
+	}
+	w2, werr := w.renameWAL(tp)
+	if w2 != nil || werr == nil { // os.Rename should fail from 'no such file or directory'
+		t.Fatalf("expected error, got %v", werr)
+	}
+}
+
+// TestReadAllFail ensure ReadAll error if used without opening the WAL
+func TestReadAllFail(t *testing.T) {
+	dir := t.TempDir()
+
+	// create initial WAL
+	f, err := Create(zaptest.NewLogger(t), dir, []byte("metadata"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	f.Close()
 	// try to read without opening the WAL
 	_, _, _, err = f.ReadAll()
@@ -1051,16 +1720,48 @@ func TestValidSnapshotEntries(t *testing.T) {
 	if !reflect.DeepEqual(walSnaps, expected) {
 		t.Errorf("expected walSnaps %+v, got %+v", expected, walSnaps)
 	}
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeValidSnapshotEntries(zaptest.NewLogger(t), p, &buf))
+	expectedJSON := `{"index":0,"term":0,"voters":[]}
+{"index":1,"term":1,"voters":[16763508]}
+{"index":2,"term":1,"voters":[16763508]}
+{"index":3,"term":2,"voters":[16763508]}
+`
+	require.Equal(t, expectedJSON, buf.String())
+
+	entries, err := SnapshotIndexes(zaptest.NewLogger(t), p)
+	require.NoError(t, err)
+	expectedEntries := []SnapshotEntry{
+		{Snapshot: snap0, Valid: true},
+		{Snapshot: snap1, Valid: true},
+		{Snapshot: snap2, Valid: true},
+		{Snapshot: snap3, Valid: true},
+		{Snapshot: snap4, Valid: false},
+	}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("expected snapshot entries %+v, got %+v", expectedEntries, entries)
+	}
+
+	for _, tc := range []struct {
+		snap walpb.Snapshot
+		want bool
+	}{
+		{snap: snap0, want: true},
+		{snap: snap3, want: true},
+		{snap: walpb.Snapshot{Index: 3, Term: 1}, want: false},
+		{snap: snap4, want: false},
+		{snap: walpb.Snapshot{Index: 5, Term: 2}, want: false},
+	} {
+		found, err := HasSnapshot(zaptest.NewLogger(t), p, tc.snap)
+		require.NoError(t, err)
+		require.Equalf(t, tc.want, found, "snapshot %d/%d", tc.snap.Index, tc.snap.Term)
+	}
 }
 
 // TestValidSnapshotEntriesAfterPurgeWal ensure that there are many wal files, and after cleaning the first wal file,
 // it can work well.
 func TestValidSnapshotEntriesAfterPurgeWal(t *testing.T) {
-	oldSegmentSizeBytes := SegmentSizeBytes
-	SegmentSizeBytes = 64
-	defer func() {
-		SegmentSizeBytes = oldSegmentSizeBytes
-	}()
 	p := t.TempDir()
 	snap0 := walpb.Snapshot{}
 	snap1 := walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState}
@@ -1069,7 +1770,7 @@ func TestValidSnapshotEntriesAfterPurgeWal(t *testing.T) {
 	snap3 := walpb.Snapshot{Index: 3, Term: 2, ConfState: &confState}
 	state2 := raftpb.HardState{Commit: 3, Term: 2}
 	func() {
-		w, err := Create(zaptest.NewLogger(t), p, nil)
+		w, err := Create(zaptest.NewLogger(t), p, nil, WithSegmentSize(64))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1105,6 +1806,55 @@ func TestValidSnapshotEntriesAfterPurgeWal(t *testing.T) {
 	}
 }
 
+func TestSnapshotCache(t *testing.T) {
+	p := t.TempDir()
+	snap1 := walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState}
+	snap2 := walpb.Snapshot{Index: 2, Term: 1, ConfState: &confState}
+	snap3 := walpb.Snapshot{Index: 3, Term: 2, ConfState: &confState}
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.SaveSnapshot(snap1))
+	require.NoError(t, w.Save(raftpb.HardState{Commit: 1, Term: 1}, nil))
+	require.NoError(t, w.cut())
+	require.NoError(t, w.SaveSnapshot(snap2))
+	require.NoError(t, w.Save(raftpb.HardState{Commit: 2, Term: 1}, nil))
+	require.NoError(t, w.SaveSnapshot(snap3))
+
+	lg := zaptest.NewLogger(t)
+	cache := NewSnapshotCache()
+	expected, err := ValidSnapshotEntries(lg, p)
+	require.NoError(t, err)
+	require.Equal(t, []walpb.Snapshot{{}, snap1, snap2}, expected)
+	snaps, err := cache.ValidSnapshotEntries(lg, p)
+	require.NoError(t, err)
+	require.Equal(t, expected, snaps)
+	require.Len(t, cache.segments, 1, "only the finalized segment should be cached")
+
+	// the tail is decoded again on each call
+	require.NoError(t, w.Save(raftpb.HardState{Commit: 3, Term: 2}, nil))
+	snaps, err = cache.ValidSnapshotEntries(lg, p)
+	require.NoError(t, err)
+	require.Equal(t, []walpb.Snapshot{{}, snap1, snap2, snap3}, snaps)
+
+	// unchanged finalized segments are not decoded again
+	names, err := readWALNames(lg, p)
+	require.NoError(t, err)
+	seg := cache.segments[names[0]]
+	seg.records = nil
+	cache.segments[names[0]] = seg
+	snaps, err = cache.ValidSnapshotEntries(lg, p)
+	require.NoError(t, err)
+	require.Equal(t, []walpb.Snapshot{snap2, snap3}, snaps)
+
+	// until their modification time changes
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(p, names[0]), modTime, modTime))
+	snaps, err = cache.ValidSnapshotEntries(lg, p)
+	require.NoError(t, err)
+	require.Equal(t, []walpb.Snapshot{{}, snap1, snap2, snap3}, snaps)
+}
+
 func TestLastRecordLengthExceedFileEnd(t *testing.T) {
 	/* The data below was generated by code something like below. The length
 	 * of the last record was intentionally changed to 1000 in order to make
@@ -1180,3 +1930,1261 @@ func TestLastRecordLengthExceedFileEnd(t *testing.T) {
 	// environment, but only once.
 	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
 }
+
+func TestMaxTotalSizePurge(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	dir := t.TempDir()
+
+	var purged [][]string
+	purge := func(freed []string) { purged = append(purged, freed) }
+	w, err := Create(lg, dir, nil, WithMaxTotalSize(1, purge))
+	require.NoError(t, err)
+	defer w.Close()
+
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: uint64(i)}}))
+		require.NoError(t, w.cut())
+	}
+	// every file is still locked by the WAL
+	require.Empty(t, purged)
+
+	require.NoError(t, w.ReleaseLockTo(4))
+	require.NoError(t, w.cut())
+	require.Equal(t, [][]string{{
+		filepath.Join(dir, walName(0, 0)),
+		filepath.Join(dir, walName(1, 2)),
+	}}, purged)
+}
+
+func TestOnCut(t *testing.T) {
+	dir := t.TempDir()
+	var finalized []string
+	onCut := func(name string) {
+		// the finalized segment and the new tail are in place
+		names, err := fileutil.ReadDir(dir, fileutil.WithExt(".wal"))
+		require.NoError(t, err)
+		require.Contains(t, names, name)
+		require.NotEqual(t, name, names[len(names)-1])
+		finalized = append(finalized, name)
+	}
+	w, err := Create(zaptest.NewLogger(t), dir, nil, WithOnCut(onCut))
+	require.NoError(t, err)
+	for i := 1; i <= 2; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: uint64(i)}}))
+		require.NoError(t, w.cut())
+	}
+	require.NoError(t, w.Close())
+	require.Equal(t, []string{walName(0, 0), walName(1, 2)}, finalized)
+
+	w, err = Open(zaptest.NewLogger(t), dir, walpb.Snapshot{}, WithOnCut(onCut))
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, _, err = w.ReadAll()
+	require.NoError(t, err)
+	require.NoError(t, w.cut())
+	require.Equal(t, []string{walName(0, 0), walName(1, 2), walName(2, 3)}, finalized)
+}
+
+func TestCopySegment(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), dir, nil)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: 1, Data: []byte("data")}}))
+	require.NoError(t, w.cut())
+
+	dst := filepath.Join(t.TempDir(), walName(0, 0))
+	crc, err := CopySegment(filepath.Join(dir, walName(0, 0)), dst)
+	require.NoError(t, err)
+	src, err := os.ReadFile(filepath.Join(dir, walName(0, 0)))
+	require.NoError(t, err)
+	copied, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, src, copied)
+	require.Equal(t, crc32.Checksum(copied, crcTable), crc)
+
+	// the destination is never overwritten
+	_, err = CopySegment(filepath.Join(dir, walName(0, 0)), dst)
+	require.ErrorIs(t, err, os.ErrExist)
+
+	tail := filepath.Base(w.tail().Name())
+	_, err = CopySegment(filepath.Join(dir, tail), filepath.Join(t.TempDir(), tail))
+	require.ErrorIs(t, err, ErrLiveSegment)
+}
+
+func TestSegmentFooters(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "plain", opts: []Option{WithSegmentFooters()}},
+		{name: "sequenced", opts: []Option{WithSegmentFooters(), WithRecordSequencing()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lg := zaptest.NewLogger(t)
+			dir := t.TempDir()
+			w, err := Create(lg, dir, []byte("metadata"), tc.opts...)
+			require.NoError(t, err)
+			var ents []raftpb.Entry
+			for i := 1; i <= 2; i++ {
+				es := []raftpb.Entry{{Index: uint64(i), Term: 1, Data: []byte(fmt.Sprintf("data%d", i))}}
+				require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, es))
+				require.NoError(t, w.cut())
+				ents = append(ents, es...)
+			}
+			require.NoError(t, w.Close())
+
+			verified, err := VerifySegmentFooters(lg, dir)
+			require.NoError(t, err)
+			require.Equal(t, []string{walName(0, 0), walName(1, 2)}, verified)
+			_, err = Verify(lg, dir, walpb.Snapshot{})
+			require.NoError(t, err)
+			w, err = Open(lg, dir, walpb.Snapshot{}, tc.opts...)
+			require.NoError(t, err)
+			_, _, gotEnts, err := w.ReadAll()
+			require.NoError(t, err)
+			require.Equal(t, ents, gotEnts)
+			require.NoError(t, w.Close())
+
+			first := filepath.Join(dir, walName(0, 0))
+			data, err := os.ReadFile(first)
+			require.NoError(t, err)
+			data[bytes.Index(data, []byte("data1"))] ^= 0xff
+			require.NoError(t, os.WriteFile(first, data, fileutil.PrivateFileMode))
+			_, err = VerifySegmentFooters(lg, dir)
+			require.ErrorIs(t, err, ErrSegmentFooterMismatch)
+			// Verify trusts the footers once it found the snapshot
+			_, err = Verify(lg, dir, walpb.Snapshot{})
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestVerifySegmentFootersChain ensures that Verify, skipping finalized
+// segments with a footer, still detects a segment spliced in from another WAL.
+func TestVerifySegmentFootersChain(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	create := func(data string) string {
+		dir := t.TempDir()
+		w, err := Create(lg, dir, []byte("metadata"), WithSegmentFooters())
+		require.NoError(t, err)
+		for i := uint64(1); i <= 3; i++ {
+			require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: i}, []raftpb.Entry{{Index: i, Term: 1, Data: []byte(data)}}))
+			if i < 3 {
+				require.NoError(t, w.cut())
+			}
+		}
+		require.NoError(t, w.Close())
+		return dir
+	}
+	dir, other := create("data"), create("other")
+
+	state, err := Verify(lg, dir, walpb.Snapshot{})
+	require.NoError(t, err)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 3}, *state)
+
+	names, err := readWALNames(lg, dir)
+	require.NoError(t, err)
+	require.Len(t, names, 3)
+	data, err := os.ReadFile(filepath.Join(other, names[1]))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, names[1]), data, fileutil.PrivateFileMode))
+	_, err = Verify(lg, dir, walpb.Snapshot{})
+	require.ErrorIs(t, err, ErrCRCMismatch)
+}
+
+func TestSaveSnapshotWithPosition(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "plain"},
+		{name: "sequenced", opts: []Option{WithRecordSequencing()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := t.TempDir()
+			w, err := Create(zaptest.NewLogger(t), p, nil, tc.opts...)
+			require.NoError(t, err)
+			defer w.Close()
+			require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: 1, Term: 1, Data: []byte("data")}}))
+
+			snap := walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState}
+			pos, err := w.SaveSnapshotWithPosition(snap)
+			require.NoError(t, err)
+			require.Equal(t, walName(0, 0), pos.Segment)
+
+			f, err := os.Open(filepath.Join(p, pos.Segment))
+			require.NoError(t, err)
+			defer f.Close()
+			_, err = f.Seek(pos.Offset, io.SeekStart)
+			require.NoError(t, err)
+			header := make([]byte, frameSizeBytes)
+			_, err = io.ReadFull(f, header)
+			require.NoError(t, err)
+			lenField := binary.LittleEndian.Uint64(header)
+			if lenField&frameSequencedFlag != 0 {
+				// skip the sequence number following the length field
+				_, err = io.ReadFull(f, header)
+				require.NoError(t, err)
+			}
+			recBytes, _ := decodeFrameSize(int64(lenField &^ frameSequencedFlag))
+			data := make([]byte, recBytes)
+			_, err = io.ReadFull(f, data)
+			require.NoError(t, err)
+
+			var rec walpb.Record
+			require.NoError(t, rec.Unmarshal(data))
+			require.Equal(t, SnapshotType, rec.Type)
+			var got walpb.Snapshot
+			pbutil.MustUnmarshal(&got, rec.Data)
+			require.Equal(t, snap, got)
+		})
+	}
+}
+
+func TestRecordSequencing(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, []byte("metadata"), WithRecordSequencing())
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: 2, Term: 1}}))
+	require.NoError(t, w.Close())
+
+	// the sequence continues across segments and reopening
+	w, err = Open(lg, p, walpb.Snapshot{}, WithRecordSequencing())
+	require.NoError(t, err)
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, ents, 2)
+	seq := w.encoder.seq
+	require.NotZero(t, seq)
+	require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: 3, Term: 1}}))
+	require.Equal(t, seq+1, w.encoder.seq)
+	require.NoError(t, w.Close())
+
+	w, err = OpenForRead(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, ents, err = w.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, ents, 3)
+}
+
+// TestRecordSequencingDroppedSegment ensures that the sequence is checked
+// across segments, so dropping a whole segment fails to decode even when the
+// crc is resumed from the CrcType record heading the next one.
+func TestRecordSequencingDroppedSegment(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, []byte("metadata"), WithRecordSequencing())
+	require.NoError(t, err)
+	for i := uint64(1); i <= 3; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: i, Term: 1}}))
+		if i < 3 {
+			require.NoError(t, w.cut())
+		}
+	}
+	require.NoError(t, w.Close())
+
+	names, err := readWALNames(lg, p)
+	require.NoError(t, err)
+	require.Len(t, names, 3)
+	var readers []fileutil.FileReader
+	for _, name := range []string{names[0], names[2]} {
+		f, err := os.Open(filepath.Join(p, name))
+		require.NoError(t, err)
+		defer f.Close()
+		readers = append(readers, fileutil.NewFileReader(f))
+	}
+
+	decoder := NewDecoder(readers...)
+	rec := &walpb.Record{}
+	for {
+		err = decoder.Decode(rec)
+		if err != nil {
+			break
+		}
+		if rec.Type == CrcType {
+			decoder.UpdateCRC(rec.Crc)
+		}
+	}
+	var seqErr *SequenceError
+	require.ErrorAs(t, err, &seqErr)
+}
+
+// TestRecordSequencingFormat ensures that no sequenced frame is written
+// before a FormatType record in the same segment, so that etcd versions unaware
+// of sequencing fail on the record type before reaching one.
+func TestRecordSequencingFormat(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	require.NoError(t, w.Close())
+
+	// sequencing an existing tail, then reopening it, declares the format once
+	for i := uint64(2); i <= 3; i++ {
+		w, err = Open(lg, p, walpb.Snapshot{}, WithRecordSequencing())
+		require.NoError(t, err)
+		_, _, _, err = w.ReadAll()
+		require.NoError(t, err)
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: i, Term: 1}}))
+		if i == 3 {
+			require.NoError(t, w.cut())
+			require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 4, Term: 1}}))
+		}
+		require.NoError(t, w.Close())
+	}
+
+	// types lists the types of the records of a segment, marking the
+	// sequenced ones with a negative type
+	types := func(name string) (types []int64) {
+		data, err := os.ReadFile(filepath.Join(p, name))
+		require.NoError(t, err)
+		for off := 0; off+frameSizeBytes <= len(data); {
+			lenField := binary.LittleEndian.Uint64(data[off:])
+			if lenField == 0 {
+				break
+			}
+			off += frameSizeBytes
+			sequenced := lenField&frameSequencedFlag != 0
+			if sequenced {
+				off += frameSizeBytes
+			}
+			recBytes, padBytes := decodeFrameSize(int64(lenField &^ frameSequencedFlag))
+			var rec walpb.Record
+			require.NoError(t, rec.Unmarshal(data[off:off+int(recBytes)]))
+			off += int(recBytes + padBytes)
+			if sequenced {
+				rec.Type = -rec.Type
+			}
+			types = append(types, rec.Type)
+		}
+		return types
+	}
+	names, err := readWALNames(lg, p)
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+	require.Equal(t, []int64{
+		CrcType, MetadataType, SnapshotType, EntryType, StateType,
+		FormatType, -EntryType, -StateType,
+		-EntryType, -StateType,
+	}, types(names[0]))
+	require.Equal(t, []int64{CrcType, FormatType, -MetadataType, -StateType, -EntryType, -StateType}, types(names[1]))
+
+	w, err = OpenForRead(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, ents, 4)
+}
+
+func TestReadAllRecords(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, []byte("metadata"))
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	require.NoError(t, w.cut())
+	require.NoError(t, w.SaveSnapshot(walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState}))
+	require.NoError(t, w.Close())
+
+	recs, err := ReadAllRecords(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	var types []int64
+	for _, rec := range recs {
+		types = append(types, rec.Type)
+	}
+	require.Equal(t, []int64{
+		// first file
+		CrcType, MetadataType, SnapshotType, EntryType, StateType,
+		// second file
+		CrcType, MetadataType, StateType, SnapshotType,
+	}, types)
+	require.Equal(t, []byte("metadata"), recs[1].Data)
+	require.Equal(t, raftpb.Entry{Index: 1, Term: 1}, MustUnmarshalEntry(recs[3].Data))
+}
+
+func TestOpenWithoutAutoRepair(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, nil)
+	require.NoError(t, err)
+	var offsets []int64
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: uint64(i)}}))
+		off, serr := w.tail().Seek(0, io.SeekCurrent)
+		require.NoError(t, serr)
+		offsets = append(offsets, off)
+	}
+	fn := filepath.Join(p, filepath.Base(w.tail().Name()))
+	require.NoError(t, w.Close())
+
+	// clobber the third entry to simulate a torn write
+	f, err := os.OpenFile(fn, os.O_WRONLY, fileutil.PrivateFileMode)
+	require.NoError(t, err)
+	_, err = f.WriteAt(make([]byte, offsets[2]-offsets[1]), offsets[1])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	before, err := os.ReadFile(fn)
+	require.NoError(t, err)
+
+	w, err = Open(lg, p, walpb.Snapshot{}, WithoutAutoRepair())
+	require.NoError(t, err)
+	_, _, _, err = w.ReadAll()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	w.Close()
+	after, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	w, err = Open(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, ents, 2)
+}
+
+func TestCreateWithInitialCRC(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	seed := crc32.Checksum([]byte("previous log"), crcTable)
+	w, err := Create(lg, p, []byte("metadata"), WithInitialCRC(seed))
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Close())
+
+	recs, err := ReadAllRecords(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	require.Equal(t, CrcType, recs[0].Type)
+	require.Equal(t, seed, recs[0].Crc)
+
+	w, err = Open(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, ents, 1)
+}
+
+func TestFinalCRC(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, []byte("metadata"))
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	_, err = w.FinalCRC()
+	require.ErrorIs(t, err, ErrNotClosedCleanly)
+	require.NoError(t, w.Close())
+	crc, err := w.FinalCRC()
+	require.NoError(t, err)
+
+	recs, err := ReadAllRecords(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	require.Equal(t, recs[len(recs)-1].Crc, crc)
+
+	// a successor WAL continues the crc chain
+	next := filepath.Join(t.TempDir(), "next")
+	w, err = Create(lg, next, []byte("metadata"), WithInitialCRC(crc))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	recs, err = ReadAllRecords(lg, next, walpb.Snapshot{})
+	require.NoError(t, err)
+	require.Equal(t, crc, recs[0].Crc)
+
+	w, err = Create(lg, filepath.Join(t.TempDir(), "aborted"), nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Abort())
+	_, err = w.FinalCRC()
+	require.ErrorIs(t, err, ErrNotClosedCleanly)
+}
+
+func TestHealthCheck(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	p := t.TempDir()
+	w, err := Create(lg, p, nil)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1}, []raftpb.Entry{{Index: 1, Term: 1, Data: []byte("data")}}))
+	require.NoError(t, w.HealthCheck())
+
+	off, err := w.tail().Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	f, err := os.OpenFile(filepath.Join(p, filepath.Base(w.tail().Name())), os.O_WRONLY, fileutil.PrivateFileMode)
+	require.NoError(t, err)
+	// records being appended concurrently, past the flushed ones, are ignored
+	_, err = f.WriteAt([]byte{0x80, 0, 0, 0}, off)
+	require.NoError(t, err)
+	require.NoError(t, w.HealthCheck())
+	_, err = f.WriteAt([]byte("corrupt"), off-8)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Error(t, w.HealthCheck())
+
+	r, err := OpenForRead(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer r.Close()
+	require.ErrorIs(t, r.HealthCheck(), ErrNotWritable)
+}
+
+func TestWithLogFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	lg := zap.New(core)
+	dir := t.TempDir()
+	field := zap.String("member-id", "8e9e05c52164694d")
+
+	w, err := Create(lg, dir, nil, WithLogFields(field))
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{}, []raftpb.Entry{{Index: 1}}))
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Close())
+
+	w, err = Open(lg, dir, walpb.Snapshot{}, WithLogFields(field))
+	require.NoError(t, err)
+	_, _, _, err = w.ReadAll()
+	require.NoError(t, err)
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Close())
+
+	require.NotZero(t, logs.FilterMessage("created a new WAL segment").Len())
+	for _, entry := range logs.All() {
+		var n int
+		for _, f := range entry.Context {
+			if f.Equals(field) {
+				n++
+			}
+		}
+		assert.Equalf(t, 1, n, "%q has %d %q fields, want 1", entry.Message, n, field.Key)
+	}
+}
+
+func TestBytesUntilCut(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil, WithSegmentSize(4*1024))
+	require.NoError(t, err)
+
+	// the data isn't a multiple of 8 bytes, so its frame is padded
+	data := make([]byte, 101)
+	var cuts int
+	for i := 1; i <= 200; i++ {
+		remaining := w.BytesUntilCut()
+		segments := len(w.locks)
+		encoded := w.encoder.encoded
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1, Data: data}}))
+		cut := len(w.locks) > segments
+		require.Equalf(t, remaining == 0, cut, "save %d with %d bytes until cut", i, remaining)
+		if cut {
+			cuts++
+			// the new segment starts with the crc, metadata and state records
+			require.Positive(t, w.BytesUntilCut())
+			require.Less(t, w.BytesUntilCut(), int64(4*1024))
+			continue
+		}
+		// the synced records take their framed size, padding included
+		written := w.encoder.encoded - encoded
+		require.Zero(t, written%8)
+		require.Equal(t, max(remaining-written, 0), w.BytesUntilCut())
+	}
+	require.Greater(t, cuts, 1)
+	require.NoError(t, w.Close())
+
+	w, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	require.Zero(t, w.BytesUntilCut())
+}
+
+func TestSaveMetrics(t *testing.T) {
+	saveBytes := func() (uint64, float64) {
+		m := &dto.Metric{}
+		require.NoError(t, walSaveBytes.Write(m))
+		return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+	}
+	cuts := func() float64 {
+		m := &dto.Metric{}
+		require.NoError(t, walCuts.Write(m))
+		return m.GetCounter().GetValue()
+	}
+	w, err := Create(zaptest.NewLogger(t), t.TempDir(), nil)
+	require.NoError(t, err)
+	defer w.Close()
+
+	count, sum := saveBytes()
+	off, err := w.tail().Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1, Data: []byte("data")}}))
+	end, err := w.tail().Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	gotCount, gotSum := saveBytes()
+	require.Equal(t, count+1, gotCount)
+	require.InDelta(t, float64(end-off), gotSum-sum, 0)
+
+	require.NoError(t, w.SaveSnapshot(walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState}))
+	gotCount, _ = saveBytes()
+	require.Equal(t, count+2, gotCount)
+
+	before := cuts()
+	require.NoError(t, w.cut())
+	require.InDelta(t, before+1, cuts(), 0)
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(reg))
+	t.Cleanup(func() {
+		require.NoError(t, RegisterMetrics(prometheus.DefaultRegisterer))
+	})
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	require.Contains(t, names, "etcd_disk_wal_cuts_total")
+
+	// the names are free in the default registerer, for collectors with the
+	// same help, which prometheus keeps once a collector is unregistered
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "etcd_disk_wal_cuts_total", Help: "Total number of WAL segments cut."})
+	require.NoError(t, prometheus.Register(c))
+	prometheus.Unregister(c)
+
+	// creating a WAL afterwards doesn't register them with it again
+	w, err := Create(zaptest.NewLogger(t), t.TempDir(), nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.False(t, prometheus.Unregister(walCuts))
+}
+
+// TestRegisterMetricsRollback ensures a failed RegisterMetrics leaves none of
+// the metrics registered with the registerer.
+func TestRegisterMetricsRollback(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "etcd_disk_wal_cuts_total", Help: "Total number of WAL segments cut."})
+	require.NoError(t, reg.Register(c))
+	t.Cleanup(func() {
+		require.NoError(t, RegisterMetrics(prometheus.DefaultRegisterer))
+	})
+
+	require.Error(t, RegisterMetrics(reg))
+	for _, collector := range collectors {
+		// walCuts has the same descriptor as c
+		if collector != walCuts {
+			require.False(t, reg.Unregister(collector))
+		}
+	}
+	require.True(t, reg.Unregister(c))
+}
+
+func TestEncodeTiming(t *testing.T) {
+	encodeCount := func() uint64 {
+		m := &dto.Metric{}
+		require.NoError(t, walEncodeSec.Write(m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want uint64
+	}{
+		{name: "disabled"},
+		// the crc, metadata and snapshot records of Create, 10 entries and a state
+		{name: "enabled", opts: []Option{WithEncodeTiming()}, want: 14},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before := encodeCount()
+			w, err := Create(zaptest.NewLogger(t), t.TempDir(), []byte("metadata"), tc.opts...)
+			require.NoError(t, err)
+			defer w.Close()
+
+			ents := make([]raftpb.Entry, 10)
+			for i := range ents {
+				ents[i] = raftpb.Entry{Index: uint64(i + 1), Term: 1, Data: []byte("data")}
+			}
+			require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 10}, ents))
+			require.Equal(t, tc.want, encodeCount()-before)
+		})
+	}
+}
+
+func TestOpenForReadCompressed(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		compress func(t *testing.T, data []byte) []byte
+	}{
+		{
+			name: "gzip",
+			compress: func(t *testing.T, data []byte) []byte {
+				var compressed bytes.Buffer
+				zw := gzip.NewWriter(&compressed)
+				_, err := zw.Write(data)
+				require.NoError(t, err)
+				require.NoError(t, zw.Close())
+				return compressed.Bytes()
+			},
+		},
+		{
+			name: "zstd",
+			compress: func(t *testing.T, data []byte) []byte {
+				zw, err := zstd.NewWriter(nil)
+				require.NoError(t, err)
+				defer zw.Close()
+				return zw.EncodeAll(data, nil)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := Create(zaptest.NewLogger(t), dir, []byte("metadata"), WithSegmentSize(64*1024))
+			require.NoError(t, err)
+			state := raftpb.HardState{Term: 1, Commit: 2}
+			ents := []raftpb.Entry{{Index: 1, Term: 1, Data: []byte("a")}, {Index: 2, Term: 1, Data: []byte("b")}}
+			require.NoError(t, w.Save(state, ents))
+			require.NoError(t, w.cut())
+			require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 3}, []raftpb.Entry{{Index: 3, Term: 1, Data: []byte("c")}}))
+			require.NoError(t, w.Close())
+
+			// compress the finalized segment in place, keeping its name
+			name := filepath.Join(dir, walName(0, 0))
+			data, err := os.ReadFile(name)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(name, tc.compress(t, data), fileutil.PrivateFileMode))
+
+			r, err := OpenForRead(zaptest.NewLogger(t), dir, walpb.Snapshot{})
+			require.NoError(t, err)
+			metadata, gotState, gotEnts, err := r.ReadAll()
+			require.NoError(t, err)
+			r.Close()
+			require.Equal(t, []byte("metadata"), metadata)
+			require.Equal(t, raftpb.HardState{Term: 1, Commit: 3}, gotState)
+			require.Equal(t, append(ents, raftpb.Entry{Index: 3, Term: 1, Data: []byte("c")}), gotEnts)
+
+			_, err = Verify(zaptest.NewLogger(t), dir, walpb.Snapshot{})
+			require.NoError(t, err)
+
+			// a corrupted compressed file fails to open
+			require.NoError(t, os.WriteFile(name, tc.compress(t, data)[:8], fileutil.PrivateFileMode))
+			_, err = OpenForRead(zaptest.NewLogger(t), dir, walpb.Snapshot{})
+			require.ErrorContains(t, err, "failed to decompress")
+
+			// as does one decompressing past the limit
+			oldSegmentSizeBytes := SegmentSizeBytes
+			defer func() {
+				SegmentSizeBytes = oldSegmentSizeBytes
+			}()
+			SegmentSizeBytes = 64 * 1024
+			require.NoError(t, os.WriteFile(name, tc.compress(t, make([]byte, decompressedSizeLimit()+1)), fileutil.PrivateFileMode))
+			_, err = OpenForRead(zaptest.NewLogger(t), dir, walpb.Snapshot{})
+			require.ErrorIs(t, err, ErrDecompressedTooLarge)
+		})
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	snap := walpb.Snapshot{Index: 2, Term: 1, ConfState: &confState}
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1}}))
+		if i == 2 {
+			require.NoError(t, w.SaveSnapshot(snap))
+		}
+		require.NoError(t, w.cut())
+	}
+	require.NoError(t, w.Close())
+
+	cps, err := Checkpoints(zaptest.NewLogger(t), p, snap)
+	require.NoError(t, err)
+	require.Len(t, cps, 5)
+	var offset int64
+	for i, cp := range cps {
+		require.Equal(t, walName(uint64(i+1), uint64(i+2)), cp.Name)
+		require.Equal(t, uint64(i+1), cp.Seq)
+		require.Equal(t, uint64(i+2), cp.Index)
+		require.Equal(t, offset, cp.Offset)
+		info, err := os.Stat(filepath.Join(p, cp.Name))
+		require.NoError(t, err)
+		offset += info.Size()
+	}
+
+	// a WAL opened at the snapshot can start reading from any checkpoint
+	w, err = OpenForRead(zaptest.NewLogger(t), p, snap)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Seek(ReadPosition{Offset: cps[2].Offset, Index: cps[2].Index - 1}))
+	_, state, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []raftpb.Entry{{Index: 4, Term: 1}, {Index: 5, Term: 1}}, ents)
+	require.Equal(t, uint64(5), state.Commit)
+
+	_, err = Checkpoints(zaptest.NewLogger(t), t.TempDir(), snap)
+	require.Error(t, err)
+}
+
+func TestReadAllLimit(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, []byte("metadata"))
+	require.NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1}}))
+		if i == 5 {
+			require.NoError(t, w.cut())
+		}
+	}
+	require.NoError(t, w.Close())
+
+	var pos ReadPosition
+	var read []raftpb.Entry
+	for page := 0; ; page++ {
+		w, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{})
+		require.NoError(t, err)
+		if page > 0 {
+			require.NoError(t, w.Seek(pos))
+		}
+		metadata, state, ents, more, err := w.ReadAllLimit(4)
+		require.NoError(t, err)
+		read = append(read, ents...)
+		if !more {
+			require.Len(t, ents, 2)
+			require.Equal(t, uint64(10), state.Commit)
+			w.Close()
+			break
+		}
+		require.Len(t, ents, 4)
+		require.Equal(t, []byte("metadata"), metadata)
+		// the hard state is the one saved along the last entry returned
+		require.Equal(t, ents[3].Index, state.Commit)
+		pos, err = w.ReadPosition()
+		require.NoError(t, err)
+		require.Equal(t, ents[3].Index, pos.Index)
+		_, _, _, err = w.ReadAll()
+		require.ErrorIs(t, err, ErrReadLimited)
+		w.Close()
+	}
+	require.Len(t, read, 10)
+	for i, e := range read {
+		require.Equal(t, uint64(i+1), e.Index)
+	}
+
+	w, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, state, ents, more, err := w.ReadAllLimit(0)
+	require.NoError(t, err)
+	require.False(t, more)
+	require.Len(t, ents, 10)
+	require.Equal(t, uint64(10), state.Commit)
+}
+
+func TestIterate(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, []byte("metadata"))
+	require.NoError(t, err)
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 2}, []raftpb.Entry{{Index: uint64(i), Term: 1}}))
+		if i == 3 {
+			require.NoError(t, w.cut())
+		}
+	}
+	// overwrite the uncommitted entries from index 4
+	require.NoError(t, w.Save(raftpb.HardState{Term: 2, Commit: 4}, []raftpb.Entry{{Index: 4, Term: 2}}))
+	require.NoError(t, w.Close())
+
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	var iterated []raftpb.Entry
+	metadata, state, err := w.Iterate(func(ent raftpb.Entry) error {
+		iterated = append(iterated[:ent.Index-1], ent)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte("metadata"), metadata)
+	require.Equal(t, raftpb.HardState{Term: 2, Commit: 4}, state)
+	// the WAL is ready for appending, like after ReadAll
+	require.NoError(t, w.Save(raftpb.HardState{Term: 2, Commit: 5}, []raftpb.Entry{{Index: 5, Term: 2}}))
+	require.NoError(t, w.Close())
+
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	_, _, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, ents[:4], iterated)
+
+	t.Run("stop", func(t *testing.T) {
+		w, err := Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+		require.NoError(t, err)
+		defer w.Close()
+		errStop := errors.New("stop")
+		var n int
+		_, _, err = w.Iterate(func(ent raftpb.Entry) error {
+			if n++; n == 2 {
+				return errStop
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, errStop)
+		require.Equal(t, 2, n)
+		// the WAL stays in read mode, after the entry fn stopped at
+		pos, err := w.ReadPosition()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), pos.Index)
+	})
+}
+
+func TestManifest(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, []byte("metadata"))
+	require.NoError(t, err)
+	for i := 1; i <= 6; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1}}))
+		if i%2 == 0 {
+			require.NoError(t, w.cut())
+		}
+	}
+	require.NoError(t, w.Close())
+
+	manifest := filepath.Join(t.TempDir(), "manifest")
+	require.NoError(t, WriteManifest(zaptest.NewLogger(t), p, manifest))
+	require.NoError(t, VerifyManifest(zaptest.NewLogger(t), p, manifest))
+
+	segments, err := manifestSegments(zaptest.NewLogger(t), p)
+	require.NoError(t, err)
+	require.Len(t, segments, 4)
+	// the tail holds no entry
+	for i, r := range [][2]uint64{{0, 2}, {3, 4}, {5, 6}, {7, 0}} {
+		require.Equal(t, r[0], segments[i].FirstIndex)
+		require.Equal(t, r[1], segments[i].LastIndex)
+	}
+
+	// a modified segment
+	f, err := os.OpenFile(filepath.Join(p, segments[1].Name), os.O_WRONLY, fileutil.PrivateFileMode)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, segments[1].Size-1)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.ErrorIs(t, VerifyManifest(zaptest.NewLogger(t), p, manifest), ErrManifestMismatch)
+
+	// a missing segment
+	require.NoError(t, WriteManifest(zaptest.NewLogger(t), p, manifest))
+	require.NoError(t, os.Remove(filepath.Join(p, segments[3].Name)))
+	require.ErrorIs(t, VerifyManifest(zaptest.NewLogger(t), p, manifest), ErrManifestMismatch)
+}
+
+func TestAppendRaw(t *testing.T) {
+	p := t.TempDir()
+	lg := zaptest.NewLogger(t)
+	w, err := Create(lg, p, []byte("metadata"), WithSegmentSize(256))
+	require.NoError(t, err)
+
+	// frames encodes the records chained from the crc of the WAL.
+	frames := func(recs ...*walpb.Record) [][]byte {
+		var frames [][]byte
+		prevCrc := w.LastCRC()
+		for _, rec := range recs {
+			var buf bytes.Buffer
+			require.NoError(t, EncodeRecords(&buf, prevCrc, rec))
+			frames = append(frames, buf.Bytes())
+			prevCrc = rec.Crc
+		}
+		return frames
+	}
+	entry := func(index uint64) *walpb.Record {
+		return &walpb.Record{Type: EntryType, Data: pbutil.MustMarshal(&raftpb.Entry{Index: index, Term: 1, Data: []byte("data")})}
+	}
+	state := func(commit uint64) *walpb.Record {
+		return &walpb.Record{Type: StateType, Data: pbutil.MustMarshal(&raftpb.HardState{Term: 1, Commit: commit})}
+	}
+
+	var ents []raftpb.Entry
+	for index := uint64(1); index <= 10; index += 2 {
+		require.NoError(t, w.AppendRaw(frames(entry(index), entry(index+1), state(index+1))))
+		ents = append(ents,
+			raftpb.Entry{Index: index, Term: 1, Data: []byte("data")},
+			raftpb.Entry{Index: index + 1, Term: 1, Data: []byte("data")},
+		)
+	}
+
+	// records not chained from the crc of the WAL are rejected as a whole
+	valid := frames(entry(11))
+	var other bytes.Buffer
+	require.NoError(t, EncodeRecords(&other, 1, entry(12)))
+	require.ErrorIs(t, w.AppendRaw([][]byte{valid[0], other.Bytes()}), ErrCRCMismatch)
+	require.ErrorIs(t, w.AppendRaw([][]byte{valid[0][:len(valid[0])-8]}), ErrMalformedRawRecord)
+	require.ErrorIs(t, w.AppendRaw(frames(&walpb.Record{Type: MetadataType, Data: []byte("metadata")})), ErrMalformedRawRecord)
+	require.NoError(t, w.AppendRaw(valid))
+	ents = append(ents, raftpb.Entry{Index: 11, Term: 1, Data: []byte("data")})
+	require.NoError(t, w.Close())
+
+	names, err := readWALNames(lg, p)
+	require.NoError(t, err)
+	require.Greater(t, len(names), 1, "appending should have cut the tail")
+	w, err = Open(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	metadata, st, readEnts, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []byte("metadata"), metadata)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 10}, st)
+	require.Equal(t, ents, readEnts)
+
+	r, err := OpenForRead(lg, p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer r.Close()
+	require.Zero(t, r.LastCRC())
+}
+
+func TestWithSyncer(t *testing.T) {
+	p := t.TempDir()
+	var syncs int
+	var syncErr error
+	syncer := SyncerFunc(func(f *os.File) error {
+		syncs++
+		return syncErr
+	})
+	w, err := Create(zaptest.NewLogger(t), p, nil, WithSyncer(syncer))
+	require.NoError(t, err)
+	defer w.Close()
+	created := syncs
+
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 2}, []raftpb.Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1}}))
+	require.Equal(t, created+1, syncs)
+	// nothing to make durable
+	require.NoError(t, w.Save(raftpb.HardState{}, nil))
+	require.Equal(t, created+1, syncs)
+
+	syncErr = errors.New("sync failed")
+	require.ErrorIs(t, w.Save(raftpb.HardState{Term: 1, Commit: 3}, []raftpb.Entry{{Index: 3, Term: 1}}), syncErr)
+}
+
+func TestSaveWithContext(t *testing.T) {
+	p := t.TempDir()
+	// syncs signal syncing and block while the channels are set
+	var syncing, block chan struct{}
+	syncer := SyncerFunc(func(f *os.File) error {
+		if block != nil {
+			syncing <- struct{}{}
+			<-block
+		}
+		return nil
+	})
+	w, err := Create(zaptest.NewLogger(t), p, nil, WithSyncer(syncer))
+	require.NoError(t, err)
+	require.NoError(t, w.SaveWithContext(t.Context(), raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	require.ErrorIs(t, w.SaveWithContext(ctx, raftpb.HardState{Term: 1, Commit: 2}, []raftpb.Entry{{Index: 2, Term: 1}}), context.Canceled)
+
+	// a Save blocked on its sync holds the WAL
+	syncing, block = make(chan struct{}), make(chan struct{})
+	saved := make(chan error)
+	go func() {
+		saved <- w.Save(raftpb.HardState{Term: 1, Commit: 2}, []raftpb.Entry{{Index: 2, Term: 1}})
+	}()
+	<-syncing
+	ctx, cancel = context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	err = w.SaveWithContext(ctx, raftpb.HardState{Term: 1, Commit: 3}, []raftpb.Entry{{Index: 3, Term: 1}})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	close(block)
+	require.NoError(t, <-saved)
+	block = nil
+	require.NoError(t, w.Close())
+
+	// nothing was written by the call that timed out
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{})
+	require.NoError(t, err)
+	defer w.Close()
+	_, state, ents, err := w.ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, raftpb.HardState{Term: 1, Commit: 2}, state)
+	require.Equal(t, []raftpb.Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1}}, ents)
+}
+
+func TestWithMinExpectedSize(t *testing.T) {
+	p := t.TempDir()
+	w, err := Create(zaptest.NewLogger(t), p, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 1}, []raftpb.Entry{{Index: 1, Term: 1}}))
+	require.NoError(t, w.Close())
+
+	names, err := readWALNames(zaptest.NewLogger(t), p)
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+	fi, err := os.Stat(filepath.Join(p, names[0]))
+	require.NoError(t, err)
+
+	w, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithMinExpectedSize(fi.Size()))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// a truncated copy
+	require.NoError(t, os.Truncate(filepath.Join(p, names[0]), fi.Size()/2))
+	_, err = Open(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithMinExpectedSize(fi.Size()))
+	require.ErrorIs(t, err, ErrWALTooSmall)
+	_, err = OpenForRead(zaptest.NewLogger(t), p, walpb.Snapshot{}, WithMinExpectedSize(fi.Size()))
+	require.ErrorIs(t, err, ErrWALTooSmall)
+}
+
+func TestStartBackgroundScrub(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "decoded"},
+		{name: "footers", opts: []Option{WithSegmentFooters()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := Create(zaptest.NewLogger(t), dir, []byte("metadata"), tc.opts...)
+			require.NoError(t, err)
+			defer w.Close()
+			for i := 1; i <= 2; i++ {
+				es := []raftpb.Entry{{Index: uint64(i), Term: 1, Data: []byte(fmt.Sprintf("data%d", i))}}
+				require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, es))
+				require.NoError(t, w.cut())
+			}
+			require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 3}, []raftpb.Entry{{Index: 3, Term: 1, Data: []byte("data3")}}))
+
+			w.scrub(t.Context(), func(err *CorruptionError) {
+				t.Errorf("unexpected corruption: %v", err)
+			})
+
+			first := filepath.Join(dir, walName(0, 0))
+			data, err := os.ReadFile(first)
+			require.NoError(t, err)
+			data[bytes.Index(data, []byte("data1"))] ^= 0xff
+			require.NoError(t, os.WriteFile(first, data, fileutil.PrivateFileMode))
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			errc := make(chan *CorruptionError, 10)
+			w.StartBackgroundScrub(ctx, 10*time.Millisecond, func(err *CorruptionError) {
+				errc <- err
+			})
+			select {
+			case err := <-errc:
+				require.Equal(t, walName(0, 0), err.Segment)
+				if w.opts.segmentFooters {
+					require.ErrorIs(t, err, ErrSegmentFooterMismatch)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("corruption not reported")
+			}
+		})
+	}
+}
+
+func TestSalvageSegmentLocked(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	w, err := Create(lg, dir, []byte("metadata"))
+	require.NoError(t, err)
+	defer w.Close()
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1, Data: []byte(fmt.Sprintf("data%d", i))}}))
+	}
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 4}, []raftpb.Entry{{Index: 4, Term: 1, Data: []byte("data4")}}))
+	require.NoError(t, w.cut())
+
+	first := filepath.Join(dir, walName(0, 0))
+	data, err := os.ReadFile(first)
+	require.NoError(t, err)
+	data[bytes.Index(data, []byte("data2"))] ^= 0xff
+	require.NoError(t, os.WriteFile(first, data, fileutil.PrivateFileMode))
+
+	// the segment is still locked by the writer
+	_, err = Verify(lg, dir, walpb.Snapshot{}, WithReadRepair())
+	require.Error(t, err)
+	require.NoFileExists(t, first+".corrupt")
+	_, err = SalvageSegment(lg, dir, walName(0, 0))
+	require.ErrorIs(t, err, fileutil.ErrLocked)
+	require.NoFileExists(t, first+".corrupt")
+
+	require.NoError(t, w.ReleaseLockTo(5))
+	_, err = SalvageSegment(lg, dir, walName(0, 0))
+	require.NoError(t, err)
+	require.FileExists(t, first+".corrupt")
+}
+
+func TestDiff(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	create := func(term uint64, snaps ...walpb.Snapshot) string {
+		dir := t.TempDir()
+		w, err := Create(lg, dir, []byte("metadata"))
+		require.NoError(t, err)
+		ents := []raftpb.Entry{{Index: 1, Term: 1, Data: []byte("data1")}, {Index: 2, Term: term, Data: []byte("data2")}, {Index: 3, Term: term}}
+		require.NoError(t, w.Save(raftpb.HardState{Term: term, Commit: 3}, ents))
+		for _, snap := range snaps {
+			require.NoError(t, w.SaveSnapshot(snap))
+		}
+		require.NoError(t, w.Close())
+		return dir
+	}
+	snap := walpb.Snapshot{Index: 1, Term: 1, ConfState: &confState}
+
+	diffs, err := Diff(lg, create(1, snap), create(1, snap), walpb.Snapshot{})
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+
+	diffs, err = Diff(lg, create(1, snap), create(2), walpb.Snapshot{})
+	require.NoError(t, err)
+	require.Equal(t, []RecordDiff{
+		{Type: EntryType, Index: 2, A: "term=1 type=EntryNormal size=5", B: "term=2 type=EntryNormal size=5"},
+		{Type: StateType, A: (&raftpb.HardState{Term: 1, Commit: 3}).String(), B: (&raftpb.HardState{Term: 2, Commit: 3}).String()},
+		{Type: SnapshotType, Index: 1, A: snap.String()},
+	}, diffs)
+}
+
+func TestSalvageSegment(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	w, err := Create(lg, dir, []byte("metadata"))
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: uint64(i)}, []raftpb.Entry{{Index: uint64(i), Term: 1, Data: []byte(fmt.Sprintf("data%d", i))}}))
+	}
+	require.NoError(t, w.cut())
+	require.NoError(t, w.Save(raftpb.HardState{Term: 1, Commit: 4}, []raftpb.Entry{{Index: 4, Term: 1, Data: []byte("data4")}}))
+	require.NoError(t, w.Close())
+
+	first := filepath.Join(dir, walName(0, 0))
+	original, err := os.ReadFile(first)
+	require.NoError(t, err)
+	corrupted := bytes.Clone(original)
+	corrupted[bytes.Index(corrupted, []byte("data2"))] ^= 0xff
+	require.NoError(t, os.WriteFile(first, corrupted, fileutil.PrivateFileMode))
+
+	_, err = Verify(lg, dir, walpb.Snapshot{}, WithReadRepair())
+	require.Error(t, err)
+
+	kept, err := os.ReadFile(first + ".corrupt")
+	require.NoError(t, err)
+	require.Equal(t, corrupted, kept)
+	report, err := readSalvageable(lg, dir, walName(0, 0))
+	require.NoError(t, err)
+	require.NoError(t, report.Cause)
+	require.Equal(t, uint64(1), report.LastIndex)
+	require.Zero(t, report.LostBytes)
+
+	// the entries past the corruption are lost, so the WAL can't be read
+	// through the salvaged segment
+	w, err = Open(lg, dir, walpb.Snapshot{})
+	require.NoError(t, err)
+	_, _, _, err = w.ReadAll()
+	require.Error(t, err)
+	require.NoError(t, w.Close())
+
+	// a second salvage doesn't overwrite the kept original
+	require.NoError(t, os.WriteFile(first, corrupted, fileutil.PrivateFileMode))
+	_, err = SalvageSegment(lg, dir, walName(0, 0))
+	require.ErrorIs(t, err, os.ErrExist)
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/server/v3/storage/wal/walpb"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+// verifyWatermark records how far Verify got through a WAL, along with the
+// state it had accumulated, so that a later Verify can resume from there.
+type verifyWatermark struct {
+	SnapshotIndex uint64
+	SnapshotTerm  uint64
+	// Segment is the name of the file Offset points into.
+	Segment string
+	Offset  int64
+	// CRC is the running crc of the records up to Offset.
+	CRC           uint32
+	Metadata      []byte
+	State         raftpb.HardState
+	SnapshotFound bool
+	// ModTimes holds the modification time, in nanoseconds, of the files
+	// preceding Segment. The watermark is discarded if any of them changed.
+	ModTimes map[string]int64
+}
+
+// loadVerifyWatermark returns the watermark saved at path if it is still valid
+// for verifying the given files of walDir from snap, or nil otherwise.
+func loadVerifyWatermark(lg *zap.Logger, path, walDir string, snap walpb.Snapshot, names []string) *verifyWatermark {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			lg.Warn("failed to read verify watermark", zap.String("path", path), zap.Error(err))
+		}
+		return nil
+	}
+	var wm verifyWatermark
+	if err = json.Unmarshal(data, &wm); err != nil {
+		lg.Warn("failed to decode verify watermark", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	if err = wm.check(walDir, snap, names); err != nil {
+		lg.Info("discarding verify watermark", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	return &wm
+}
+
+func (wm *verifyWatermark) check(walDir string, snap walpb.Snapshot, names []string) error {
+	if wm.SnapshotIndex != snap.Index || wm.SnapshotTerm != snap.Term {
+		return fmt.Errorf("recorded for snapshot at index %d and term %d", wm.SnapshotIndex, wm.SnapshotTerm)
+	}
+	i := wm.segmentIndex(names)
+	if i < 0 {
+		return fmt.Errorf("segment %q not found", wm.Segment)
+	}
+	if i != len(wm.ModTimes) {
+		return fmt.Errorf("%d segments precede %q, %d recorded", i, wm.Segment, len(wm.ModTimes))
+	}
+	for _, name := range names[:i] {
+		fi, err := os.Stat(filepath.Join(walDir, name))
+		if err != nil {
+			return err
+		}
+		if modTime, ok := wm.ModTimes[name]; !ok || modTime != fi.ModTime().UnixNano() {
+			return fmt.Errorf("segment %q was modified", name)
+		}
+	}
+	fi, err := os.Stat(filepath.Join(walDir, wm.Segment))
+	if err != nil {
+		return err
+	}
+	if fi.Size() < wm.Offset {
+		return fmt.Errorf("segment %q is shorter than offset %d", wm.Segment, wm.Offset)
+	}
+	return nil
+}
+
+// segmentIndex returns the index of the watermark segment in names, or -1.
+func (wm *verifyWatermark) segmentIndex(names []string) int {
+	for i, name := range names {
+		if name == wm.Segment {
+			return i
+		}
+	}
+	return -1
+}
+
+// saveVerifyWatermark atomically replaces the watermark at path.
+func saveVerifyWatermark(path, walDir string, names []string, wm verifyWatermark) error {
+	wm.ModTimes = make(map[string]int64, len(names))
+	for _, name := range names {
+		if name == wm.Segment {
+			break
+		}
+		fi, err := os.Stat(filepath.Join(walDir, name))
+		if err != nil {
+			return err
+		}
+		wm.ModTimes[name] = fi.ModTime().UnixNano()
+	}
+	data, err := json.Marshal(wm)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, fileutil.PrivateFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
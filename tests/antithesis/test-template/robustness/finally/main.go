@@ -73,6 +73,7 @@ func validateReports(lg *zap.Logger, serversDataPath map[string]string, reports
 	validateConfig := validate.Config{ExpectRevisionUnique: tf.ExpectUniqueRevision}
 	result := validate.ValidateAndReturnVisualize(lg, validateConfig, reports, persistedRequests, 5*time.Minute)
 	assertResult(result.Assumptions, "Validation assumptions fulfilled")
+	assertResult(result.RevisionBounds, "Revision bounds validation passes")
 	if result.Linearization.Timeout {
 		assert.Unreachable("Linearization timeout", nil)
 	} else {
@@ -80,6 +81,12 @@ func validateReports(lg *zap.Logger, serversDataPath map[string]string, reports
 	}
 	assertResult(result.Watch, "Watch validation passes")
 	assertResult(result.Serializable, "Serializable validation passes")
+	assertResult(result.Session, "Session validation passes")
+	assertResult(result.Causality, "Causality validation passes")
+	assertResult(result.Compaction, "Compaction validation passes")
+	assertResult(result.Lease, "Lease validation passes")
+	assertResult(result.RevisionDensity, "Revision density validation passes")
+	assertResult(result.Txn, "Transaction branch validation passes")
 	lg.Info("Completed robustness validation")
 	return result
 }
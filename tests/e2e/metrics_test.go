@@ -169,7 +169,9 @@ func TestNoMetricsMissing(t *testing.T) {
 			"etcd_disk_backend_defrag_duration_seconds",
 			"etcd_disk_backend_snapshot_duration_seconds",
 			"etcd_disk_defrag_inflight",
+			"etcd_disk_wal_cuts_total",
 			"etcd_disk_wal_fsync_duration_seconds",
+			"etcd_disk_wal_save_bytes",
 			"etcd_disk_wal_write_bytes_total",
 			"etcd_disk_wal_write_duration_seconds",
 			"etcd_grpc_proxy_cache_hits_total",
@@ -24,6 +24,7 @@ import (
 	"go.uber.org/zap"
 
 	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/tests/v3/robustness/identity"
 	"go.etcd.io/etcd/tests/v3/robustness/model"
@@ -40,8 +41,12 @@ type RecordingClient struct {
 	// see https://github.com/golang/go/blob/master/src/time/time.go#L17
 	baseTime time.Time
 
-	watchMux        sync.Mutex
-	watchOperations []model.WatchOperation
+	watchMux             sync.Mutex
+	watchOperations      []model.WatchOperation
+	keepAliveMux         sync.Mutex
+	keepAliveOperations  []model.LeaseKeepAliveOperation
+	timeToLiveMux        sync.Mutex
+	timeToLiveOperations []model.LeaseTimeToLiveOperation
 	// mux ensures order of request appending.
 	kvMux        sync.Mutex
 	kvOperations *model.AppendableHistory
@@ -78,9 +83,11 @@ func (c *RecordingClient) Close() error {
 
 func (c *RecordingClient) Report() report.ClientReport {
 	return report.ClientReport{
-		ClientID: c.ID,
-		KeyValue: c.kvOperations.History.Operations(),
-		Watch:    c.watchOperations,
+		ClientID:        c.ID,
+		KeyValue:        c.kvOperations.History.Operations(),
+		Watch:           c.watchOperations,
+		LeaseKeepAlive:  c.keepAliveOperations,
+		LeaseTimeToLive: c.timeToLiveOperations,
 	}
 }
 
@@ -90,26 +97,64 @@ func (c *RecordingClient) Do(ctx context.Context, op clientv3.Op) (clientv3.OpRe
 
 func (c *RecordingClient) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
 	op := clientv3.OpGet(key, opts...)
-	return c.Range(ctx, key, string(op.RangeBytes()), op.Rev(), op.Limit())
+	if op.IsSortSet() {
+		// the sort options of an op can't be read to record them
+		panic("sorting not implemented, use RangeSorted")
+	}
+	return c.rangeWithOptions(ctx, model.RangeOptions{
+		Start:     key,
+		End:       string(op.RangeBytes()),
+		Limit:     op.Limit(),
+		KeysOnly:  op.IsKeysOnly(),
+		CountOnly: op.IsCountOnly(),
+	}, op.Rev())
 }
 
 func (c *RecordingClient) Range(ctx context.Context, start, end string, revision, limit int64) (*clientv3.GetResponse, error) {
+	return c.rangeWithOptions(ctx, model.RangeOptions{Start: start, End: end, Limit: limit}, revision)
+}
+
+// RangeKeysOnly is a range returning the keys without their values.
+func (c *RecordingClient) RangeKeysOnly(ctx context.Context, start, end string, revision, limit int64) (*clientv3.GetResponse, error) {
+	return c.rangeWithOptions(ctx, model.RangeOptions{Start: start, End: end, Limit: limit, KeysOnly: true}, revision)
+}
+
+// RangeCountOnly is a range returning only the number of keys in the range.
+func (c *RecordingClient) RangeCountOnly(ctx context.Context, start, end string, revision, limit int64) (*clientv3.GetResponse, error) {
+	return c.rangeWithOptions(ctx, model.RangeOptions{Start: start, End: end, Limit: limit, CountOnly: true}, revision)
+}
+
+// RangeSorted is a range returning the keys sorted by target in order.
+func (c *RecordingClient) RangeSorted(ctx context.Context, start, end string, revision, limit int64, target clientv3.SortTarget, order clientv3.SortOrder) (*clientv3.GetResponse, error) {
+	return c.rangeWithOptions(ctx, model.RangeOptions{Start: start, End: end, Limit: limit, SortTarget: target, SortOrder: order}, revision)
+}
+
+func (c *RecordingClient) rangeWithOptions(ctx context.Context, options model.RangeOptions, revision int64) (*clientv3.GetResponse, error) {
 	ops := []clientv3.OpOption{}
-	if end != "" {
-		ops = append(ops, clientv3.WithRange(end))
+	if options.End != "" {
+		ops = append(ops, clientv3.WithRange(options.End))
 	}
 	if revision != 0 {
 		ops = append(ops, clientv3.WithRev(revision))
 	}
-	if limit != 0 {
-		ops = append(ops, clientv3.WithLimit(limit))
+	if options.Limit != 0 {
+		ops = append(ops, clientv3.WithLimit(options.Limit))
+	}
+	if options.KeysOnly {
+		ops = append(ops, clientv3.WithKeysOnly())
+	}
+	if options.CountOnly {
+		ops = append(ops, clientv3.WithCountOnly())
+	}
+	if options.SortOrder != clientv3.SortNone || options.SortTarget != clientv3.SortByKey {
+		ops = append(ops, clientv3.WithSort(options.SortTarget, options.SortOrder))
 	}
 	c.kvMux.Lock()
 	defer c.kvMux.Unlock()
 	callTime := time.Since(c.baseTime)
-	resp, err := c.client.Get(ctx, start, ops...)
+	resp, err := c.client.Get(ctx, options.Start, ops...)
 	returnTime := time.Since(c.baseTime)
-	c.kvOperations.AppendRange(start, end, revision, limit, callTime, returnTime, resp, err)
+	c.kvOperations.AppendRangeWithOptions(options, revision, callTime, returnTime, resp, err)
 	return resp, err
 }
 
@@ -195,6 +240,54 @@ func (c *RecordingClient) LeaseRevoke(ctx context.Context, leaseID int64) (*clie
 	return resp, err
 }
 
+// LeaseKeepAliveOnce renews the lease once, recording the TTL returned by
+// etcd. Keepalives are not part of the KV history, as the model doesn't track
+// lease expiration.
+func (c *RecordingClient) LeaseKeepAliveOnce(ctx context.Context, leaseID int64) (*clientv3.LeaseKeepAliveResponse, error) {
+	c.keepAliveMux.Lock()
+	defer c.keepAliveMux.Unlock()
+	callTime := time.Since(c.baseTime)
+	resp, err := c.client.Lease.KeepAliveOnce(ctx, clientv3.LeaseID(leaseID))
+	returnTime := time.Since(c.baseTime)
+	op := model.LeaseKeepAliveOperation{
+		LeaseID: leaseID,
+		Call:    callTime,
+		Return:  returnTime,
+	}
+	switch {
+	case errors.Is(err, rpctypes.ErrLeaseNotFound):
+	case err != nil:
+		op.Error = err.Error()
+	case resp != nil:
+		op.TTL = resp.TTL
+	}
+	c.keepAliveOperations = append(c.keepAliveOperations, op)
+	return resp, err
+}
+
+// LeaseTimeToLive queries the remaining lease TTL, recording the TTL returned
+// by etcd.
+func (c *RecordingClient) LeaseTimeToLive(ctx context.Context, leaseID int64) (*clientv3.LeaseTimeToLiveResponse, error) {
+	c.timeToLiveMux.Lock()
+	defer c.timeToLiveMux.Unlock()
+	callTime := time.Since(c.baseTime)
+	resp, err := c.client.Lease.TimeToLive(ctx, clientv3.LeaseID(leaseID))
+	returnTime := time.Since(c.baseTime)
+	op := model.LeaseTimeToLiveOperation{
+		LeaseID: leaseID,
+		Call:    callTime,
+		Return:  returnTime,
+	}
+	switch {
+	case err != nil:
+		op.Error = err.Error()
+	case resp != nil:
+		op.TTL = resp.TTL
+	}
+	c.timeToLiveOperations = append(c.timeToLiveOperations, op)
+	return resp, err
+}
+
 func (c *RecordingClient) PutWithLease(ctx context.Context, key string, value string, leaseID int64) (*clientv3.PutResponse, error) {
 	opts := clientv3.WithLease(clientv3.LeaseID(leaseID))
 	c.kvMux.Lock()
@@ -290,6 +383,12 @@ func (c *RecordingClient) Watch(ctx context.Context, key string, rev int64, with
 	return c.watch(ctx, request)
 }
 
+// WatchWithRequest watches with all the options of the request, including
+// the event filters and fragmentation not exposed by Watch.
+func (c *RecordingClient) WatchWithRequest(ctx context.Context, request model.WatchRequest) clientv3.WatchChan {
+	return c.watch(ctx, request)
+}
+
 func (c *RecordingClient) watch(ctx context.Context, request model.WatchRequest) clientv3.WatchChan {
 	ops := []clientv3.OpOption{}
 	if request.WithPrefix {
@@ -304,6 +403,15 @@ func (c *RecordingClient) watch(ctx context.Context, request model.WatchRequest)
 	if request.WithPrevKV {
 		ops = append(ops, clientv3.WithPrevKV())
 	}
+	if request.FilterPut {
+		ops = append(ops, clientv3.WithFilterPut())
+	}
+	if request.FilterDelete {
+		ops = append(ops, clientv3.WithFilterDelete())
+	}
+	if request.Fragment {
+		ops = append(ops, clientv3.WithFragment())
+	}
 	respCh := make(chan clientv3.WatchResponse)
 
 	c.watchMux.Lock()
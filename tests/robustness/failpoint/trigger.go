@@ -46,7 +46,7 @@ func (t triggerDefrag) Trigger(ctx context.Context, _ *testing.T, member e2e.Etc
 	if err != nil && !connectionError(err) {
 		return nil, err
 	}
-	return nil, nil
+	return []report.ClientReport{cc.Report()}, nil
 }
 
 func (t triggerDefrag) Available(e2e.EtcdProcessClusterConfig, e2e.EtcdProcess, traffic.Profile) bool {
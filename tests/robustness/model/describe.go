@@ -214,6 +214,15 @@ func describeRangeRequest(opts RangeOptions, revision int64) string {
 	if opts.Limit != 0 {
 		kwargs = append(kwargs, fmt.Sprintf("limit=%d", opts.Limit))
 	}
+	if opts.KeysOnly {
+		kwargs = append(kwargs, "keys_only")
+	}
+	if opts.CountOnly {
+		kwargs = append(kwargs, "count_only")
+	}
+	if opts.SortOrder != clientv3.SortNone || opts.SortTarget != clientv3.SortByKey {
+		kwargs = append(kwargs, fmt.Sprintf("sort=%s %s", sortTargetNames[opts.SortTarget], sortOrderNames[opts.SortOrder]))
+	}
 	kwargsString := strings.Join(kwargs, ", ")
 	if kwargsString != "" {
 		kwargsString = ", " + kwargsString
@@ -241,7 +250,15 @@ func describeEtcdOperationResponse(op EtcdOperation, resp EtcdOperationResult) s
 	}
 }
 
+var (
+	sortOrderNames  = map[clientv3.SortOrder]string{clientv3.SortNone: "none", clientv3.SortAscend: "ascend", clientv3.SortDescend: "descend"}
+	sortTargetNames = map[clientv3.SortTarget]string{clientv3.SortByKey: "key", clientv3.SortByVersion: "version", clientv3.SortByCreateRevision: "create", clientv3.SortByModRevision: "mod", clientv3.SortByValue: "value"}
+)
+
 func describeRangeResponse(request RangeOptions, response RangeResponse) string {
+	if request.CountOnly {
+		return fmt.Sprintf("count: %d", response.Count)
+	}
 	if request.End != "" {
 		kvs := make([]string, len(response.KVs))
 		for i, kv := range response.KVs {
@@ -21,6 +21,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 func TestModelDescribe(t *testing.T) {
@@ -169,6 +170,21 @@ func TestModelDescribe(t *testing.T) {
 			resp:           rangeResponse(nil, 0, 16),
 			expectDescribe: `range("key16".."key16b", limit=2) -> [], count: 0, rev: 16`,
 		},
+		{
+			req:            EtcdRequest{Type: Range, Range: &RangeRequest{RangeOptions: RangeOptions{Start: "key17", Limit: 1, KeysOnly: true}}},
+			resp:           rangeResponse(nil, 0, 17),
+			expectDescribe: `get("key17", limit=1, keys_only) -> nil, rev: 17`,
+		},
+		{
+			req:            EtcdRequest{Type: Range, Range: &RangeRequest{RangeOptions: RangeOptions{Start: "key18", End: "key19", Limit: 1, CountOnly: true}}},
+			resp:           rangeResponse(nil, 2, 18),
+			expectDescribe: `list("key18", limit=1, count_only) -> count: 2, rev: 18`,
+		},
+		{
+			req:            EtcdRequest{Type: Range, Range: &RangeRequest{RangeOptions: RangeOptions{Start: "key19", End: "key1:", Limit: 1, SortOrder: clientv3.SortDescend, SortTarget: clientv3.SortByModRevision}}},
+			resp:           rangeResponse(nil, 0, 19),
+			expectDescribe: `list("key19", limit=1, sort=mod descend) -> [], count: 0, rev: 19`,
+		},
 	}
 	for _, tc := range tcs {
 		assert.Equal(t, tc.expectDescribe, NonDeterministicModel.DescribeOperation(tc.req, tc.resp))
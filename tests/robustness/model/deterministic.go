@@ -24,6 +24,7 @@ import (
 
 	"github.com/anishathalye/porcupine"
 
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/server/v3/storage/mvcc"
 )
 
@@ -275,7 +276,7 @@ func (s EtcdState) getRange(options RangeOptions) RangeResponse {
 	if options.End != "" {
 		var count int64
 		for k, v := range s.KeyValues {
-			if k >= options.Start && k < options.End {
+			if options.ContainsKey(k) {
 				response.KVs = append(response.KVs, KeyValue{Key: k, ValueRevision: v})
 				count++
 			}
@@ -283,9 +284,7 @@ func (s EtcdState) getRange(options RangeOptions) RangeResponse {
 		sort.Slice(response.KVs, func(j, k int) bool {
 			return response.KVs[j].Key < response.KVs[k].Key
 		})
-		if options.Limit != 0 && count > options.Limit {
-			response.KVs = response.KVs[:options.Limit]
-		}
+		response.KVs = sortAndLimit(response.KVs, options)
 		response.Count = count
 	} else {
 		value, ok := s.KeyValues[options.Start]
@@ -297,9 +296,67 @@ func (s EtcdState) getRange(options RangeOptions) RangeResponse {
 			response.Count = 1
 		}
 	}
+	if options.KeysOnly {
+		for i := range response.KVs {
+			response.KVs[i].Value = ValueOrHash{}
+		}
+	}
+	if options.CountOnly {
+		response.KVs = []KeyValue{}
+	}
 	return response
 }
 
+// sortAndLimit sorts and limits the keys of a range, given in key order, the
+// way etcd does. With a sort order, all the keys are sorted before the limit
+// applies. Without one, keys are only sorted, in ascending order, for a target
+// other than the key, and only the first key past the limit along with the
+// keys within it, as etcd reads no more before sorting.
+func sortAndLimit(kvs []KeyValue, options RangeOptions) []KeyValue {
+	if options.Limit > 0 && options.SortOrder == clientv3.SortNone && int64(len(kvs)) > options.Limit+1 {
+		kvs = kvs[:options.Limit+1]
+	}
+	order := options.SortOrder
+	switch {
+	case options.SortTarget != clientv3.SortByKey && order == clientv3.SortNone:
+		order = clientv3.SortAscend
+	case options.SortTarget == clientv3.SortByKey && order == clientv3.SortAscend:
+		order = clientv3.SortNone
+	}
+	// sort.Sort like etcd, so keys comparing equal end up in the same order
+	var sorter sort.Interface = kvSorter{kvs: kvs, target: options.SortTarget}
+	switch order {
+	case clientv3.SortAscend:
+		sort.Sort(sorter)
+	case clientv3.SortDescend:
+		sort.Sort(sort.Reverse(sorter))
+	}
+	if options.Limit > 0 && int64(len(kvs)) > options.Limit {
+		kvs = kvs[:options.Limit]
+	}
+	return kvs
+}
+
+type kvSorter struct {
+	kvs    []KeyValue
+	target clientv3.SortTarget
+}
+
+func (s kvSorter) Len() int      { return len(s.kvs) }
+func (s kvSorter) Swap(i, j int) { s.kvs[i], s.kvs[j] = s.kvs[j], s.kvs[i] }
+func (s kvSorter) Less(i, j int) bool {
+	switch s.target {
+	case clientv3.SortByVersion:
+		return s.kvs[i].Version < s.kvs[j].Version
+	case clientv3.SortByModRevision:
+		return s.kvs[i].ModRevision < s.kvs[j].ModRevision
+	case clientv3.SortByKey:
+		return s.kvs[i].Key < s.kvs[j].Key
+	default:
+		panic(fmt.Sprintf("unsupported sort target %d", s.target))
+	}
+}
+
 func detachFromOldLease(s EtcdState, key string) EtcdState {
 	if oldLeaseID, ok := s.KeyLeases[key]; ok {
 		delete(s.Leases[oldLeaseID].Keys, key)
@@ -139,6 +139,30 @@ var commonTestScenarios = []modelTestCase{
 			}, 3, 4), expectFailure: true},
 		},
 	},
+	{
+		name: "Range end should follow etcd semantics",
+		operations: []testOperation{
+			{req: putRequest("key1", "1"), resp: putResponse(2)},
+			{req: putRequest("key2", "2"), resp: putResponse(3)},
+			{req: putRequest("key3", "3"), resp: putResponse(4)},
+			{req: rangeRequest("key2", "", 0), resp: rangeResponse([]*mvccpb.KeyValue{
+				{Key: []byte("key2"), Value: []byte("2"), ModRevision: 3, Version: 1},
+			}, 1, 4)},
+			{req: rangeRequest("key2", "key3", 0), resp: rangeResponse([]*mvccpb.KeyValue{
+				{Key: []byte("key2"), Value: []byte("2"), ModRevision: 3, Version: 1},
+			}, 1, 4)},
+			{req: rangeRequest("key2", "\x00", 0), resp: rangeResponse([]*mvccpb.KeyValue{
+				{Key: []byte("key2"), Value: []byte("2"), ModRevision: 3, Version: 1},
+				{Key: []byte("key3"), Value: []byte("3"), ModRevision: 4, Version: 1},
+			}, 2, 4)},
+			{req: rangeRequest("\x00", "\x00", 0), resp: rangeResponse([]*mvccpb.KeyValue{
+				{Key: []byte("key1"), Value: []byte("1"), ModRevision: 2, Version: 1},
+				{Key: []byte("key2"), Value: []byte("2"), ModRevision: 3, Version: 1},
+				{Key: []byte("key3"), Value: []byte("3"), ModRevision: 4, Version: 1},
+			}, 3, 4)},
+			{req: rangeRequest("key3", "key1", 0), resp: rangeResponse([]*mvccpb.KeyValue{}, 0, 4)},
+		},
+	},
 	{
 		name: "Range response data should match large put",
 		operations: []testOperation{
@@ -59,7 +59,16 @@ func NewAppendableHistory(ids identity.Provider) *AppendableHistory {
 }
 
 func (h *AppendableHistory) AppendRange(startKey, endKey string, revision, limit int64, start, end time.Duration, resp *clientv3.GetResponse, err error) {
-	request := staleRangeRequest(startKey, endKey, limit, revision)
+	h.appendRange(staleRangeRequest(startKey, endKey, limit, revision), start, end, resp, err)
+}
+
+// AppendRangeWithOptions appends a range with options beyond its bounds and
+// limit, e.g. returning the keys without their values.
+func (h *AppendableHistory) AppendRangeWithOptions(options RangeOptions, revision int64, start, end time.Duration, resp *clientv3.GetResponse, err error) {
+	h.appendRange(EtcdRequest{Type: Range, Range: &RangeRequest{RangeOptions: options, Revision: revision}}, start, end, resp, err)
+}
+
+func (h *AppendableHistory) appendRange(request EtcdRequest, start, end time.Duration, resp *clientv3.GetResponse, err error) {
 	if err != nil {
 		h.appendFailed(request, start, end, err)
 		return
@@ -98,11 +107,13 @@ func (h *AppendableHistory) AppendPutWithLease(key, value string, leaseID int64,
 }
 
 func (h *AppendableHistory) AppendLeaseGrant(start, end time.Duration, resp *clientv3.LeaseGrantResponse, err error) {
-	var leaseID int64
+	var leaseID, ttl int64
 	if resp != nil {
 		leaseID = int64(resp.ID)
+		ttl = resp.TTL
 	}
 	request := leaseGrantRequest(leaseID)
+	request.LeaseGrant.TTL = ttl
 	if err != nil {
 		h.appendFailed(request, start, end, err)
 		return
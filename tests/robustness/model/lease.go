@@ -0,0 +1,44 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "time"
+
+// LeaseKeepAliveOperation is a single lease keepalive. Keepalives don't change
+// the state tracked by the model, so they are recorded separately from the
+// key value operations, to validate lease expiration.
+type LeaseKeepAliveOperation struct {
+	LeaseID int64
+	Call    time.Duration
+	Return  time.Duration
+	// TTL is the lease TTL in seconds after the keepalive, 0 if etcd reported
+	// that the lease was not found.
+	TTL   int64  `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+// LeaseTimeToLiveOperation is a single query of the remaining lease TTL.
+// Like keepalives, queries are recorded separately from the key value
+// operations, to validate that the reported TTL doesn't increase without the
+// lease being renewed.
+type LeaseTimeToLiveOperation struct {
+	LeaseID int64
+	Call    time.Duration
+	Return  time.Duration
+	// TTL is the remaining lease TTL in seconds, -1 if etcd reported that the
+	// lease was not found.
+	TTL   int64  `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
@@ -51,6 +51,11 @@ func (r *EtcdReplay) StateForRevision(revision int64) (EtcdState, error) {
 	return r.revisionToEtcdState[revision], nil
 }
 
+// LastState returns the state after all persisted requests.
+func (r *EtcdReplay) LastState() EtcdState {
+	return r.revisionToEtcdState[len(r.revisionToEtcdState)-1]
+}
+
 func (r *EtcdReplay) EventsForWatch(watch WatchRequest) (events []PersistedEvent) {
 	for _, e := range r.Events {
 		if e.Revision < watch.Revision || !e.Match(watch) {
@@ -150,7 +155,12 @@ type Event struct {
 	Value ValueOrHash   `json:",omitempty"`
 }
 
+// Match returns whether the event is delivered to a watch with the request,
+// as its key is watched and its type isn't filtered out.
 func (e Event) Match(request WatchRequest) bool {
+	if request.FilterPut && e.Type == PutOperation || request.FilterDelete && e.Type == DeleteOperation {
+		return false
+	}
 	if request.WithPrefix {
 		return strings.HasPrefix(e.Key, request.Key)
 	}
@@ -163,4 +173,10 @@ type WatchRequest struct {
 	WithPrefix         bool
 	WithProgressNotify bool
 	WithPrevKV         bool
+	// FilterPut and FilterDelete filter out put and delete events.
+	FilterPut    bool
+	FilterDelete bool
+	// Fragment allows etcd to split large responses, the client reassembles
+	// them, so the responses recorded hold complete revisions either way.
+	Fragment bool
 }
@@ -19,6 +19,8 @@ import (
 	"hash/fnv"
 	"maps"
 	"reflect"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // RevisionForNonLinearizableResponse is a fake revision value used to
@@ -71,6 +73,32 @@ type RangeOptions struct {
 	Start string
 	End   string
 	Limit int64
+	// KeysOnly ranges return the keys without their values.
+	KeysOnly bool `json:",omitempty"`
+	// CountOnly ranges return the number of keys in the range, without the
+	// keys, regardless of the limit.
+	CountOnly bool `json:",omitempty"`
+	// SortOrder and SortTarget sort the keys of the range like etcd does, by
+	// key in ascending order if unset. Sorting by create revision or value
+	// isn't modeled, as the model tracks neither create revisions nor the
+	// values of large puts.
+	SortOrder  clientv3.SortOrder  `json:",omitempty"`
+	SortTarget clientv3.SortTarget `json:",omitempty"`
+}
+
+// ContainsKey returns whether the key is in the range, with the semantics of
+// the range end of etcd: an empty End selects only the Start key, an End of
+// "\x00" all the keys from Start, and other ends the keys from Start up to End
+// excluded, none if End isn't past Start.
+func (o RangeOptions) ContainsKey(key string) bool {
+	switch o.End {
+	case "":
+		return key == o.Start
+	case "\x00":
+		return key >= o.Start
+	default:
+		return key >= o.Start && key < o.End
+	}
 }
 
 type PutOptions struct {
@@ -112,6 +140,9 @@ const (
 
 type LeaseGrantRequest struct {
 	LeaseID int64
+	// TTL is the lease TTL in seconds granted by etcd, it's not used by the
+	// model, only to validate lease expiration.
+	TTL int64 `json:",omitempty"`
 }
 type LeaseRevokeRequest struct {
 	LeaseID int64
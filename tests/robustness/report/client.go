@@ -33,6 +33,10 @@ type ClientReport struct {
 	ClientID int
 	KeyValue []porcupine.Operation
 	Watch    []model.WatchOperation
+	// LeaseKeepAlive are the lease keepalives done by the client.
+	LeaseKeepAlive []model.LeaseKeepAliveOperation
+	// LeaseTimeToLive are the lease TTL queries done by the client.
+	LeaseTimeToLive []model.LeaseTimeToLiveOperation
 }
 
 func (r ClientReport) WatchEventCount() int {
@@ -62,6 +66,16 @@ func persistClientReports(lg *zap.Logger, path string, reports []ClientReport) e
 		} else {
 			lg.Info("no watch operations for client, skip persisting", zap.Int("client-id", r.ClientID))
 		}
+		if len(r.LeaseKeepAlive) != 0 {
+			if err := persistLeaseKeepAliveOperations(lg, filepath.Join(clientDir, "keepalive.json"), r.LeaseKeepAlive); err != nil {
+				return err
+			}
+		}
+		if len(r.LeaseTimeToLive) != 0 {
+			if err := persistLeaseTimeToLiveOperations(lg, filepath.Join(clientDir, "timetolive.json"), r.LeaseTimeToLive); err != nil {
+				return err
+			}
+		}
 		if len(r.KeyValue) != 0 {
 			if err := persistKeyValueOperations(lg, filepath.Join(clientDir, "operations.json"), r.KeyValue); err != nil {
 				return err
@@ -109,6 +123,14 @@ func loadClientReport(path string) (report ClientReport, err error) {
 	if err != nil {
 		return report, err
 	}
+	report.LeaseKeepAlive, err = loadLeaseKeepAliveOperations(filepath.Join(path, "keepalive.json"))
+	if err != nil {
+		return report, err
+	}
+	report.LeaseTimeToLive, err = loadLeaseTimeToLiveOperations(filepath.Join(path, "timetolive.json"))
+	if err != nil {
+		return report, err
+	}
 	return report, nil
 }
 
@@ -137,6 +159,56 @@ func loadWatchOperations(path string) (operations []model.WatchOperation, err er
 	return operations, nil
 }
 
+func loadLeaseKeepAliveOperations(path string) (operations []model.LeaseKeepAliveOperation, err error) {
+	_, err = os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open lease keepalive file: %q, err: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lease keepalive file: %q, err: %w", path, err)
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var keepAlive model.LeaseKeepAliveOperation
+		err = decoder.Decode(&keepAlive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode lease keepalive, err: %w", err)
+		}
+		operations = append(operations, keepAlive)
+	}
+	return operations, nil
+}
+
+func loadLeaseTimeToLiveOperations(path string) (operations []model.LeaseTimeToLiveOperation, err error) {
+	_, err = os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open lease time to live file: %q, err: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lease time to live file: %q, err: %w", path, err)
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var timeToLive model.LeaseTimeToLiveOperation
+		err = decoder.Decode(&timeToLive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode lease time to live, err: %w", err)
+		}
+		operations = append(operations, timeToLive)
+	}
+	return operations, nil
+}
+
 func loadKeyValueOperations(path string) (operations []porcupine.Operation, err error) {
 	_, err = os.Stat(path)
 	if err != nil {
@@ -192,6 +264,42 @@ func persistWatchOperations(lg *zap.Logger, path string, responses []model.Watch
 	return nil
 }
 
+func persistLeaseKeepAliveOperations(lg *zap.Logger, path string, operations []model.LeaseKeepAliveOperation) error {
+	lg.Info("Saving lease keepalives", zap.String("path", path))
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to save lease keepalives: %w", err)
+	}
+	defer file.Close()
+	for _, op := range operations {
+		data, err := json.MarshalIndent(op, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode lease keepalive: %w", err)
+		}
+		file.Write(data)
+		file.WriteString("\n")
+	}
+	return nil
+}
+
+func persistLeaseTimeToLiveOperations(lg *zap.Logger, path string, operations []model.LeaseTimeToLiveOperation) error {
+	lg.Info("Saving lease time to live queries", zap.String("path", path))
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to save lease time to live queries: %w", err)
+	}
+	defer file.Close()
+	for _, op := range operations {
+		data, err := json.MarshalIndent(op, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode lease time to live: %w", err)
+		}
+		file.Write(data)
+		file.WriteString("\n")
+	}
+	return nil
+}
+
 func persistKeyValueOperations(lg *zap.Logger, path string, operations []porcupine.Operation) error {
 	lg.Info("Saving operation history", zap.String("path", path))
 	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o755)
@@ -210,6 +318,20 @@ func persistKeyValueOperations(lg *zap.Logger, path string, operations []porcupi
 	return nil
 }
 
+// PersistOperations saves the KV operation history to path, in the format used
+// for the operations of a client report, so it can be loaded by LoadOperations.
+func PersistOperations(lg *zap.Logger, path string, operations []porcupine.Operation) error {
+	return persistKeyValueOperations(lg, path, operations)
+}
+
+// LoadOperations loads a KV operation history saved by PersistOperations.
+func LoadOperations(path string) ([]porcupine.Operation, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open KV operation file: %q, err: %w", path, err)
+	}
+	return loadKeyValueOperations(path)
+}
+
 func OperationsMaxRevision(reports []ClientReport) int64 {
 	var maxRevision int64
 	for _, r := range reports {
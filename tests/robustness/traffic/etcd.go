@@ -38,15 +38,20 @@ var (
 		// Please keep the sum of weights equal 100.
 		requests: []random.ChoiceWeight[etcdRequestType]{
 			{Choice: Get, Weight: 15},
-			{Choice: List, Weight: 15},
+			{Choice: List, Weight: 11},
+			{Choice: SortedList, Weight: 2},
 			{Choice: StaleGet, Weight: 10},
-			{Choice: StaleList, Weight: 10},
+			{Choice: StaleList, Weight: 8},
+			{Choice: CountList, Weight: 2},
+			{Choice: FilteredWatch, Weight: 2},
 			{Choice: Delete, Weight: 5},
 			{Choice: MultiOpTxn, Weight: 5},
 			{Choice: PutWithLease, Weight: 5},
 			{Choice: LeaseRevoke, Weight: 5},
+			{Choice: LeaseKeepAlive, Weight: 3},
+			{Choice: LeaseTimeToLive, Weight: 2},
 			{Choice: CompareAndSet, Weight: 5},
-			{Choice: Put, Weight: 20},
+			{Choice: Put, Weight: 15},
 			{Choice: LargePut, Weight: 5},
 		},
 	}
@@ -91,18 +96,23 @@ func (t etcdTraffic) ExpectUniqueRevision() bool {
 type etcdRequestType string
 
 const (
-	Get           etcdRequestType = "get"
-	StaleGet      etcdRequestType = "staleGet"
-	List          etcdRequestType = "list"
-	StaleList     etcdRequestType = "staleList"
-	Put           etcdRequestType = "put"
-	LargePut      etcdRequestType = "largePut"
-	Delete        etcdRequestType = "delete"
-	MultiOpTxn    etcdRequestType = "multiOpTxn"
-	PutWithLease  etcdRequestType = "putWithLease"
-	LeaseRevoke   etcdRequestType = "leaseRevoke"
-	CompareAndSet etcdRequestType = "compareAndSet"
-	Defragment    etcdRequestType = "defragment"
+	Get             etcdRequestType = "get"
+	StaleGet        etcdRequestType = "staleGet"
+	List            etcdRequestType = "list"
+	StaleList       etcdRequestType = "staleList"
+	CountList       etcdRequestType = "countList"
+	SortedList      etcdRequestType = "sortedList"
+	Put             etcdRequestType = "put"
+	LargePut        etcdRequestType = "largePut"
+	Delete          etcdRequestType = "delete"
+	MultiOpTxn      etcdRequestType = "multiOpTxn"
+	PutWithLease    etcdRequestType = "putWithLease"
+	LeaseRevoke     etcdRequestType = "leaseRevoke"
+	LeaseKeepAlive  etcdRequestType = "leaseKeepAlive"
+	LeaseTimeToLive etcdRequestType = "leaseTimeToLive"
+	FilteredWatch   etcdRequestType = "filteredWatch"
+	CompareAndSet   etcdRequestType = "compareAndSet"
+	Defragment      etcdRequestType = "defragment"
 )
 
 func (t etcdTraffic) Name() string {
@@ -203,6 +213,13 @@ type etcdTrafficClient struct {
 	leaseStorage identity.LeaseIDStorage
 }
 
+// sortTargets and sortOrders are picked from for sorted lists, the targets
+// limited to those the model can validate, see model.RangeOptions.
+var (
+	sortTargets = []clientv3.SortTarget{clientv3.SortByKey, clientv3.SortByVersion, clientv3.SortByModRevision}
+	sortOrders  = []clientv3.SortOrder{clientv3.SortNone, clientv3.SortAscend, clientv3.SortDescend}
+)
+
 func (c etcdTrafficClient) Request(ctx context.Context, request etcdRequestType, lastRev int64) (rev int64, err error) {
 	opCtx, cancel := context.WithTimeout(ctx, RequestTimeout)
 	defer cancel()
@@ -234,6 +251,19 @@ func (c etcdTrafficClient) Request(ctx context.Context, request etcdRequestType,
 		if resp != nil {
 			rev = resp.Header.Revision
 		}
+	case SortedList:
+		var resp *clientv3.GetResponse
+		resp, err = c.client.RangeSorted(opCtx, c.keyStore.GetPrefix(), clientv3.GetPrefixRangeEnd(c.keyStore.GetPrefix()), lastRev, rand.Int63n(3), sortTargets[rand.Intn(len(sortTargets))], sortOrders[rand.Intn(len(sortOrders))])
+		if resp != nil {
+			rev = resp.Header.Revision
+		}
+	case CountList:
+		var resp *clientv3.GetResponse
+		// the limit doesn't apply to the count
+		resp, err = c.client.RangeCountOnly(opCtx, c.keyStore.GetPrefix(), clientv3.GetPrefixRangeEnd(c.keyStore.GetPrefix()), lastRev, 1)
+		if resp != nil {
+			rev = resp.Header.Revision
+		}
 	case Put:
 		var resp *clientv3.PutResponse
 		resp, err = c.client.Put(opCtx, c.keyStore.GetKey(), fmt.Sprintf("%d", c.idProvider.NewRequestID()))
@@ -323,6 +353,26 @@ func (c etcdTrafficClient) Request(ctx context.Context, request etcdRequestType,
 				rev = resp.Header.Revision
 			}
 		}
+	case LeaseKeepAlive:
+		leaseID := c.leaseStorage.LeaseID(c.client.ID)
+		if leaseID != 0 {
+			var resp *clientv3.LeaseKeepAliveResponse
+			resp, err = c.client.LeaseKeepAliveOnce(opCtx, leaseID)
+			if resp != nil {
+				rev = resp.ResponseHeader.Revision
+			}
+		}
+	case LeaseTimeToLive:
+		leaseID := c.leaseStorage.LeaseID(c.client.ID)
+		if leaseID != 0 {
+			var resp *clientv3.LeaseTimeToLiveResponse
+			resp, err = c.client.LeaseTimeToLive(opCtx, leaseID)
+			if resp != nil {
+				rev = resp.ResponseHeader.Revision
+			}
+		}
+	case FilteredWatch:
+		rev = c.filteredWatch(opCtx, lastRev)
 	case Defragment:
 		var resp *clientv3.DefragmentResponse
 		resp, err = c.client.Defragment(opCtx)
@@ -335,6 +385,36 @@ func (c etcdTrafficClient) Request(ctx context.Context, request etcdRequestType,
 	return rev, err
 }
 
+// filteredWatchTimeout bounds how long filteredWatch waits for events, so it
+// doesn't stall the client for the whole RequestTimeout when there are none.
+const filteredWatchTimeout = 50 * time.Millisecond
+
+// filteredWatch watches the keys from after the revision, filtering out either
+// puts or deletes, with fragmented responses, and returns after the first
+// response or filteredWatchTimeout. The events etcd reassembles from the
+// fragments are validated against the filter like those of any other watch.
+// It returns the revision of the response, 0 if there was none.
+func (c etcdTrafficClient) filteredWatch(ctx context.Context, lastRev int64) (rev int64) {
+	ctx, cancel := context.WithTimeout(ctx, filteredWatchTimeout)
+	defer cancel()
+	request := model.WatchRequest{
+		Key:        c.keyStore.GetPrefix(),
+		Revision:   lastRev + 1,
+		WithPrefix: true,
+		Fragment:   true,
+	}
+	if rand.Int()%2 == 0 {
+		request.FilterPut = true
+	} else {
+		request.FilterDelete = true
+	}
+	resp, ok := <-c.client.WatchWithRequest(ctx, request)
+	if !ok || resp.Err() != nil {
+		return 0
+	}
+	return resp.Header.Revision
+}
+
 func (c etcdTrafficClient) pickMultiTxnOps(keyStore *keyStore) (ops []clientv3.Op) {
 	opTypes := make([]model.OperationType, 4)
 
@@ -0,0 +1,182 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+var errBrokeCausality = errors.New("broke Causality - operations ordered by the revisions they observed and by client order form a cycle")
+
+// validateCausality checks that the order implied by response revisions is
+// acyclic. It doesn't depend on operation timestamps, so it is not affected by
+// clock skew between clients. The order is built from:
+//   - writes, ordered by the revision they produced,
+//   - a write producing a revision happens before any operation observing it,
+//   - a linearizable read observing a revision happens before the write
+//     producing the next revision,
+//   - operations of a client happen in the order the client issued them.
+func validateCausality(lg *zap.Logger, reports []report.ClientReport) Result {
+	lg.Info("Validating causality")
+	start := time.Now()
+	err := validateCausalityError(lg, reports)
+	if err != nil {
+		lg.Error("Causality validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Causality validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateCausalityError(lg *zap.Logger, reports []report.ClientReport) error {
+	var operations []porcupine.Operation
+	var revisions []int64
+	lastClientOperation := map[int]int{}
+	graph := map[int][]int{}
+	writes := map[int64][]int{}
+	reads := map[int64][]int{}
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			request := op.Input.(model.EtcdRequest)
+			response := op.Output.(model.MaybeEtcdResponse)
+			if response.Error != "" || response.Persisted || response.ClientError != "" || response.Revision <= 0 {
+				continue
+			}
+			if request.Type != model.Range && request.Type != model.Txn {
+				continue
+			}
+			node := len(operations)
+			operations = append(operations, op)
+			revisions = append(revisions, response.Revision)
+			if last, ok := lastClientOperation[op.ClientId]; ok {
+				graph[last] = append(graph[last], node)
+			}
+			lastClientOperation[op.ClientId] = node
+			switch {
+			case isWrite(request, response):
+				writes[response.Revision] = append(writes[response.Revision], node)
+			case request.Type == model.Range && request.Range.Revision != 0:
+				// Stale reads are not required to observe the latest revision.
+			default:
+				reads[response.Revision] = append(reads[response.Revision], node)
+			}
+		}
+	}
+
+	writeRevisions := make([]int64, 0, len(writes))
+	for rev := range writes {
+		writeRevisions = append(writeRevisions, rev)
+	}
+	sort.Slice(writeRevisions, func(i, j int) bool { return writeRevisions[i] < writeRevisions[j] })
+	nextWrite := func(rev int64) []int {
+		i := sort.Search(len(writeRevisions), func(i int) bool { return writeRevisions[i] > rev })
+		if i == len(writeRevisions) {
+			return nil
+		}
+		return writes[writeRevisions[i]]
+	}
+	for node, rev := range revisions {
+		if slices.Contains(writes[rev], node) {
+			continue
+		}
+		for _, write := range writes[rev] {
+			graph[write] = append(graph[write], node)
+		}
+	}
+	for rev, nodes := range writes {
+		for _, node := range nodes {
+			graph[node] = append(graph[node], nextWrite(rev)...)
+		}
+	}
+	for rev, nodes := range reads {
+		for _, node := range nodes {
+			graph[node] = append(graph[node], nextWrite(rev)...)
+		}
+	}
+
+	if node, ok := findCycle(graph, len(operations)); ok {
+		lg.Error("Found causality cycle", zap.Int("client", operations[node].ClientId), zap.Any("request", operations[node].Input), zap.Any("response", operations[node].Output))
+		return errBrokeCausality
+	}
+	return nil
+}
+
+// isWrite returns whether the operation produced the revision of its response.
+func isWrite(request model.EtcdRequest, response model.MaybeEtcdResponse) bool {
+	if request.Type != model.Txn || response.Txn == nil {
+		return false
+	}
+	ops := request.Txn.OperationsOnSuccess
+	if response.Txn.Failure {
+		ops = request.Txn.OperationsOnFailure
+	}
+	for i, op := range ops {
+		switch op.Type {
+		case model.PutOperation:
+			return true
+		case model.DeleteOperation:
+			if i < len(response.Txn.Results) && response.Txn.Results[i].Deleted != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findCycle topologically sorts the graph and returns a node that is part of
+// a cycle or reachable from one, if the sort doesn't cover all nodes.
+func findCycle(graph map[int][]int, nodeCount int) (int, bool) {
+	inDegree := make([]int, nodeCount)
+	for _, edges := range graph {
+		for _, to := range edges {
+			inDegree[to]++
+		}
+	}
+	var queue []int
+	for node, degree := range inDegree {
+		if degree == 0 {
+			queue = append(queue, node)
+		}
+	}
+	sorted := 0
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		sorted++
+		for _, to := range graph[node] {
+			inDegree[to]--
+			if inDegree[to] == 0 {
+				queue = append(queue, to)
+			}
+		}
+	}
+	if sorted == nodeCount {
+		return 0, false
+	}
+	for node, degree := range inDegree {
+		if degree != 0 {
+			return node, true
+		}
+	}
+	return 0, false
+}
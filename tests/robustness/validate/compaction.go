@@ -0,0 +1,190 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/server/v3/storage/mvcc"
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+var (
+	errReadAfterCompaction  = errors.New("broke Compaction - read returned data of a revision compacted before the read started")
+	errCompactedWithoutCall = errors.New("broke Compaction - read failed as compacted, but no compaction of its revision could have happened before the read returned")
+	errCompactedLiveKey     = errors.New("broke Compaction - read after compaction missed a key live at the compaction revision")
+)
+
+// validateCompaction checks stale reads against the compactions of the
+// history: a read called after a compaction returned must fail as compacted
+// if it targets a revision below the compaction revision, and a read can only
+// fail as compacted if a compaction of its revision could have happened before
+// the read returned. Reading at the compaction revision itself still returns
+// data, as compaction keeps the latest version of each key at that revision.
+// Operations are referred to by their index in the reports, counting key value
+// operations of all clients in order.
+func validateCompaction(lg *zap.Logger, reports []report.ClientReport) Result {
+	lg.Info("Validating compaction")
+	start := time.Now()
+	err := validateCompactionError(lg, reports)
+	if err != nil {
+		lg.Error("Compaction validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Compaction validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateCompactionError(lg *zap.Logger, reports []report.ClientReport) error {
+	var operations []porcupine.Operation
+	for _, r := range reports {
+		operations = append(operations, r.KeyValue...)
+	}
+	var compactions []int
+	for i, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		// A compaction that failed with an error known to etcd didn't happen.
+		if request.Type == model.Compact && response.ClientError == "" {
+			compactions = append(compactions, i)
+		}
+	}
+	for i, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		if request.Type != model.Range || request.Range.Revision == 0 || response.Error != "" || response.Persisted {
+			continue
+		}
+		switch {
+		case response.ClientError == mvcc.ErrCompacted.Error():
+			if !compactedBefore(operations, compactions, request.Range.Revision, op.Return) {
+				lg.Error("Read failed as compacted without compaction", zap.Int("read-index", i), zap.Int("client", op.ClientId), zap.Int64("revision", request.Range.Revision))
+				return errCompactedWithoutCall
+			}
+		case response.ClientError == "" && response.Range != nil:
+			for _, c := range compactions {
+				compaction := operations[c]
+				compactResponse := compaction.Output.(model.MaybeEtcdResponse)
+				if compactResponse.Error != "" || compaction.Return >= op.Call {
+					continue
+				}
+				if compactRevision := compaction.Input.(model.EtcdRequest).Compact.Revision; request.Range.Revision < compactRevision {
+					lg.Error("Read returned data of compacted revision", zap.Int("read-index", i), zap.Int("compaction-index", c), zap.Int("client", op.ClientId), zap.Int64("revision", request.Range.Revision), zap.Int64("compact-revision", compactRevision))
+					return errReadAfterCompaction
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// compactedBefore returns whether any of the compactions, including failed ones
+// that might have taken effect, compacted the revision and was called before
+// the given time.
+func compactedBefore(operations []porcupine.Operation, compactions []int, revision, before int64) bool {
+	for _, c := range compactions {
+		compaction := operations[c]
+		if compaction.Call < before && revision < compaction.Input.(model.EtcdRequest).Compact.Revision {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCompactionLiveKeys checks that compactions only remove superseded
+// versions of keys. A read called after a compaction returned, at a revision
+// at or past the compaction revision, must return each key in its range that
+// was live at the compaction revision and left unchanged up to the revision
+// of the read.
+func validateCompactionLiveKeys(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) Result {
+	lg.Info("Validating compaction keeps live keys")
+	start := time.Now()
+	err := validateCompactionLiveKeysError(lg, reports, replay)
+	if err != nil {
+		lg.Error("Compaction live keys validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Compaction live keys validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateCompactionLiveKeysError(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) error {
+	var compactions []porcupine.Operation
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			request := op.Input.(model.EtcdRequest)
+			response := op.Output.(model.MaybeEtcdResponse)
+			if request.Type == model.Compact && response.Error == "" && response.ClientError == "" && !response.Persisted {
+				compactions = append(compactions, op)
+			}
+		}
+	}
+	for _, compaction := range compactions {
+		compactRevision := compaction.Input.(model.EtcdRequest).Compact.Revision
+		compacted, err := replay.StateForRevision(compactRevision)
+		if err != nil {
+			continue
+		}
+		for _, r := range reports {
+			for _, op := range r.KeyValue {
+				request := op.Input.(model.EtcdRequest)
+				response := op.Output.(model.MaybeEtcdResponse)
+				if request.Type != model.Range || response.Range == nil || response.Error != "" || response.Persisted || op.Call <= compaction.Return {
+					continue
+				}
+				revision := request.Range.Revision
+				if revision <= 0 {
+					revision = response.Revision
+				}
+				if revision < compactRevision {
+					continue
+				}
+				state, err := replay.StateForRevision(revision)
+				if err != nil {
+					continue
+				}
+				if key, ok := liveKeysReturned(compacted, state, request.Range.RangeOptions, response.Range.KVs); !ok {
+					lg.Error("Read after compaction missed live key", zap.Int("client", op.ClientId), zap.String("key", key), zap.Int64("compact-revision", compactRevision), zap.Int64("revision", revision))
+					return errCompactedLiveKey
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// liveKeysReturned checks that kvs include the keys in range live in the
+// compacted state and unchanged in the state read. A limited read only
+// returns the first keys of the range, so keys after the last one returned
+// aren't checked. It returns the first key missing.
+func liveKeysReturned(compacted, state model.EtcdState, options model.RangeOptions, kvs []model.KeyValue) (string, bool) {
+	returned := map[string]bool{}
+	for _, kv := range kvs {
+		returned[kv.Key] = true
+	}
+	limited := options.Limit > 0 && int64(len(kvs)) >= options.Limit
+	for key, value := range compacted.KeyValues {
+		if !options.ContainsKey(key) || (limited && key > kvs[len(kvs)-1].Key) {
+			continue
+		}
+		if current, ok := state.KeyValues[key]; ok && current.ModRevision == value.ModRevision && !returned[key] {
+			return key, false
+		}
+	}
+	return "", true
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/anishathalye/porcupine"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+)
+
+// FinalState replays the writes of the operations in the order of the revisions
+// they produced and returns the resulting keyspace, to be compared against the
+// state of the cluster after the run. It fails if the outcome or revision of a
+// write is unknown, so failed writes should be patched with their persisted
+// revision, or removed if they were not persisted, before calling it.
+func FinalState(operations []porcupine.Operation) (map[string]string, error) {
+	type write struct {
+		request  model.EtcdRequest
+		revision int64
+		// produced is whether the write produced its revision, so it has to be
+		// applied before other operations observing the same revision.
+		produced bool
+	}
+	var writes []write
+	for _, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		switch request.Type {
+		case model.Txn, model.LeaseGrant, model.LeaseRevoke:
+		default:
+			continue
+		}
+		if request.IsRead() {
+			continue
+		}
+		switch {
+		case response.Persisted && response.PersistedRevision != 0:
+			writes = append(writes, write{request: request, revision: response.PersistedRevision, produced: true})
+		case response.Error != "" || response.Persisted:
+			return nil, fmt.Errorf("unknown revision of request %v of client %d", request, op.ClientId)
+		case request.Type == model.Txn:
+			if isWrite(request, response) {
+				writes = append(writes, write{request: request, revision: response.Revision, produced: true})
+			}
+		default:
+			writes = append(writes, write{request: request, revision: response.Revision})
+		}
+	}
+	sort.SliceStable(writes, func(i, j int) bool {
+		if writes[i].revision != writes[j].revision {
+			return writes[i].revision < writes[j].revision
+		}
+		return writes[i].produced && !writes[j].produced
+	})
+
+	state := model.DeterministicModel.Init().(model.EtcdState)
+	for _, w := range writes {
+		state, _ = state.Step(w.request)
+	}
+	keyValues := make(map[string]string, len(state.KeyValues))
+	for key, value := range state.KeyValues {
+		if value.Value.Hash != 0 {
+			return nil, fmt.Errorf("value of key %q is only known by its hash", key)
+		}
+		keyValues[key] = value.Value.Value
+	}
+	return keyValues, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+)
+
+// KeyFilter is a key, or a prefix of keys, to focus validation of serializable
+// reads on. Linearization can't be limited to some keys, as writes to other
+// keys change the revision observed by all operations.
+type KeyFilter struct {
+	Key string
+	// Prefix makes the filter match all keys starting with Key.
+	Prefix bool
+}
+
+// filter returns the operations touching keys matched by the filter.
+func (f KeyFilter) filter(lg *zap.Logger, operations []porcupine.Operation) []porcupine.Operation {
+	lg.Warn("Validating serializable operations touching filtered keys only", zap.String("key", f.Key), zap.Bool("prefix", f.Prefix))
+	filtered := make([]porcupine.Operation, 0, len(operations))
+	for _, op := range operations {
+		if f.matches(op.Input.(model.EtcdRequest)) {
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// matches returns whether the request ranges over, puts or deletes a key
+// matched by the filter.
+func (f KeyFilter) matches(request model.EtcdRequest) bool {
+	switch request.Type {
+	case model.Range:
+		return f.overlaps(request.Range.RangeOptions)
+	case model.Txn:
+		for _, op := range append(request.Txn.OperationsOnSuccess, request.Txn.OperationsOnFailure...) {
+			switch op.Type {
+			case model.RangeOperation:
+				if f.overlaps(op.Range) {
+					return true
+				}
+			case model.PutOperation:
+				if f.overlaps(model.RangeOptions{Start: op.Put.Key}) {
+					return true
+				}
+			case model.DeleteOperation:
+				if f.overlaps(model.RangeOptions{Start: op.Delete.Key}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// overlaps returns whether any key in the range is matched by the filter.
+func (f KeyFilter) overlaps(options model.RangeOptions) bool {
+	end := options.End
+	if end == "" {
+		end = options.Start + "\x00"
+	}
+	filterEnd := f.Key + "\x00"
+	if f.Prefix {
+		filterEnd = clientv3.GetPrefixRangeEnd(f.Key)
+	}
+	// An end of "\x00" means there is no end.
+	return (end == "\x00" || f.Key < end) && (filterEnd == "\x00" || options.Start < filterEnd)
+}
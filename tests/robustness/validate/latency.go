@@ -0,0 +1,66 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+// OperationLatency describes a single client operation and the time it took,
+// measured from its call to its return.
+type OperationLatency struct {
+	ClientID int
+	Call     int64
+	Return   int64
+	Latency  time.Duration
+	Request  model.EtcdRequest
+	Response model.MaybeEtcdResponse
+}
+
+// latencyOutliers returns the n operations with the highest latency, slowest
+// first. They are only diagnostic, slow operations often precede or overlap
+// with the part of the history that fails validation.
+func latencyOutliers(lg *zap.Logger, reports []report.ClientReport, n int) []OperationLatency {
+	if n <= 0 {
+		return nil
+	}
+	var latencies []OperationLatency
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			latencies = append(latencies, OperationLatency{
+				ClientID: op.ClientId,
+				Call:     op.Call,
+				Return:   op.Return,
+				Latency:  time.Duration(op.Return - op.Call),
+				Request:  op.Input.(model.EtcdRequest),
+				Response: op.Output.(model.MaybeEtcdResponse),
+			})
+		}
+	}
+	sort.SliceStable(latencies, func(i, j int) bool { return latencies[i].Latency > latencies[j].Latency })
+	if len(latencies) > n {
+		latencies = latencies[:n]
+	}
+	for _, l := range latencies {
+		lg.Info("Operation latency outlier", zap.Int("client", l.ClientID), zap.Duration("latency", l.Latency), zap.Int64("call", l.Call), zap.Int64("return", l.Return), zap.Any("request", l.Request))
+	}
+	return latencies
+}
@@ -0,0 +1,383 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+var (
+	errLeaseExpiredEarly     = errors.New("broke Lease - keepalive reported lease as not found before its TTL passed")
+	errLeasedKeyDeletedEarly = errors.New("broke Lease - key attached to a lease was deleted before the lease TTL passed")
+	errLeaseRevokeMismatch   = errors.New("broke Lease - revoking a lease must delete exactly the keys attached to it")
+	errLeaseTTLIncreased     = errors.New("broke Lease - remaining lease TTL increased without the lease being renewed")
+	errLeaseDefragMismatch   = errors.New("broke Lease - defragmentation changed lease attachments")
+)
+
+// validateLease checks that leases don't expire before the TTL granted by the
+// last successful grant or keepalive. etcd starts counting the TTL when it
+// handles the request, so the lease is guaranteed to live at least until the
+// request was called plus the TTL. Leases revoked by clients are skipped. It
+// also checks that the remaining TTL reported for a lease doesn't increase
+// without the lease being renewed.
+func validateLease(lg *zap.Logger, reports []report.ClientReport) Result {
+	lg.Info("Validating lease")
+	start := time.Now()
+	err := validateLeaseError(lg, reports)
+	if err != nil {
+		lg.Error("Lease validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Lease validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateLeaseError(lg *zap.Logger, reports []report.ClientReport) error {
+	revoked := map[int64]bool{}
+	// deadlines holds the time until which a lease is guaranteed not to expire.
+	deadlines := map[int64]time.Duration{}
+	extend := func(leaseID int64, call time.Duration, ttl int64) {
+		if deadline := call + time.Duration(ttl)*time.Second; deadline > deadlines[leaseID] {
+			deadlines[leaseID] = deadline
+		}
+	}
+	// clientRevisions holds revisions of writes done by clients, deletes in
+	// other revisions are done by lease expiration.
+	clientRevisions := map[int64]bool{}
+	// puts holds successful puts to a key, unknownKeys keys written by requests
+	// with unknown outcome.
+	puts := map[string][]leasedPut{}
+	unknownKeys := map[string]bool{}
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			request := op.Input.(model.EtcdRequest)
+			response := op.Output.(model.MaybeEtcdResponse)
+			switch request.Type {
+			case model.LeaseRevoke:
+				revoked[request.LeaseRevoke.LeaseID] = true
+			case model.LeaseGrant:
+				if response.Error == "" && request.LeaseGrant.TTL > 0 {
+					extend(request.LeaseGrant.LeaseID, time.Duration(op.Call), request.LeaseGrant.TTL)
+				}
+			case model.Txn:
+				if response.Error != "" || response.Persisted {
+					for _, txnOp := range append(request.Txn.OperationsOnSuccess, request.Txn.OperationsOnFailure...) {
+						switch txnOp.Type {
+						case model.PutOperation:
+							unknownKeys[txnOp.Put.Key] = true
+						case model.DeleteOperation:
+							unknownKeys[txnOp.Delete.Key] = true
+						}
+					}
+					continue
+				}
+				if !isWrite(request, response) {
+					continue
+				}
+				clientRevisions[response.Revision] = true
+				ops := request.Txn.OperationsOnSuccess
+				if response.Txn.Failure {
+					ops = request.Txn.OperationsOnFailure
+				}
+				for _, txnOp := range ops {
+					if txnOp.Type == model.PutOperation {
+						puts[txnOp.Put.Key] = append(puts[txnOp.Put.Key], leasedPut{revision: response.Revision, leaseID: txnOp.Put.LeaseID})
+					}
+				}
+			}
+		}
+	}
+	for _, r := range reports {
+		for _, keepAlive := range r.LeaseKeepAlive {
+			if keepAlive.Error == "" && keepAlive.TTL > 0 {
+				extend(keepAlive.LeaseID, keepAlive.Call, keepAlive.TTL)
+			}
+		}
+	}
+
+	for _, r := range reports {
+		for _, keepAlive := range r.LeaseKeepAlive {
+			// A keepalive arriving after expiry doesn't extend the lease, it's
+			// only invalid if the lease should still be alive.
+			if keepAlive.Error != "" || keepAlive.TTL > 0 || revoked[keepAlive.LeaseID] {
+				continue
+			}
+			if deadline, ok := deadlines[keepAlive.LeaseID]; ok && keepAlive.Return < deadline {
+				lg.Error("Keepalive reported lease as not found before its deadline", zap.Int("client", r.ClientID), zap.Int64("lease-id", keepAlive.LeaseID), zap.Duration("return", keepAlive.Return), zap.Duration("deadline", deadline))
+				return errLeaseExpiredEarly
+			}
+		}
+	}
+
+	for _, r := range reports {
+		for _, watch := range r.Watch {
+			for _, resp := range watch.Responses {
+				for _, event := range resp.Events {
+					if event.Type != model.DeleteOperation || clientRevisions[event.Revision] || unknownKeys[event.Key] {
+						continue
+					}
+					leaseID := leaseAt(puts[event.Key], event.Revision)
+					if leaseID == 0 || revoked[leaseID] {
+						continue
+					}
+					// The event was received after the key was deleted.
+					if deadline, ok := deadlines[leaseID]; ok && resp.Time < deadline {
+						lg.Error("Leased key deleted before lease deadline", zap.Int("client", r.ClientID), zap.String("key", event.Key), zap.Int64("revision", event.Revision), zap.Int64("lease-id", leaseID), zap.Duration("time", resp.Time), zap.Duration("deadline", deadline))
+						return errLeasedKeyDeletedEarly
+					}
+				}
+			}
+		}
+	}
+	return validateLeaseTTLOrder(lg, reports)
+}
+
+// leaseTTLGranularity is the slack allowed between remaining TTLs, as etcd
+// reports them in whole seconds.
+const leaseTTLGranularity = 1
+
+// validateLeaseTTLOrder checks that the remaining TTL reported by queries of
+// a lease doesn't increase, unless a grant or keepalive of the lease might
+// have happened between the queries. Only queries that don't overlap in time
+// are compared. A leader change also refreshes leases, which isn't recorded,
+// so TTLs shouldn't be queried when failpoints change the leader.
+func validateLeaseTTLOrder(lg *zap.Logger, reports []report.ClientReport) error {
+	// renewals holds the time ranges of requests that might have renewed a lease.
+	renewals := map[int64][]timeRange{}
+	queries := map[int64][]model.LeaseTimeToLiveOperation{}
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			request := op.Input.(model.EtcdRequest)
+			if request.Type == model.LeaseGrant {
+				renewals[request.LeaseGrant.LeaseID] = append(renewals[request.LeaseGrant.LeaseID], timeRange{call: time.Duration(op.Call), ret: time.Duration(op.Return)})
+			}
+		}
+		for _, keepAlive := range r.LeaseKeepAlive {
+			renewals[keepAlive.LeaseID] = append(renewals[keepAlive.LeaseID], timeRange{call: keepAlive.Call, ret: keepAlive.Return})
+		}
+		for _, query := range r.LeaseTimeToLive {
+			if query.Error == "" && query.TTL > 0 {
+				queries[query.LeaseID] = append(queries[query.LeaseID], query)
+			}
+		}
+	}
+	for leaseID, ops := range queries {
+		sort.Slice(ops, func(i, j int) bool {
+			return ops[i].Call < ops[j].Call
+		})
+		for i, prev := range ops {
+			for _, next := range ops[i+1:] {
+				if next.Call < prev.Return || next.TTL <= prev.TTL+leaseTTLGranularity {
+					continue
+				}
+				if renewedBetween(renewals[leaseID], prev.Call, next.Return) {
+					continue
+				}
+				lg.Error("Remaining lease TTL increased", zap.Int64("lease-id", leaseID), zap.Int64("ttl", prev.TTL), zap.Duration("return", prev.Return), zap.Int64("next-ttl", next.TTL), zap.Duration("next-call", next.Call))
+				return errLeaseTTLIncreased
+			}
+		}
+	}
+	return nil
+}
+
+type timeRange struct {
+	call, ret time.Duration
+}
+
+// renewedBetween returns whether any of the renewals might have been handled
+// by etcd between from and to.
+func renewedBetween(renewals []timeRange, from, to time.Duration) bool {
+	for _, r := range renewals {
+		if r.call < to && r.ret > from {
+			return true
+		}
+	}
+	return false
+}
+
+type leasedPut struct {
+	revision int64
+	leaseID  int64
+}
+
+// leaseAt returns the lease the key was attached to just before the revision.
+func leaseAt(puts []leasedPut, revision int64) (leaseID int64) {
+	var last int64
+	for _, put := range puts {
+		if put.revision < revision && put.revision > last {
+			last, leaseID = put.revision, put.leaseID
+		}
+	}
+	return leaseID
+}
+
+// validateLeaseAttachment checks that revoking a lease, by a client or by its
+// expiration, deletes exactly the keys attached to it, as observed by watches.
+// Keys are attached by puts with the lease, and detached by puts without it or
+// with another lease, which are tracked by the replay. The lease of keys
+// returned by reads isn't checked, as the history doesn't record it. It also
+// checks that defragmentation doesn't change lease attachments.
+func validateLeaseAttachment(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) Result {
+	lg.Info("Validating lease attachment")
+	start := time.Now()
+	err := validateLeaseAttachmentError(lg, reports, replay)
+	if err != nil {
+		lg.Error("Lease attachment validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Lease attachment validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateLeaseAttachmentError(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) error {
+	before, err := replay.StateForRevision(1)
+	if err != nil {
+		return nil
+	}
+	for revision := int64(2); ; revision++ {
+		after, err := replay.StateForRevision(revision)
+		if err != nil {
+			break
+		}
+		for leaseID := range before.Leases {
+			if _, found := after.Leases[leaseID]; found {
+				continue
+			}
+			attached := attachedKeys(before, leaseID)
+			if len(attached) == 0 {
+				continue
+			}
+			for _, r := range reports {
+				for _, watch := range r.Watch {
+					if err := validateRevokeEvents(lg, watch, attached, leaseID, revision); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		before = after
+	}
+	return validateDefragLeaseAttachment(lg, reports, replay)
+}
+
+// attachedKeys returns the existing keys attached to the lease in the state.
+// A revoke deleting no key doesn't get its own revision, so a lease is only
+// known to be revoked at a revision if it had keys attached.
+func attachedKeys(state model.EtcdState, leaseID int64) map[string]bool {
+	keys := map[string]bool{}
+	for key := range state.Leases[leaseID].Keys {
+		if _, exists := state.KeyValues[key]; exists {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// validateRevokeEvents checks that the events a watch received at the revision
+// of a revoke are the deletes of the attached keys it watches, and nothing else.
+// Watches that received no event at that revision are skipped.
+func validateRevokeEvents(lg *zap.Logger, watch model.WatchOperation, attached map[string]bool, leaseID, revision int64) error {
+	deleted := map[string]bool{}
+	for _, resp := range watch.Responses {
+		for _, event := range resp.Events {
+			if event.Revision != revision {
+				continue
+			}
+			if event.Type != model.DeleteOperation || !attached[event.Key] {
+				lg.Error("Lease revoke included event of key not attached to the lease", zap.Int64("lease-id", leaseID), zap.Int64("revision", revision), zap.Any("event", event))
+				return errLeaseRevokeMismatch
+			}
+			deleted[event.Key] = true
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	for key := range attached {
+		if !deleted[key] && (model.Event{Type: model.DeleteOperation, Key: key}).Match(watch.Request) {
+			lg.Error("Lease revoke didn't delete key attached to the lease", zap.Int64("lease-id", leaseID), zap.Int64("revision", revision), zap.String("key", key))
+			return errLeaseRevokeMismatch
+		}
+	}
+	return nil
+}
+
+// validateDefragLeaseAttachment checks that the lease attachments replayed
+// immediately before and after each defragmentation, requested by clients or
+// by failpoints, are identical. Defragmentation doesn't get a revision, so
+// before is the last revision observed by requests that returned before the
+// defragmentation was called, and after the first revision observed by
+// requests called after it returned. Keys written between the two revisions
+// are skipped, as writes, and revokes deleting the keys, change attachments.
+func validateDefragLeaseAttachment(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) error {
+	for _, r := range reports {
+		for _, defrag := range r.KeyValue {
+			if defrag.Input.(model.EtcdRequest).Type != model.Defragment {
+				continue
+			}
+			before, after := defragRevisions(reports, defrag)
+			if before <= 0 || after < before {
+				continue
+			}
+			beforeState, err := replay.StateForRevision(before)
+			if err != nil {
+				continue
+			}
+			afterState, err := replay.StateForRevision(after)
+			if err != nil {
+				continue
+			}
+			for key, value := range beforeState.KeyValues {
+				if afterValue, ok := afterState.KeyValues[key]; !ok || afterValue.ModRevision != value.ModRevision {
+					continue
+				}
+				leaseID := beforeState.KeyLeases[key]
+				afterLeaseID := afterState.KeyLeases[key]
+				if _, attached := afterState.Leases[afterLeaseID].Keys[key]; leaseID != afterLeaseID || afterLeaseID != 0 && !attached {
+					lg.Error("Lease attachment changed by defragmentation", zap.String("key", key), zap.Int64("lease-id", leaseID), zap.Int64("after-lease-id", afterLeaseID), zap.Int64("before-revision", before), zap.Int64("after-revision", after))
+					return errLeaseDefragMismatch
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// defragRevisions returns the last revision observed before the
+// defragmentation and the first one observed after it, 0 if there is none.
+func defragRevisions(reports []report.ClientReport, defrag porcupine.Operation) (before, after int64) {
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			response := op.Output.(model.MaybeEtcdResponse)
+			if response.Error != "" || response.Persisted || response.Revision <= 0 {
+				continue
+			}
+			if op.Return < defrag.Call && response.Revision > before {
+				before = response.Revision
+			}
+			if op.Call > defrag.Return && (after == 0 || response.Revision < after) {
+				after = response.Revision
+			}
+		}
+	}
+	return before, after
+}
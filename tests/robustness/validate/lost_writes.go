@@ -0,0 +1,101 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/anishathalye/porcupine"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+)
+
+var ErrLostWrite = errors.New("acknowledged write was lost")
+
+// VerifyNoLostWrites checks that the last write acknowledged to a client for
+// each key is reflected in the state replayed from the persisted requests at
+// the final revision: the key holds the value of the put, or is absent after
+// the delete, unless a later persisted request, like a write with unknown
+// outcome or the revoke of the lease of the key, changed it since.
+func VerifyNoLostWrites(operations []porcupine.Operation, replay *model.EtcdReplay) error {
+	// writes holds the last acknowledged write to each key.
+	writes := map[string]ackedWrite{}
+	for _, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		if request.Type != model.Txn || request.IsRead() || response.ClientError != "" || response.Error != "" || response.Persisted {
+			continue
+		}
+		ops := request.Txn.OperationsOnSuccess
+		if response.Txn.Failure {
+			ops = request.Txn.OperationsOnFailure
+		}
+		for _, txnOp := range ops {
+			var w ackedWrite
+			switch txnOp.Type {
+			case model.PutOperation:
+				w = ackedWrite{key: txnOp.Put.Key, value: txnOp.Put.Value, revision: response.Revision, clientID: op.ClientId}
+			case model.DeleteOperation:
+				w = ackedWrite{key: txnOp.Delete.Key, delete: true, revision: response.Revision, clientID: op.ClientId}
+			default:
+				continue
+			}
+			if last, ok := writes[w.key]; !ok || w.revision >= last.revision {
+				writes[w.key] = w
+			}
+		}
+	}
+
+	final := replay.LastState()
+	for key, w := range writes {
+		kv, found := final.KeyValues[key]
+		switch {
+		case w.revision > final.Revision:
+			return fmt.Errorf("%w: write of key %q by client %d acknowledged at revision %d, after the final persisted revision %d", ErrLostWrite, key, w.clientID, w.revision, final.Revision)
+		case changedAfter(replay.Events, key, w.revision):
+		case w.delete && found:
+			return fmt.Errorf("%w: key %q deleted by client %d at revision %d is present at the final revision %d", ErrLostWrite, key, w.clientID, w.revision, final.Revision)
+		case !w.delete && !found:
+			return fmt.Errorf("%w: key %q put by client %d at revision %d is missing at the final revision %d", ErrLostWrite, key, w.clientID, w.revision, final.Revision)
+		case !w.delete && (kv.ModRevision != w.revision || kv.Value != w.value):
+			return fmt.Errorf("%w: key %q put by client %d at revision %d has value of revision %d at the final revision %d", ErrLostWrite, key, w.clientID, w.revision, kv.ModRevision, final.Revision)
+		}
+	}
+	return nil
+}
+
+type ackedWrite struct {
+	key      string
+	value    model.ValueOrHash
+	delete   bool
+	revision int64
+	clientID int
+}
+
+// changedAfter returns whether any persisted event changed the key after the
+// revision.
+func changedAfter(events []model.PersistedEvent, key string, revision int64) bool {
+	i := sort.Search(len(events), func(i int) bool {
+		return events[i].Revision > revision
+	})
+	for _, event := range events[i:] {
+		if event.Key == key {
+			return true
+		}
+	}
+	return false
+}
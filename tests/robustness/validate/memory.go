@@ -0,0 +1,77 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+)
+
+var ErrMemoryBudgetExceeded = errors.New("linearization aborted, memory budget exceeded")
+
+// memoryCheckInterval is how often memory usage is compared to the budget
+// while linearizing.
+var memoryCheckInterval = time.Second
+
+// withMemoryBudget returns the model with a Step that rejects all transitions
+// once the heap grows above budget, so porcupine quickly gives up the search
+// and releases its memory. porcupine can't be cancelled otherwise. exceeded
+// reports whether that happened, stop has to be called once the check is done.
+// A zero budget disables the check.
+func withMemoryBudget(lg *zap.Logger, m porcupine.Model, budget uint64) (budgeted porcupine.Model, exceeded func() bool, stop func()) {
+	if budget == 0 {
+		return m, func() bool { return false }, func() {}
+	}
+	var over atomic.Bool
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			runtime.ReadMemStats(&stats)
+			lg.Debug("Linearization in progress", zap.Uint64("heap-bytes", stats.HeapAlloc), zap.Uint64("memory-budget", budget))
+			if stats.HeapAlloc > budget {
+				lg.Warn("Memory budget exceeded, aborting linearization", zap.Uint64("heap-bytes", stats.HeapAlloc), zap.Uint64("memory-budget", budget))
+				over.Store(true)
+				return
+			}
+		}
+	}()
+	step := m.Step
+	m.Step = func(state, input, output any) (bool, any) {
+		if over.Load() {
+			return false, state
+		}
+		return step(state, input, output)
+	}
+	stop = func() {
+		close(done)
+		<-finished
+	}
+	return m, over.Load, stop
+}
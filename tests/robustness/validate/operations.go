@@ -16,6 +16,8 @@ package validate
 
 import (
 	"errors"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/anishathalye/porcupine"
@@ -28,26 +30,68 @@ import (
 var (
 	errRespNotMatched         = errors.New("response didn't match expected")
 	errFutureRevRespRequested = errors.New("request about a future rev with response")
+	errKeyBeforeCreate        = errors.New("response included key before the put creating it")
+	errFalseEmptyRange        = errors.New("empty response for range that had keys")
+	errKeyAfterDelete         = errors.New("response included key after the delete removing it")
+	errMixedRevisions         = errors.New("response included keys from different revisions")
+	errUncommittedRead        = errors.New("response included key value not written by any committed request")
+	errHeaderRevisionBehind   = errors.New("response header revision lower than the requested revision")
+	errKeyMetadataMismatch    = errors.New("response included key with mod revision or version different from the replay")
 )
 
-func validateLinearizableOperationsAndVisualize(lg *zap.Logger, operations []porcupine.Operation, timeout time.Duration) LinearizationResult {
-	lg.Info("Validating linearizable operations", zap.Duration("timeout", timeout))
+func validateLinearizableOperationsAndVisualize(lg *zap.Logger, operations []porcupine.Operation, timeout time.Duration, memoryBudget uint64) LinearizationResult {
+	return validateLinearizableOperationsWithModel(lg, model.NonDeterministicModel, operations, timeout, memoryBudget)
+}
+
+// validateCandidateModels checks linearization of operations against each of
+// the candidate models. It only characterizes the history, the verdict is
+// given by the linearization against model.NonDeterministicModel. Models are
+// checked concurrently, so all of them together take at most the timeout, and
+// the memory budget, measured on the whole heap, bounds them together too.
+func validateCandidateModels(lg *zap.Logger, candidates []CandidateModel, operations []porcupine.Operation, timeout time.Duration, memoryBudget uint64) []CandidateModelResult {
+	results := make([]CandidateModelResult, len(candidates))
+	var wg sync.WaitGroup
+	for i, candidate := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lg := lg.With(zap.String("model", candidate.Name))
+			lg.Info("Validating linearizable operations against candidate model")
+			results[i] = CandidateModelResult{
+				Name:   candidate.Name,
+				Result: validateLinearizableOperationsWithModel(lg, candidate.Model, operations, timeout, memoryBudget),
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func validateLinearizableOperationsWithModel(lg *zap.Logger, m porcupine.Model, operations []porcupine.Operation, timeout time.Duration, memoryBudget uint64) LinearizationResult {
+	lg.Info("Validating linearizable operations", zap.Duration("timeout", timeout), zap.Uint64("memory-budget", memoryBudget))
 	start := time.Now()
-	check, info := porcupine.CheckOperationsVerbose(model.NonDeterministicModel, operations, timeout)
+	budgeted, exceeded, stop := withMemoryBudget(lg, m, memoryBudget)
+	check, info := porcupine.CheckOperationsVerbose(budgeted, operations, timeout)
+	stop()
 	result := LinearizationResult{
 		Info:  info,
-		Model: model.NonDeterministicModel,
+		Model: m,
 	}
-	switch check {
-	case porcupine.Ok:
+	switch {
+	case exceeded():
+		result.Status = Failure
+		result.Message = ErrMemoryBudgetExceeded.Error()
+		result.MemoryBudgetExceeded = true
+		lg.Error("Linearization aborted as memory budget was exceeded", zap.Duration("duration", time.Since(start)))
+	case check == porcupine.Ok:
 		result.Status = Success
 		lg.Info("Linearization success", zap.Duration("duration", time.Since(start)))
-	case porcupine.Unknown:
+	case check == porcupine.Unknown:
 		result.Status = Failure
 		result.Message = "timed out"
 		result.Timeout = true
 		lg.Error("Linearization timed out", zap.Duration("duration", time.Since(start)))
-	case porcupine.Illegal:
+	case check == porcupine.Illegal:
 		result.Status = Failure
 		result.Message = "illegal"
 		lg.Error("Linearization illegal", zap.Duration("duration", time.Since(start)))
@@ -94,6 +138,67 @@ func validateSerializableRead(lg *zap.Logger, replay *model.EtcdReplay, request
 		return errFutureRevRespRequested
 	}
 
+	// The keys of a read at a revision reflect the state at that revision,
+	// but the header holds the revision of the member when it served the
+	// read. It might be past the requested revision, it can't be behind it.
+	// Serializable reads might be served by a lagging member, so the header
+	// isn't required to be the last revision.
+	if request.Range.Revision > 0 && response.Revision < request.Range.Revision {
+		lg.Error("Failed validating serializable operation", zap.Any("request", request), zap.Int64("header-revision", response.Revision))
+		return errHeaderRevisionBehind
+	}
+
+	if response.EtcdResponse.Range != nil && request.Range.Revision > 0 {
+		for _, kv := range response.EtcdResponse.Range.KVs {
+			if !keyCommitted(replay, kv, request.Range.KeysOnly) {
+				lg.Error("Failed validating serializable operation", zap.Any("request", request), zap.String("key", kv.Key), zap.Int64("mod-revision", kv.ModRevision))
+				return errUncommittedRead
+			}
+			createRevision := keyCreateRevision(replay, kv.Key, kv.ModRevision)
+			if createRevision > request.Range.Revision {
+				lg.Error("Failed validating serializable operation", zap.Any("request", request), zap.String("key", kv.Key), zap.Int64("create-revision", createRevision))
+				return errKeyBeforeCreate
+			}
+			deleteRevision := keyDeleteRevision(replay, kv.Key, request.Range.Revision)
+			if deleteRevision > kv.ModRevision {
+				lg.Error("Failed validating serializable operation", zap.Any("request", request), zap.String("key", kv.Key), zap.Int64("mod-revision", kv.ModRevision), zap.Int64("delete-revision", deleteRevision))
+				return errKeyAfterDelete
+			}
+		}
+		if revision, ok := singleRevision(replay, response.EtcdResponse.Range.KVs); !ok {
+			lg.Error("Failed validating serializable operation", zap.Any("request", request), zap.Any("response", response), zap.Int64("effective-revision", revision))
+			return errMixedRevisions
+		}
+		for _, kv := range response.EtcdResponse.Range.KVs {
+			// The metadata is checked per key, so a bookkeeping bug returning
+			// the right value is reported as such. Create revisions are not
+			// recorded in reports, the version covers them, as it counts the
+			// puts since the key was created.
+			if expected, ok := state.KeyValues[kv.Key]; ok && (kv.ModRevision != expected.ModRevision || kv.Version != expected.Version) {
+				lg.Error("Failed validating serializable operation", zap.Any("request", request), zap.String("key", kv.Key), zap.Int64("mod-revision", kv.ModRevision), zap.Int64("version", kv.Version), zap.Int64("expected-mod-revision", expected.ModRevision), zap.Int64("expected-version", expected.Version))
+				return errKeyMetadataMismatch
+			}
+		}
+	}
+
+	// An empty response is checked explicitly, so a read dropping all keys is
+	// reported as such, and not only as a difference from the expected response.
+	if response.EtcdResponse.Range != nil && len(response.EtcdResponse.Range.KVs) == 0 && response.EtcdResponse.Range.Count == 0 {
+		for key := range state.KeyValues {
+			if request.Range.ContainsKey(key) {
+				lg.Error("Failed validating serializable operation", zap.Any("request", request), zap.String("key", key), zap.Int64("revision", state.Revision))
+				return errFalseEmptyRange
+			}
+		}
+	}
+
+	// The expected response is sorted and limited like etcd does, by default
+	// to the first keys of the range in key order, while its count covers all
+	// keys in the range, so the count of a limited read is validated too. Keys
+	// only reads are compared without values, as the model drops them too, and
+	// count only reads by their count alone, the limit not applying to it. Only
+	// the range is compared, as the model reports the requested revision in
+	// the header.
 	_, expectResp := state.Step(request)
 
 	if diff := cmp.Diff(response.EtcdResponse.Range, expectResp.Range); diff != "" {
@@ -102,3 +207,84 @@ func validateSerializableRead(lg *zap.Logger, replay *model.EtcdReplay, request
 	}
 	return nil
 }
+
+// keyCreateRevision returns the revision of the put that created the key
+// version with the given mod revision. As the key might have been deleted and
+// recreated, it is the last create of the key up to modRevision. If no such
+// put was persisted, modRevision is returned.
+func keyCreateRevision(replay *model.EtcdReplay, key string, modRevision int64) int64 {
+	i := sort.Search(len(replay.Events), func(i int) bool {
+		return replay.Events[i].Revision > modRevision
+	})
+	for i--; i >= 0; i-- {
+		event := replay.Events[i]
+		if event.Key != key {
+			continue
+		}
+		if event.Type == model.DeleteOperation {
+			break
+		}
+		if event.IsCreate {
+			return event.Revision
+		}
+	}
+	return modRevision
+}
+
+// keyCommitted returns whether the key value was written by a persisted put
+// at its mod revision. As the replay only holds committed requests, a key value
+// not found in it is a dirty read of a write that never committed, or that
+// committed with another value or at another revision. The value of a key
+// returned by a keys only range isn't compared.
+func keyCommitted(replay *model.EtcdReplay, kv model.KeyValue, keysOnly bool) bool {
+	i := sort.Search(len(replay.Events), func(i int) bool {
+		return replay.Events[i].Revision >= kv.ModRevision
+	})
+	for ; i < len(replay.Events) && replay.Events[i].Revision == kv.ModRevision; i++ {
+		event := replay.Events[i]
+		if event.Key == kv.Key && event.Type == model.PutOperation {
+			return keysOnly || event.Value == kv.Value
+		}
+	}
+	return false
+}
+
+// singleRevision returns the effective revision of the keys of a response, the
+// highest mod revision among them, and whether all keys match the state at
+// that revision. Keys can only be observed together at a revision at or after
+// the effective one, and if they are unchanged at a later revision, they are
+// unchanged at the effective one too.
+func singleRevision(replay *model.EtcdReplay, kvs []model.KeyValue) (revision int64, ok bool) {
+	for _, kv := range kvs {
+		revision = max(revision, kv.ModRevision)
+	}
+	state, err := replay.StateForRevision(revision)
+	if err != nil {
+		return revision, true
+	}
+	for _, kv := range kvs {
+		if value, found := state.KeyValues[kv.Key]; !found || value.ModRevision != kv.ModRevision {
+			return revision, false
+		}
+	}
+	return revision, true
+}
+
+// keyDeleteRevision returns the revision of the delete that removed the key,
+// if the key was deleted and not recreated up to the revision, or 0 otherwise.
+func keyDeleteRevision(replay *model.EtcdReplay, key string, revision int64) int64 {
+	i := sort.Search(len(replay.Events), func(i int) bool {
+		return replay.Events[i].Revision > revision
+	})
+	for i--; i >= 0; i-- {
+		event := replay.Events[i]
+		if event.Key != key {
+			continue
+		}
+		if event.Type == model.DeleteOperation {
+			return event.Revision
+		}
+		return 0
+	}
+	return 0
+}
@@ -25,6 +25,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/tests/v3/robustness/model"
 )
 
@@ -156,7 +157,7 @@ func TestValidateSerializableOperations(t *testing.T) {
 					),
 				},
 			},
-			expectError: errRespNotMatched.Error(),
+			expectError: errKeyBeforeCreate.Error(),
 		},
 		{
 			name: "Invalid revision",
@@ -174,7 +175,112 @@ func TestValidateSerializableOperations(t *testing.T) {
 					),
 				},
 			},
-			expectError: errRespNotMatched.Error(),
+			expectError: errKeyBeforeCreate.Error(),
+		},
+		{
+			name: "Recreated key",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				deleteRequest("a"),
+				putRequest("a", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(0),
+				},
+				{
+					Input:  rangeRequest("a", "z", 4, 0),
+					Output: rangeResponse(1, keyValueRevision("a", "2", 4)),
+				},
+			},
+		},
+		{
+			name: "Deleted key",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				deleteRequest("a"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+				{
+					Input:  rangeRequest("a", "z", 4, 0),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+			},
+			expectError: errKeyAfterDelete.Error(),
+		},
+		{
+			name: "Keys from different revisions",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "1"),
+				putRequest("a", "2"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 4, 0),
+					Output: rangeResponse(2, keyValueRevision("a", "2", 4), keyValueRevision("b", "1", 3)),
+				},
+				{
+					Input:  rangeRequest("a", "z", 5, 0),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 5)),
+				},
+			},
+			expectError: errMixedRevisions.Error(),
+		},
+		{
+			name: "Recreated key before its create",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				deleteRequest("a"),
+				putRequest("a", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(1, keyValueRevision("a", "2", 4)),
+				},
+			},
+			expectError: errKeyBeforeCreate.Error(),
+		},
+		{
+			name: "False empty range",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(0),
+				},
+			},
+			expectError: errFalseEmptyRange.Error(),
+		},
+		{
+			name: "Empty range after keys deleted",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				deleteRequest("a"),
+				deleteRequest("b"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+				{
+					Input:  rangeRequest("a", "z", 5, 0),
+					Output: rangeResponse(0),
+				},
+			},
 		},
 		{
 			name: "Error",
@@ -194,6 +300,357 @@ func TestValidateSerializableOperations(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Value never committed",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(2, keyValueRevision("a", "uncommitted", 2), keyValueRevision("b", "2", 3)),
+				},
+			},
+			expectError: errUncommittedRead.Error(),
+		},
+		{
+			name: "Revision never committed",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("c", "3", 3)),
+				},
+			},
+			expectError: errUncommittedRead.Error(),
+		},
+		{
+			name: "Range end semantics",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("b", "", 4, 0),
+					Output: rangeResponse(1, keyValueRevision("b", "2", 3)),
+				},
+				{
+					Input:  rangeRequest("b", "c", 4, 0),
+					Output: rangeResponse(1, keyValueRevision("b", "2", 3)),
+				},
+				{
+					Input:  rangeRequest("b", "\x00", 4, 0),
+					Output: rangeResponse(2, keyValueRevision("b", "2", 3), keyValueRevision("c", "3", 4)),
+				},
+				{
+					Input:  rangeRequest("\x00", "\x00", 4, 0),
+					Output: rangeResponse(3, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3), keyValueRevision("c", "3", 4)),
+				},
+				{
+					Input:  rangeRequest("c", "a", 4, 0),
+					Output: rangeResponse(0),
+				},
+			},
+		},
+		{
+			name: "False empty range from key",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("b", "\x00", 3, 0),
+					Output: rangeResponse(0),
+				},
+			},
+			expectError: errFalseEmptyRange.Error(),
+		},
+		{
+			name: "Version not matching replay",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("a", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(1, keyValueRevision("a", "2", 3)),
+				},
+			},
+			expectError: errKeyMetadataMismatch.Error(),
+		},
+		{
+			name: "Mod revision not matching replay",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input: rangeRequest("a", "z", 3, 0),
+					Output: rangeResponse(2, model.KeyValue{
+						Key:           "a",
+						ValueRevision: model.ValueRevision{Value: model.ToValueOrHash("1"), ModRevision: 2, Version: 2},
+					}, keyValueRevision("b", "2", 3)),
+				},
+			},
+			expectError: errKeyMetadataMismatch.Error(),
+		},
+		{
+			name: "Limited range",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 4, 2),
+					Output: rangeResponse(3, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+				{
+					// limit larger than the number of keys
+					Input:  rangeRequest("a", "z", 3, 5),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+			},
+		},
+		{
+			name: "Limit not applied",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 1),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Limited range count not matching keys in range",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 4, 1),
+					Output: rangeResponse(1, keyValueRevision("a", "1", 2)),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Limited range out of key order",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 1),
+					Output: rangeResponse(2, keyValueRevision("b", "2", 3)),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Keys only range",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  keysOnlyRangeRequest("a", "z", 4, 0),
+					Output: rangeResponse(3, keyValueRevision("a", "", 2), keyValueRevision("b", "", 3), keyValueRevision("c", "", 4)),
+				},
+				{
+					Input:  keysOnlyRangeRequest("a", "z", 4, 2),
+					Output: rangeResponse(3, keyValueRevision("a", "", 2), keyValueRevision("b", "", 3)),
+				},
+			},
+		},
+		{
+			name: "Keys only range missing key",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  keysOnlyRangeRequest("a", "z", 4, 0),
+					Output: rangeResponse(3, keyValueRevision("a", "", 2), keyValueRevision("c", "", 4)),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Keys only range returning values",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  keysOnlyRangeRequest("a", "z", 2, 0),
+					Output: rangeResponse(1, keyValueRevision("a", "1", 2)),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Sorted range",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+				putRequest("a", "4"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  sortedRangeRequest("a", "z", 5, 2, clientv3.SortByModRevision, clientv3.SortDescend),
+					Output: rangeResponse(3, keyValueVersion("a", "4", 5, 2), keyValueRevision("c", "3", 4)),
+				},
+				{
+					// keys of the same version stay in key order
+					Input:  sortedRangeRequest("a", "z", 5, 0, clientv3.SortByVersion, clientv3.SortAscend),
+					Output: rangeResponse(3, keyValueRevision("b", "2", 3), keyValueRevision("c", "3", 4), keyValueVersion("a", "4", 5, 2)),
+				},
+				{
+					// without an order, only the keys etcd read up to the limit are sorted
+					Input:  sortedRangeRequest("a", "z", 5, 1, clientv3.SortByVersion, clientv3.SortNone),
+					Output: rangeResponse(3, keyValueRevision("b", "2", 3)),
+				},
+				{
+					Input:  sortedRangeRequest("a", "z", 5, 1, clientv3.SortByKey, clientv3.SortDescend),
+					Output: rangeResponse(3, keyValueRevision("c", "3", 4)),
+				},
+			},
+		},
+		{
+			name: "Sorted range in key order",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  sortedRangeRequest("a", "z", 3, 0, clientv3.SortByModRevision, clientv3.SortDescend),
+					Output: rangeResponse(2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Count only range",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  countOnlyRangeRequest("a", "z", 4, 0),
+					Output: rangeResponse(3),
+				},
+				{
+					// the limit doesn't apply to the count
+					Input:  countOnlyRangeRequest("a", "z", 4, 1),
+					Output: rangeResponse(3),
+				},
+				{
+					Input:  countOnlyRangeRequest("a", "z", 2, 0),
+					Output: rangeResponse(1),
+				},
+			},
+		},
+		{
+			name: "Count only range with wrong count",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  countOnlyRangeRequest("a", "z", 4, 1),
+					Output: rangeResponse(1),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Count only range returning keys",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  countOnlyRangeRequest("a", "z", 2, 0),
+					Output: rangeResponse(1, keyValueRevision("a", "1", 2)),
+				},
+			},
+			expectError: errRespNotMatched.Error(),
+		},
+		{
+			name: "Count only range empty for range that had keys",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  countOnlyRangeRequest("a", "z", 2, 0),
+					Output: rangeResponse(0),
+				},
+			},
+			expectError: errFalseEmptyRange.Error(),
+		},
+		{
+			name: "Header revision past requested revision",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 2, 0),
+					Output: rangeResponseWithRevision(3, 1, keyValueRevision("a", "1", 2)),
+				},
+				{
+					Input:  rangeRequest("a", "z", 2, 0),
+					Output: rangeResponseWithRevision(2, 1, keyValueRevision("a", "1", 2)),
+				},
+			},
+		},
+		{
+			name: "Header revision behind requested revision",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("b", "2"),
+				putRequest("c", "3"),
+			},
+			operations: []porcupine.Operation{
+				{
+					Input:  rangeRequest("a", "z", 3, 0),
+					Output: rangeResponseWithRevision(2, 2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3)),
+				},
+			},
+			expectError: errHeaderRevisionBehind.Error(),
+		},
 		{
 			name: "Future rev returned",
 			persistedRequests: []model.EtcdRequest{
@@ -240,6 +697,15 @@ func TestValidateSerializableOperations(t *testing.T) {
 	}
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
+			// Unless set by the test case, reads are served after all
+			// persisted requests were applied.
+			for i, op := range tc.operations {
+				response := op.Output.(model.MaybeEtcdResponse)
+				if response.Error == "" && response.Revision == 0 {
+					response.Revision = int64(len(tc.persistedRequests)) + 1
+					tc.operations[i].Output = response
+				}
+			}
 			replay := model.NewReplay(tc.persistedRequests)
 			result := validateSerializableOperations(zaptest.NewLogger(t), tc.operations, replay)
 			if result.Message != tc.expectError {
@@ -263,6 +729,31 @@ func rangeRequest(start, end string, rev, limit int64) model.EtcdRequest {
 	}
 }
 
+func keysOnlyRangeRequest(start, end string, rev, limit int64) model.EtcdRequest {
+	request := rangeRequest(start, end, rev, limit)
+	request.Range.KeysOnly = true
+	return request
+}
+
+func sortedRangeRequest(start, end string, rev, limit int64, target clientv3.SortTarget, order clientv3.SortOrder) model.EtcdRequest {
+	request := rangeRequest(start, end, rev, limit)
+	request.Range.SortTarget = target
+	request.Range.SortOrder = order
+	return request
+}
+
+func countOnlyRangeRequest(start, end string, rev, limit int64) model.EtcdRequest {
+	request := rangeRequest(start, end, rev, limit)
+	request.Range.CountOnly = true
+	return request
+}
+
+func rangeResponseWithRevision(revision, count int64, kvs ...model.KeyValue) model.MaybeEtcdResponse {
+	response := rangeResponse(count, kvs...)
+	response.Revision = revision
+	return response
+}
+
 func rangeResponse(count int64, kvs ...model.KeyValue) model.MaybeEtcdResponse {
 	if kvs == nil {
 		kvs = []model.KeyValue{}
@@ -283,6 +774,12 @@ func errorResponse(err error) model.MaybeEtcdResponse {
 	}
 }
 
+func keyValueVersion(key, value string, rev, version int64) model.KeyValue {
+	kv := keyValueRevision(key, value, rev)
+	kv.Version = version
+	return kv
+}
+
 func keyValueRevision(key, value string, rev int64) model.KeyValue {
 	return model.KeyValue{
 		Key: key,
@@ -391,7 +888,7 @@ func shuffleHistory(history []porcupine.Operation, shuffleCount int) [][]porcupi
 
 func validateShuffles(b *testing.B, lg *zap.Logger, shuffles [][]porcupine.Operation, duration time.Duration) {
 	for i := 0; i < len(shuffles); i++ {
-		result := validateLinearizableOperationsAndVisualize(lg, shuffles[i], duration)
+		result := validateLinearizableOperationsAndVisualize(lg, shuffles[i], duration, 0)
 		if err := result.Error(); err != nil {
 			b.Fatalf("Not linearizable: %v", err)
 		}
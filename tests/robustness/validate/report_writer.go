@@ -0,0 +1,152 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// ReportWriter writes the result of validation in a format consumed by other
+// tools, like CI dashboards.
+type ReportWriter interface {
+	WriteReport(w io.Writer, result RobustnessResult) error
+}
+
+var (
+	_ ReportWriter = TextReportWriter{}
+	_ ReportWriter = JSONReportWriter{}
+	_ ReportWriter = JUnitReportWriter{}
+)
+
+// TextReportWriter writes a line with the status of each validation phase.
+type TextReportWriter struct{}
+
+func (TextReportWriter) WriteReport(w io.Writer, result RobustnessResult) error {
+	for _, phase := range result.Phases() {
+		line := fmt.Sprintf("%s: %s", phase.Name, phaseStatus(phase.Result))
+		if phase.Result.Status == Failure {
+			line += ": " + phase.Result.Message
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSONReportWriter writes the status of each validation phase as a JSON
+// object.
+type JSONReportWriter struct{}
+
+type jsonReport struct {
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Phases []jsonPhase `json:"phases"`
+}
+
+type jsonPhase struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func (JSONReportWriter) WriteReport(w io.Writer, result RobustnessResult) error {
+	report := jsonReport{Status: string(Success)}
+	if err := result.Error(); err != nil {
+		report.Status = string(Failure)
+		report.Error = err.Error()
+	}
+	for _, phase := range result.Phases() {
+		report.Phases = append(report.Phases, jsonPhase{
+			Name:    phase.Name,
+			Status:  phaseStatus(phase.Result),
+			Message: phase.Result.Message,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// JUnitReportWriter writes a JUnit XML test suite with a test case for each
+// validation phase. Skipped phases are reported as skipped test cases.
+type JUnitReportWriter struct {
+	// SuiteName is the name of the test suite, "robustness" if empty.
+	SuiteName string
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Details string `xml:",chardata"`
+}
+
+func (j JUnitReportWriter) WriteReport(w io.Writer, result RobustnessResult) error {
+	suite := junitTestSuite{Name: j.SuiteName}
+	if suite.Name == "" {
+		suite.Name = "robustness"
+	}
+	for _, phase := range result.Phases() {
+		testCase := junitTestCase{Name: phase.Name, ClassName: suite.Name}
+		switch phase.Result.Status {
+		case Success:
+		case Failure:
+			testCase.Failure = &junitFailure{Message: phase.Result.Message, Details: phase.Result.Message}
+			suite.Failures++
+		default:
+			testCase.Skipped = &struct{}{}
+			suite.Skipped++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// phaseStatus returns the status of the validation phase, "Skipped" for
+// phases that didn't run.
+func phaseStatus(result Result) string {
+	if result.Status == Unknown {
+		return "Skipped"
+	}
+	return string(result.Status)
+}
@@ -15,18 +15,54 @@
 package validate
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
 
 	"github.com/anishathalye/porcupine"
 	"go.uber.org/zap"
 )
 
 type RobustnessResult struct {
-	Assumptions   Result
-	Linearization LinearizationResult
-	Watch         Result
-	Serializable  Result
+	Assumptions    Result
+	RevisionBounds Result
+	Linearization  LinearizationResult
+	// RevisionDensity is only validated if linearization succeeds.
+	RevisionDensity Result
+	Watch           Result
+	Serializable    Result
+	Session         Result
+	Causality       Result
+	Compaction      Result
+	Lease           Result
+	Txn             Result
+	// LostWrites and FinalState are only validated with Config.WritesOnly.
+	LostWrites Result
+	FinalState Result
+	// LatencyOutliers lists the slowest operations, see Config.LatencyOutliers.
+	LatencyOutliers []OperationLatency
+	// CandidateModels holds the linearization results against
+	// Config.CandidateModels, in the same order.
+	CandidateModels []CandidateModelResult
+}
+
+type CandidateModelResult struct {
+	Name   string
+	Result LinearizationResult
+}
+
+// StrictestModel returns the name of the first, so strictest, candidate model
+// the history is linearizable against.
+func (r RobustnessResult) StrictestModel() (string, bool) {
+	for _, candidate := range r.CandidateModels {
+		if candidate.Result.Status == Success {
+			return candidate.Name, true
+		}
+	}
+	return "", false
 }
 
 type Result struct {
@@ -43,21 +79,40 @@ var (
 )
 
 func (r RobustnessResult) Error() error {
-	if err := r.Assumptions.Error(); err != nil {
-		return fmt.Errorf("assumptions: %w", err)
-	}
-	if err := r.Linearization.Error(); err != nil {
-		return fmt.Errorf("linearization: %w", err)
-	}
-	if err := r.Watch.Error(); err != nil {
-		return fmt.Errorf("watch: %w", err)
-	}
-	if err := r.Serializable.Error(); err != nil {
-		return fmt.Errorf("serializable: %w", err)
+	for _, phase := range r.Phases() {
+		if err := phase.Result.Error(); err != nil {
+			return fmt.Errorf("%s: %w", phase.Name, err)
+		}
 	}
 	return nil
 }
 
+// Phase is the result of one validation of the history.
+type Phase struct {
+	Name   string
+	Result Result
+}
+
+// Phases returns results of all validations in the order they are checked
+// by Error. Validations that were skipped have Unknown status.
+func (r RobustnessResult) Phases() []Phase {
+	return []Phase{
+		{Name: "assumptions", Result: r.Assumptions},
+		{Name: "revision bounds", Result: r.RevisionBounds},
+		{Name: "linearization", Result: r.Linearization.Result},
+		{Name: "revision density", Result: r.RevisionDensity},
+		{Name: "watch", Result: r.Watch},
+		{Name: "serializable", Result: r.Serializable},
+		{Name: "session", Result: r.Session},
+		{Name: "causality", Result: r.Causality},
+		{Name: "compaction", Result: r.Compaction},
+		{Name: "lease", Result: r.Lease},
+		{Name: "txn", Result: r.Txn},
+		{Name: "lost writes", Result: r.LostWrites},
+		{Name: "final state", Result: r.FinalState},
+	}
+}
+
 func ResultFromError(err error) Result {
 	if err != nil {
 		return Result{
@@ -85,6 +140,9 @@ type LinearizationResult struct {
 	Model porcupine.Model
 	Result
 	Timeout bool
+	// MemoryBudgetExceeded is set if the linearization was aborted as memory
+	// usage exceeded Config.MemoryBudget.
+	MemoryBudgetExceeded bool
 }
 
 func (r *LinearizationResult) Visualize(lg *zap.Logger, path string) error {
@@ -96,6 +154,139 @@ func (r *LinearizationResult) Visualize(lg *zap.Logger, path string) error {
 	return nil
 }
 
+// VisualizeClients saves the porcupine visualization of the operations of the
+// given clients to path, along the linearization found for the whole history.
+func (r *LinearizationResult) VisualizeClients(lg *zap.Logger, path string, clientIDs ...int) error {
+	lg.Info("Saving visualization", zap.String("path", path), zap.Ints("clients", clientIDs))
+	page, err := r.clientsVisualization(clientIDs)
+	if err == nil {
+		err = os.WriteFile(path, page, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to visualize, err: %w", err)
+	}
+	return nil
+}
+
+// clientsVisualization renders the visualization of the history and filters
+// the data embedded in the page down to the operations of the given clients.
+// Porcupine doesn't expose that data, so it is located using the page of an
+// empty history, which only differs by its data.
+func (r *LinearizationResult) clientsVisualization(clientIDs []int) ([]byte, error) {
+	var page, empty bytes.Buffer
+	if err := porcupine.Visualize(r.Model, r.Info, &page); err != nil {
+		return nil, err
+	}
+	if err := porcupine.Visualize(r.Model, porcupine.LinearizationInfo{}, &empty); err != nil {
+		return nil, err
+	}
+	prefix, suffix, found := bytes.Cut(empty.Bytes(), []byte(`{"Partitions":[],"Annotations":[]}`))
+	if !found || !bytes.HasPrefix(page.Bytes(), prefix) || !bytes.HasSuffix(page.Bytes(), suffix) || page.Len() < len(prefix)+len(suffix) {
+		return nil, errors.New("unexpected porcupine visualization layout")
+	}
+	var data visualizationData
+	if err := json.Unmarshal(page.Bytes()[len(prefix):page.Len()-len(suffix)], &data); err != nil {
+		return nil, err
+	}
+	clients := map[int]bool{}
+	for _, id := range clientIDs {
+		clients[id] = true
+	}
+	filtered, err := json.Marshal(data.filterClients(clients))
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(prefix, filtered, suffix), nil
+}
+
+// visualizationData mirrors the data porcupine embeds in its visualization,
+// only decoding what is needed to filter it by client.
+type visualizationData struct {
+	Partitions  []visualizationPartition
+	Annotations []json.RawMessage
+}
+
+type visualizationPartition struct {
+	History               []json.RawMessage
+	PartialLinearizations [][]visualizationStep
+	Largest               map[int]int
+}
+
+type visualizationStep struct {
+	Index            int
+	StateDescription string
+}
+
+type visualizationClient struct {
+	ClientID int
+	Tag      string
+}
+
+// filterClients drops the operations and annotations of other clients. The
+// linearization steps refer to operations by their index in the history, so
+// they are renumbered, while the states described stay those of the whole
+// history.
+func (d visualizationData) filterClients(clients map[int]bool) visualizationData {
+	kept := func(raw json.RawMessage) bool {
+		var c visualizationClient
+		return json.Unmarshal(raw, &c) == nil && (c.Tag != "" || clients[c.ClientID])
+	}
+	filtered := visualizationData{Annotations: []json.RawMessage{}}
+	for _, partition := range d.Partitions {
+		ids := map[int]int{}
+		p := visualizationPartition{History: []json.RawMessage{}, Largest: map[int]int{}}
+		for id, op := range partition.History {
+			if kept(op) {
+				ids[id] = len(p.History)
+				p.History = append(p.History, op)
+			}
+		}
+		if len(p.History) == 0 {
+			continue
+		}
+		for _, linearization := range partition.PartialLinearizations {
+			steps := []visualizationStep{}
+			for _, step := range linearization {
+				if id, ok := ids[step.Index]; ok {
+					steps = append(steps, visualizationStep{Index: id, StateDescription: step.StateDescription})
+				}
+			}
+			p.PartialLinearizations = append(p.PartialLinearizations, steps)
+		}
+		for id, largest := range partition.Largest {
+			if id, ok := ids[id]; ok {
+				p.Largest[id] = largest
+			}
+		}
+		filtered.Partitions = append(filtered.Partitions, p)
+	}
+	for _, annotation := range d.Annotations {
+		if kept(annotation) {
+			filtered.Annotations = append(filtered.Annotations, annotation)
+		}
+	}
+	return filtered
+}
+
+// linearization returns the operations in the order they were linearized, nil
+// if the history is not linearizable or was split into partitions.
+func (r *LinearizationResult) linearization() []porcupine.Operation {
+	if r.Status != Success {
+		return nil
+	}
+	partitions := r.Info.PartialLinearizationsOperations()
+	if len(partitions) != 1 {
+		return nil
+	}
+	var longest []porcupine.Operation
+	for _, linearization := range partitions[0] {
+		if len(linearization) > len(longest) {
+			longest = linearization
+		}
+	}
+	return longest
+}
+
 func (r *LinearizationResult) AddToVisualization(serializable []porcupine.Operation) {
 	annotations := []porcupine.Annotation{}
 	for _, op := range serializable {
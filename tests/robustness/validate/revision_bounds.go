@@ -0,0 +1,184 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+)
+
+var (
+	errBrokeRevisionBounds = errors.New("broke Revision Bounds - an operation observed a revision lower than one of a write completed before it started")
+	errRevisionAboveMax    = errors.New("broke Revision Bounds - an operation observed a revision higher than any write could have produced")
+	errReadRevisionOrder   = errors.New("broke Revision Bounds - a read observed a revision lower than one of a read completed before it started")
+)
+
+// validateRevisionBounds checks necessary conditions of linearization: the
+// revision of a response is never lower than the revision of a write that
+// returned before the request was sent, nor higher than the highest revision
+// writes of the history could have produced, and reads don't observe revisions
+// lower than reads that returned before them. It is much cheaper than the full
+// linearization, so it fails fast pointing directly at the offending operation.
+func validateRevisionBounds(lg *zap.Logger, operations []porcupine.Operation) Result {
+	lg.Info("Validating revision bounds")
+	start := time.Now()
+	err := validateRevisionBoundsError(lg, operations)
+	if err != nil {
+		lg.Error("Revision bounds validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Revision bounds validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateRevisionBoundsError(lg *zap.Logger, operations []porcupine.Operation) error {
+	bound := maxRevisionBound(operations)
+	for _, op := range operations {
+		response := op.Output.(model.MaybeEtcdResponse)
+		if response.Error != "" || response.ClientError != "" {
+			continue
+		}
+		if response.Revision > bound {
+			lg.Error("Operation observed revision higher than any write produced", zap.Int("client", op.ClientId), zap.Int64("revision", response.Revision), zap.Int64("max-revision", bound), zap.Any("request", op.Input), zap.Any("response", op.Output))
+			return errRevisionAboveMax
+		}
+	}
+	var writes []porcupine.Operation
+	for _, op := range operations {
+		if revisionKnown(op) && isWrite(op.Input.(model.EtcdRequest), op.Output.(model.MaybeEtcdResponse)) {
+			writes = append(writes, op)
+		}
+	}
+	sort.Slice(writes, func(i, j int) bool { return writes[i].Return < writes[j].Return })
+	// maxRevision[i] is the highest revision produced by writes[:i+1].
+	maxRevision := make([]int64, len(writes))
+	for i, op := range writes {
+		maxRevision[i] = op.Output.(model.MaybeEtcdResponse).Revision
+		if i > 0 {
+			maxRevision[i] = max(maxRevision[i], maxRevision[i-1])
+		}
+	}
+	for _, op := range operations {
+		if !revisionKnown(op) {
+			continue
+		}
+		// Writes returned before the operation was called.
+		i := sort.Search(len(writes), func(i int) bool { return writes[i].Return >= op.Call })
+		if i == 0 {
+			continue
+		}
+		revision := op.Output.(model.MaybeEtcdResponse).Revision
+		if revision < maxRevision[i-1] {
+			lg.Error("Operation observed revision lower than completed write", zap.Int("client", op.ClientId), zap.Int64("revision", revision), zap.Int64("write-revision", maxRevision[i-1]), zap.Any("request", op.Input), zap.Any("response", op.Output))
+			return errBrokeRevisionBounds
+		}
+	}
+	return validateReadRevisionOrdering(lg, operations)
+}
+
+// validateReadRevisionOrdering checks that linearizable reads don't observe a
+// revision lower than one observed by a read that returned before they were
+// called. Reads overlapping in time can be linearized in any order, so they
+// are not compared.
+func validateReadRevisionOrdering(lg *zap.Logger, operations []porcupine.Operation) error {
+	var reads []porcupine.Operation
+	for _, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		if revisionKnown(op) && request.IsRead() {
+			reads = append(reads, op)
+		}
+	}
+	sort.Slice(reads, func(i, j int) bool { return reads[i].Return < reads[j].Return })
+	// maxRead[i] is the read observing the highest revision among reads[:i+1].
+	maxRead := make([]int, len(reads))
+	for i, op := range reads {
+		if i > 0 && reads[maxRead[i-1]].Output.(model.MaybeEtcdResponse).Revision > op.Output.(model.MaybeEtcdResponse).Revision {
+			maxRead[i] = maxRead[i-1]
+		} else {
+			maxRead[i] = i
+		}
+	}
+	for _, op := range reads {
+		// Reads returned before the read was called.
+		i := sort.Search(len(reads), func(i int) bool { return reads[i].Return >= op.Call })
+		if i == 0 {
+			continue
+		}
+		previous := reads[maxRead[i-1]]
+		revision := op.Output.(model.MaybeEtcdResponse).Revision
+		previousRevision := previous.Output.(model.MaybeEtcdResponse).Revision
+		if revision < previousRevision {
+			lg.Error("Read observed revision lower than completed read", zap.Int("client", op.ClientId), zap.Int64("revision", revision), zap.Int("previous-client", previous.ClientId), zap.Int64("previous-revision", previousRevision), zap.Any("request", op.Input), zap.Any("response", op.Output))
+			return errReadRevisionOrder
+		}
+	}
+	return nil
+}
+
+// maxRevisionBound returns the highest revision the writes of the history
+// could have produced. It's the highest revision returned by a write, plus one
+// for each write with unknown outcome and lease that could have expired, as each
+// of them could have incremented the revision once.
+func maxRevisionBound(operations []porcupine.Operation) int64 {
+	// The database is expected to be empty at start.
+	bound := int64(1)
+	var unknown int64
+	for _, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		switch {
+		case request.Type == model.LeaseGrant:
+			if response.ClientError == "" {
+				unknown++
+			}
+			continue
+		case request.Type != model.Txn && request.Type != model.LeaseRevoke, request.IsRead(), response.ClientError != "":
+			continue
+		}
+		switch {
+		case response.Persisted && response.PersistedRevision > 0:
+			bound = max(bound, response.PersistedRevision)
+		case response.Error != "" || response.Persisted:
+			unknown++
+		default:
+			bound = max(bound, response.Revision)
+		}
+	}
+	return bound + unknown
+}
+
+// revisionKnown returns whether the operation succeeded with a response at the
+// latest revision, so it observed or produced that revision.
+func revisionKnown(op porcupine.Operation) bool {
+	request := op.Input.(model.EtcdRequest)
+	response := op.Output.(model.MaybeEtcdResponse)
+	if response.Error != "" || response.Persisted || response.ClientError != "" || response.Revision <= 0 {
+		return false
+	}
+	switch request.Type {
+	case model.Range:
+		// Stale reads are not required to observe the latest revision.
+		return request.Range.Revision == 0
+	case model.Txn:
+		return true
+	default:
+		return false
+	}
+}
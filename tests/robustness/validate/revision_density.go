@@ -0,0 +1,101 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+)
+
+var (
+	errRevisionRepeated = errors.New("broke Revision Density - two writes produced the same revision")
+	errRevisionGap      = errors.New("broke Revision Density - revision increased by more than the writes in between could explain")
+)
+
+// validateRevisionDensity checks that each write increments the revision by
+// exactly one, walking writes in the order they were linearized from the
+// revision of the empty database. A transaction is a single write, however
+// many keys it modifies. A gap is only allowed when writes with unknown
+// outcome linearized in between, or leases that could have expired, explain
+// it. A repeat or an unexplained gap means a lost or phantom write.
+func validateRevisionDensity(lg *zap.Logger, linearization []porcupine.Operation) Result {
+	lg.Info("Validating revision density")
+	start := time.Now()
+	err := validateRevisionDensityError(lg, linearization)
+	if err != nil {
+		lg.Error("Revision density validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Revision density validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateRevisionDensityError(lg *zap.Logger, linearization []porcupine.Operation) error {
+	// The database is expected to be empty at start.
+	last := int64(1)
+	// unknown is the number of writes with unknown outcome since the last
+	// write, leases the number of leases that could have expired so far, each
+	// of them increments the revision at most once.
+	var unknown, leases int64
+	for _, op := range linearization {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		if response.ClientError != "" {
+			continue
+		}
+		var revision int64
+		switch {
+		case request.Type == model.LeaseGrant:
+			leases++
+			continue
+		case request.Type != model.Txn && request.Type != model.LeaseRevoke, request.IsRead():
+			continue
+		case response.Persisted && response.PersistedRevision > 0:
+			revision = response.PersistedRevision
+		case response.Error != "" || response.Persisted:
+			unknown++
+			continue
+		case request.Type == model.LeaseRevoke:
+			// Revoking a lease without keys doesn't increment the revision.
+			if response.Revision <= last {
+				continue
+			}
+			revision = response.Revision
+		case !isWrite(request, response):
+			continue
+		default:
+			revision = response.Revision
+		}
+		if revision <= last {
+			lg.Error("Write didn't increment the revision", zap.Int("client", op.ClientId), zap.Int64("revision", revision), zap.Int64("previous-revision", last), zap.Any("request", request), zap.Any("response", response))
+			return errRevisionRepeated
+		}
+		gap := revision - last - 1
+		if gap > unknown+leases {
+			lg.Error("Write revision skipped revisions", zap.Int("client", op.ClientId), zap.Int64("revision", revision), zap.Int64("previous-revision", last), zap.Int64("unknown-writes", unknown), zap.Int64("leases", leases), zap.Any("request", request), zap.Any("response", response))
+			return errRevisionGap
+		}
+		// Writes with unknown outcome linearized before this one either were
+		// applied before it or never, expired leases don't expire again.
+		leases -= max(0, gap-unknown)
+		unknown = 0
+		last = revision
+	}
+	return nil
+}
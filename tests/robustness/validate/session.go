@@ -0,0 +1,133 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+var (
+	errBrokeMonotonicRevision = errors.New("broke Monotonic Revision - a client never observes a revision lower than one it has already observed")
+	errBrokeReadYourWrites    = errors.New("broke Read Your Writes - a client read always observes the client's own earlier writes")
+)
+
+// validateSession checks the guarantees that hold within a single client
+// session, which are cheaper to validate than linearization of the whole
+// history.
+func validateSession(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) Result {
+	lg.Info("Validating client sessions")
+	start := time.Now()
+	err := validateSessionError(lg, reports, replay)
+	if err != nil {
+		lg.Error("Session validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Session validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateSessionError(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) error {
+	// Client requests are not concurrent, so operations are already ordered per client.
+	clientOperations := map[int][]porcupine.Operation{}
+	var clientIDs []int
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			if _, ok := clientOperations[op.ClientId]; !ok {
+				clientIDs = append(clientIDs, op.ClientId)
+			}
+			clientOperations[op.ClientId] = append(clientOperations[op.ClientId], op)
+		}
+	}
+	for _, id := range clientIDs {
+		if err := validateClientSession(lg, replay, id, clientOperations[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateClientSession(lg *zap.Logger, replay *model.EtcdReplay, clientID int, operations []porcupine.Operation) error {
+	var lastRevision int64
+	// lastWrite holds the revision of the last successful write of the client to a key.
+	lastWrite := map[string]int64{}
+	for _, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		if response.Error != "" || response.Persisted || response.ClientError != "" || response.Revision <= 0 {
+			continue
+		}
+		if response.Revision < lastRevision {
+			lg.Error("Client observed revision going back", zap.Int("client", clientID), zap.Int64("revision", response.Revision), zap.Int64("last-revision", lastRevision))
+			return errBrokeMonotonicRevision
+		}
+		lastRevision = response.Revision
+
+		switch {
+		case request.Type == model.Range && request.Range.Revision == 0 && response.Range != nil:
+			if err := validateReadYourWrites(lg, replay, clientID, request.Range.RangeOptions, *response.Range, response.Revision, lastWrite); err != nil {
+				return err
+			}
+		case request.Type == model.Txn && response.Txn != nil:
+			ops := request.Txn.OperationsOnSuccess
+			if response.Txn.Failure {
+				ops = request.Txn.OperationsOnFailure
+			}
+			for _, txnOp := range ops {
+				switch txnOp.Type {
+				case model.PutOperation:
+					lastWrite[txnOp.Put.Key] = response.Revision
+				case model.DeleteOperation:
+					lastWrite[txnOp.Delete.Key] = response.Revision
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateReadYourWrites(lg *zap.Logger, replay *model.EtcdReplay, clientID int, options model.RangeOptions, response model.RangeResponse, revision int64, lastWrite map[string]int64) error {
+	observed := map[string]int64{}
+	for _, kv := range response.KVs {
+		observed[kv.Key] = kv.ModRevision
+		if kv.ModRevision < lastWrite[kv.Key] {
+			lg.Error("Client read value older than its own write", zap.Int("client", clientID), zap.String("key", kv.Key), zap.Int64("mod-revision", kv.ModRevision), zap.Int64("write-revision", lastWrite[kv.Key]))
+			return errBrokeReadYourWrites
+		}
+	}
+	if options.Limit != 0 {
+		return nil
+	}
+	state, err := replay.StateForRevision(revision)
+	if err != nil {
+		return nil
+	}
+	for key := range lastWrite {
+		if _, ok := observed[key]; ok || !options.ContainsKey(key) {
+			continue
+		}
+		// The key can still be missing if it was deleted after the write.
+		if _, ok := state.KeyValues[key]; ok {
+			lg.Error("Client read missed its own write", zap.Int("client", clientID), zap.String("key", key), zap.Int64("revision", revision), zap.Int64("write-revision", lastWrite[key]))
+			return errBrokeReadYourWrites
+		}
+	}
+	return nil
+}
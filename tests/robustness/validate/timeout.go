@@ -0,0 +1,71 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"sort"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+)
+
+const (
+	minEstimatedTimeout = 10 * time.Second
+	maxEstimatedTimeout = 10 * time.Minute
+	// timeoutPerOperation is the linearization time budgeted for each
+	// operation per operation overlapping with it.
+	timeoutPerOperation = 50 * time.Microsecond
+)
+
+// EstimateTimeout returns a linearization timeout for the operations. The
+// cost of linearization grows with the number of operations, and with their
+// concurrency, the highest number of operations overlapping in time, as
+// porcupine has to try more orders of concurrent operations. Failed
+// operations overlap with all operations called after them. The estimate is
+// between 10 seconds and 10 minutes.
+func EstimateTimeout(operations []porcupine.Operation) time.Duration {
+	timeout := time.Duration(len(operations)*concurrency(operations)) * timeoutPerOperation
+	return min(max(timeout, minEstimatedTimeout), maxEstimatedTimeout)
+}
+
+// concurrency returns the highest number of operations in progress at once.
+func concurrency(operations []porcupine.Operation) int {
+	type event struct {
+		time int64
+		call bool
+	}
+	events := make([]event, 0, 2*len(operations))
+	for _, op := range operations {
+		events = append(events, event{time: op.Call, call: true}, event{time: op.Return})
+	}
+	// Returns go first, operations returning when others are called don't
+	// overlap with them.
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].time != events[j].time {
+			return events[i].time < events[j].time
+		}
+		return !events[i].call && events[j].call
+	})
+	var current, highest int
+	for _, e := range events {
+		if e.call {
+			current++
+			highest = max(highest, current)
+		} else {
+			current--
+		}
+	}
+	return highest
+}
@@ -0,0 +1,211 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+var (
+	errBrokeTxnBranch  = errors.New("broke Txn Branch - the branch taken by a transaction must match its comparisons evaluated against the state it was applied on")
+	errTxnReadMismatch = errors.New("txn read didn't match the state the transaction was applied on")
+	errTxnPartialRead  = errors.New("broke Txn Atomicity - read observed only part of the writes of a transaction")
+)
+
+// validateTxnBranches checks that the branch taken by each successful
+// transaction is the one its comparisons select on the state the transaction
+// was applied on. For a transaction that wrote, that is the state preceding
+// the revision of its response, otherwise the state at that revision. It also
+// checks that the reads of the branch observed that single state, along with
+// the writes of the branch preceding them.
+func validateTxnBranches(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) Result {
+	lg.Info("Validating transaction branches")
+	start := time.Now()
+	err := validateTxnBranchesError(lg, reports, replay)
+	if err != nil {
+		lg.Error("Transaction branch validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Transaction branch validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateTxnBranchesError(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) error {
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			request := op.Input.(model.EtcdRequest)
+			response := op.Output.(model.MaybeEtcdResponse)
+			if request.Type != model.Txn || response.Txn == nil {
+				continue
+			}
+			if response.Error != "" || response.Persisted || response.ClientError != "" || response.Revision <= 0 {
+				continue
+			}
+			applyRevision := response.Revision
+			if isWrite(request, response) {
+				applyRevision--
+			}
+			state, err := replay.StateForRevision(applyRevision)
+			if err != nil {
+				continue
+			}
+			if conditionsSucceeded(state, request.Txn.Conditions) == response.Txn.Failure {
+				lg.Error("Transaction took branch not matching its comparisons", zap.Int("client", op.ClientId), zap.Int64("apply-revision", applyRevision), zap.Any("request", request), zap.Any("response", response))
+				return errBrokeTxnBranch
+			}
+			if i, ok := txnReadsMatch(state, request, response); !ok {
+				lg.Error("Transaction read not matching the state it was applied on", zap.Int("client", op.ClientId), zap.Int64("apply-revision", applyRevision), zap.Int("operation", i), zap.Any("request", request), zap.Any("response", response))
+				return errTxnReadMismatch
+			}
+		}
+	}
+	return nil
+}
+
+// conditionsSucceeded evaluates transaction comparisons the same way as the
+// model: against the version of the key if an expected version is set, and
+// against its mod revision otherwise, where 0 means the key doesn't exist.
+func conditionsSucceeded(state model.EtcdState, conditions []model.EtcdCondition) bool {
+	for _, cond := range conditions {
+		val := state.KeyValues[cond.Key]
+		if cond.ExpectedVersion > 0 {
+			if val.Version != cond.ExpectedVersion {
+				return false
+			}
+		} else if val.ModRevision != cond.ExpectedRevision {
+			return false
+		}
+	}
+	return true
+}
+
+// txnReadsMatch steps the transaction on the state it was applied on and
+// returns whether the keys returned by each read of the taken branch match the
+// expected ones, and the index of the first read that doesn't. Reads bundled
+// in one transaction all observe the same revision, so a read returning a key
+// version older or newer than the one at that revision breaks isolation.
+func txnReadsMatch(state model.EtcdState, request model.EtcdRequest, response model.MaybeEtcdResponse) (int, bool) {
+	_, expect := state.Step(request)
+	ops := request.Txn.OperationsOnSuccess
+	if response.Txn.Failure {
+		ops = request.Txn.OperationsOnFailure
+	}
+	for i, op := range ops {
+		if op.Type != model.RangeOperation || i >= len(response.Txn.Results) || i >= len(expect.Txn.Results) {
+			continue
+		}
+		if !slices.Equal(response.Txn.Results[i].KVs, expect.Txn.Results[i].KVs) {
+			return i, false
+		}
+	}
+	return 0, true
+}
+
+// validateTxnAtomicity checks that reads observe either all or none of the
+// writes of a transaction. The replay applies each transaction at a single
+// revision, so a read returning a key written by a transaction must return
+// the other keys in its range written by it too, unless they were changed
+// again up to the revision of the read.
+func validateTxnAtomicity(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) Result {
+	lg.Info("Validating transaction atomicity")
+	start := time.Now()
+	err := validateTxnAtomicityError(lg, reports, replay)
+	if err != nil {
+		lg.Error("Transaction atomicity validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Transaction atomicity validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+func validateTxnAtomicityError(lg *zap.Logger, reports []report.ClientReport, replay *model.EtcdReplay) error {
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			request := op.Input.(model.EtcdRequest)
+			response := op.Output.(model.MaybeEtcdResponse)
+			if request.Type != model.Range || response.Range == nil || response.Error != "" || response.Persisted {
+				continue
+			}
+			// The keys reflect the requested revision, or the revision in the
+			// header if none was requested.
+			revision := request.Range.Revision
+			if revision <= 0 {
+				revision = response.Revision
+			}
+			state, err := replay.StateForRevision(revision)
+			if err != nil {
+				continue
+			}
+			if key, ok := txnWritesObserved(replay, state, request.Range.RangeOptions, response.Range.KVs); !ok {
+				lg.Error("Read observed part of a transaction", zap.Int("client", op.ClientId), zap.Int64("revision", revision), zap.String("key", key), zap.Any("request", request), zap.Any("response", response))
+				return errTxnPartialRead
+			}
+		}
+	}
+	return nil
+}
+
+// txnWritesObserved checks that for each transaction that wrote more than one
+// key and whose write is among kvs, kvs match the state of all keys in range
+// written by it. A limited read only returns the first keys of the range, so
+// keys after the last one returned aren't checked. It returns the first key
+// not matching.
+func txnWritesObserved(replay *model.EtcdReplay, state model.EtcdState, options model.RangeOptions, kvs []model.KeyValue) (string, bool) {
+	returned := map[string]model.KeyValue{}
+	for _, kv := range kvs {
+		returned[kv.Key] = kv
+	}
+	limited := options.Limit > 0 && int64(len(kvs)) >= options.Limit
+	checked := map[int64]bool{}
+	for _, kv := range kvs {
+		if checked[kv.ModRevision] {
+			continue
+		}
+		checked[kv.ModRevision] = true
+		events := revisionEvents(replay, kv.ModRevision)
+		if len(events) < 2 {
+			continue
+		}
+		for _, event := range events {
+			if !options.ContainsKey(event.Key) || (limited && event.Key > kvs[len(kvs)-1].Key) {
+				continue
+			}
+			expect, exists := state.KeyValues[event.Key]
+			got, found := returned[event.Key]
+			if exists != found || (exists && got.ModRevision != expect.ModRevision) {
+				return event.Key, false
+			}
+		}
+	}
+	return "", true
+}
+
+// revisionEvents returns the events persisted at the revision.
+func revisionEvents(replay *model.EtcdReplay, revision int64) []model.PersistedEvent {
+	i := sort.Search(len(replay.Events), func(i int) bool {
+		return replay.Events[i].Revision >= revision
+	})
+	j := i
+	for j < len(replay.Events) && replay.Events[j].Revision == revision {
+		j++
+	}
+	return replay.Events[i:j]
+}
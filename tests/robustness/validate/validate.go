@@ -29,38 +29,141 @@ import (
 
 var ErrNotEmptyDatabase = errors.New("non empty database at start, required by model used for linearizability validation")
 
+// ValidateAndReturnVisualize validates the reports. A zero timeout for
+// linearization is replaced by the EstimateTimeout of the operations.
 func ValidateAndReturnVisualize(lg *zap.Logger, cfg Config, reports []report.ClientReport, persistedRequests []model.EtcdRequest, timeout time.Duration) (result RobustnessResult) {
+	if cfg.HistoryPath != "" {
+		persistHistory(lg, cfg.HistoryPath, reports)
+	}
 	result.Assumptions = ResultFromError(checkValidationAssumptions(reports))
 	if result.Assumptions.Error() != nil {
 		return result
 	}
-	linearizableOperations, serializableOperations, operationsForVisualization := prepareAndCategorizeOperations(reports)
+	// Watch validation and history patching keep using all operations, as
+	// they correlate operations with the whole persisted history.
+	kvReports := reports
+	if cfg.Window != nil {
+		kvReports = cfg.Window.filter(lg, reports)
+	}
+	result.LatencyOutliers = latencyOutliers(lg, kvReports, cfg.LatencyOutliers)
+	linearizableOperations, serializableOperations, operationsForVisualization := prepareAndCategorizeOperations(kvReports)
+	if cfg.KeyFilter != nil {
+		serializableOperations = cfg.KeyFilter.filter(lg, serializableOperations)
+	}
 	// We are passing in the original reports and linearizableOperations with modified return time.
 	// The reason is that linearizableOperations are those dedicated for linearization, which requires them to have returnTime set to infinity as required by pourcupine.
 	// As for the report, the original report is used so the consumer doesn't need to track what patching was done or not.
 	if len(persistedRequests) != 0 {
 		linearizableOperations = patchLinearizableOperations(linearizableOperations, reports, persistedRequests)
 	}
+	if cfg.WritesOnly {
+		return validateWritesOnly(lg, result, linearizableOperations, persistedRequests)
+	}
+
+	if timeout == 0 {
+		timeout = EstimateTimeout(linearizableOperations)
+		lg.Info("Estimated linearization timeout", zap.Duration("timeout", timeout), zap.Int("operations", len(linearizableOperations)))
+	}
 
-	result.Linearization = validateLinearizableOperationsAndVisualize(lg, linearizableOperations, timeout)
+	result.RevisionBounds = validateRevisionBounds(lg, linearizableOperations)
+	// Skip the expensive linearization, as it's expected to fail too.
+	if result.RevisionBounds.Error() != nil {
+		lg.Info("Skipping other validations as revision bounds validation failed")
+		return result
+	}
+	result.Linearization = validateLinearizableOperationsAndVisualize(lg, linearizableOperations, timeout, cfg.MemoryBudget)
 	result.Linearization.AddToVisualization(operationsForVisualization)
+	result.CandidateModels = validateCandidateModels(lg, cfg.CandidateModels, linearizableOperations, timeout, cfg.MemoryBudget)
+	// Serializable reads are validated against the persisted requests, so they
+	// still get a verdict if the history was too large to linearize.
+	if result.Linearization.MemoryBudgetExceeded && len(persistedRequests) != 0 {
+		lg.Info("Skipping other validations as linearization exceeded memory budget")
+		result.Serializable = validateSerializableOperations(lg, serializableOperations, model.NewReplay(persistedRequests))
+		return result
+	}
 	// Skip other validations if model is not linearizable, as they are expected to fail too and obfuscate the logs.
 	if result.Linearization.Error() != nil {
 		lg.Info("Skipping other validations as linearization failed")
 		return result
 	}
+	result.RevisionDensity = validateRevisionDensity(lg, result.Linearization.linearization())
+	result.Causality = validateCausality(lg, kvReports)
+	result.Compaction = validateCompaction(lg, kvReports)
+	result.Lease = validateLease(lg, reports)
 	if len(persistedRequests) == 0 {
 		lg.Info("Skipping other validations as persisted requests were empty")
 		return result
 	}
 	replay := model.NewReplay(persistedRequests)
+	if result.Compaction.Error() == nil {
+		result.Compaction = validateCompactionLiveKeys(lg, kvReports, replay)
+	}
 	result.Watch = validateWatch(lg, cfg, reports, replay)
+	if result.Watch.Error() == nil {
+		result.Watch = validateWatchLinearizedOrder(lg, reports, result.Linearization.linearization())
+	}
 	result.Serializable = validateSerializableOperations(lg, serializableOperations, replay)
+	result.Session = validateSession(lg, kvReports, replay)
+	result.Txn = validateTxnBranches(lg, kvReports, replay)
+	if result.Txn.Error() == nil {
+		result.Txn = validateTxnAtomicity(lg, kvReports, replay)
+	}
+	if result.Lease.Error() == nil {
+		result.Lease = validateLeaseAttachment(lg, reports, replay)
+	}
 	return result
 }
 
 type Config struct {
 	ExpectRevisionUnique bool
+	// LatencyOutliers is the number of slowest operations to report in
+	// RobustnessResult, to correlate them with a failure. 0 disables it.
+	LatencyOutliers int
+	// CandidateModels are additional models to check linearization against,
+	// ordered from the strictest to the most relaxed, to characterize the
+	// consistency provided by the tested configuration. They don't affect
+	// the verdict. Models get operations with model.EtcdRequest input and
+	// model.MaybeEtcdResponse output, like model.NonDeterministicModel.
+	CandidateModels []CandidateModel
+	// HistoryPath, if set, is the file the complete KV operation history is
+	// saved to before validation, regardless of its result, so passing runs
+	// can be analyzed later too. It can be loaded with report.LoadOperations.
+	HistoryPath string
+	// Window, if set, limits validation of key value operations to the given
+	// period of the history, to zoom into the region of a failure. Passing
+	// validation of a window doesn't imply the full history passes, see
+	// TimeWindow for the approximations made at its boundaries.
+	Window *TimeWindow
+	// MemoryBudget, if set, is the heap size in bytes above which linearization
+	// is aborted with ErrMemoryBudgetExceeded, before the process gets killed
+	// for running out of memory.
+	MemoryBudget uint64
+	// KeyFilter, if set, limits validation of serializable reads to operations
+	// touching the given key or prefix, to focus on a key suspected of a
+	// failure. Other validations still cover all keys.
+	KeyFilter *KeyFilter
+	// WritesOnly, if set, only validates the write path: revision density of
+	// the writes, that acknowledged writes are not lost and that they result
+	// in the persisted keyspace. Reads are not compared and the history is
+	// not linearized, so the verdict is partial, passing doesn't mean the
+	// history is linearizable.
+	WritesOnly bool
+}
+
+type CandidateModel struct {
+	Name  string
+	Model porcupine.Model
+}
+
+func persistHistory(lg *zap.Logger, path string, reports []report.ClientReport) {
+	var operations []porcupine.Operation
+	for _, r := range reports {
+		operations = append(operations, r.KeyValue...)
+	}
+	// Failing to save the history doesn't invalidate it, so it's not part of the result.
+	if err := report.PersistOperations(lg, path, operations); err != nil {
+		lg.Error("Failed to persist history", zap.String("path", path), zap.Error(err))
+	}
 }
 
 func prepareAndCategorizeOperations(reports []report.ClientReport) (linearizable, serializable, forVisualization []porcupine.Operation) {
@@ -16,6 +16,12 @@
 package validate
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -25,6 +31,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 
+	"go.etcd.io/etcd/server/v3/storage/mvcc"
 	"go.etcd.io/etcd/tests/v3/framework/testutils"
 	"go.etcd.io/etcd/tests/v3/robustness/model"
 	"go.etcd.io/etcd/tests/v3/robustness/report"
@@ -171,19 +178,1376 @@ func TestValidateAndReturnVisualize(t *testing.T) {
 			persistedRequests: []model.EtcdRequest{putRequest("key", "value")},
 			expectError:       "watch: broke Reliable",
 		},
+		{
+			name: "Failure of revision bounds",
+			reports: []report.ClientReport{
+				{
+					KeyValue: []porcupine.Operation{
+						{
+							ClientId: 0,
+							Input:    putRequest("key", "value"),
+							Call:     100,
+							Output:   putResponse(2, model.EtcdOperationResult{}),
+							Return:   200,
+						},
+						{
+							ClientId: 0,
+							Input:    getRequest("key"),
+							Call:     300,
+							Output:   getResponse(1),
+							Return:   400,
+						},
+					},
+				},
+			},
+			persistedRequests: []model.EtcdRequest{putRequest("key", "value")},
+			expectError:       "revision bounds: broke Revision Bounds",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			lg := zaptest.NewLogger(t)
+			result := ValidateAndReturnVisualize(lg, Config{}, tc.reports, tc.persistedRequests, 5*time.Second)
+
+			if tc.expectError != "" {
+				require.ErrorContains(t, result.Error(), tc.expectError)
+			} else {
+				require.NoError(t, result.Error())
+			}
+			err := result.Linearization.Visualize(lg, filepath.Join(t.TempDir(), "history.html"))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateCandidateModels(t *testing.T) {
+	// readOnlyModel is stricter than model.NonDeterministicModel, it doesn't allow any writes.
+	readOnlyModel := model.NonDeterministicModel
+	readOnlyModel.Step = func(st any, in any, out any) (bool, any) {
+		if request := in.(model.EtcdRequest); !request.IsRead() {
+			return false, st
+		}
+		return model.NonDeterministicModel.Step(st, in, out)
+	}
+	reports := []report.ClientReport{
+		{
+			KeyValue: []porcupine.Operation{
+				{ClientId: 0, Input: getRequest("key"), Call: 100, Output: getResponse(1), Return: 200},
+				{ClientId: 0, Input: putRequest("key", "value"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+			},
+		},
+	}
+	cfg := Config{CandidateModels: []CandidateModel{
+		{Name: "read-only", Model: readOnlyModel},
+		{Name: "non-deterministic", Model: model.NonDeterministicModel},
+	}}
+	result := ValidateAndReturnVisualize(zaptest.NewLogger(t), cfg, reports, nil, 5*time.Second)
+	require.NoError(t, result.Error())
+	require.Len(t, result.CandidateModels, 2)
+	require.Equal(t, Failure, result.CandidateModels[0].Result.Status)
+	require.Equal(t, Success, result.CandidateModels[1].Result.Status)
+	strictest, ok := result.StrictestModel()
+	require.True(t, ok)
+	require.Equal(t, "non-deterministic", strictest)
+
+	result = ValidateAndReturnVisualize(zaptest.NewLogger(t), Config{}, reports, nil, 5*time.Second)
+	_, ok = result.StrictestModel()
+	require.False(t, ok)
+}
+
+func TestValidateCandidateModelsShareTimeout(t *testing.T) {
+	// slowModel never finishes linearizing within the timeout.
+	slowModel := model.NonDeterministicModel
+	slowModel.Step = func(st any, in any, out any) (bool, any) {
+		time.Sleep(10 * time.Millisecond)
+		return model.NonDeterministicModel.Step(st, in, out)
+	}
+	var operations []porcupine.Operation
+	for i := 0; i < 100; i++ {
+		operations = append(operations, porcupine.Operation{ClientId: i, Input: putRequest("key", fmt.Sprint(i)), Call: 100, Output: putResponse(int64(i+2), model.EtcdOperationResult{}), Return: 200})
+	}
+	candidates := []CandidateModel{
+		{Name: "slow-1", Model: slowModel},
+		{Name: "slow-2", Model: slowModel},
+		{Name: "slow-3", Model: slowModel},
+	}
+	timeout := 500 * time.Millisecond
+	start := time.Now()
+	results := validateCandidateModels(zaptest.NewLogger(t), candidates, operations, timeout, 0)
+	require.Less(t, time.Since(start), 2*timeout)
+	require.Len(t, results, len(candidates))
+	for i, result := range results {
+		require.Equal(t, candidates[i].Name, result.Name)
+		require.True(t, result.Result.Timeout)
+	}
+}
+
+func TestValidateMemoryBudget(t *testing.T) {
+	restoreLater := memoryCheckInterval
+	memoryCheckInterval = time.Millisecond
+	defer func() { memoryCheckInterval = restoreLater }()
+	// slowModel gives the budget check time to run before linearization ends.
+	slowModel := model.NonDeterministicModel
+	slowModel.Step = func(st any, in any, out any) (bool, any) {
+		time.Sleep(10 * time.Millisecond)
+		return model.NonDeterministicModel.Step(st, in, out)
+	}
+	operations := []porcupine.Operation{
+		{ClientId: 0, Input: getRequest("key"), Call: 100, Output: getResponse(1), Return: 200},
+		{ClientId: 0, Input: putRequest("key", "value"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+		{ClientId: 0, Input: getRequest("key"), Call: 500, Output: getResponseWithKVs(2, keyValueRevision("key", "value", 2)), Return: 600},
+	}
+
+	result := validateLinearizableOperationsWithModel(zaptest.NewLogger(t), slowModel, operations, 5*time.Second, 1)
+	require.True(t, result.MemoryBudgetExceeded)
+	require.ErrorContains(t, result.Error(), ErrMemoryBudgetExceeded.Error())
+
+	result = validateLinearizableOperationsWithModel(zaptest.NewLogger(t), slowModel, operations, 5*time.Second, math.MaxUint64)
+	require.False(t, result.MemoryBudgetExceeded)
+	require.NoError(t, result.Error())
+}
+
+func TestPersistHistory(t *testing.T) {
+	operations := []porcupine.Operation{
+		{ClientId: 1, Input: getRequest("key"), Call: 100, Output: getResponse(1), Return: 200},
+		{ClientId: 1, Input: putRequest("key", "value"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+		// Revision going back fails linearization, history should be persisted anyway.
+		{ClientId: 2, Input: getRequest("key"), Call: 500, Output: getResponse(1), Return: 600},
+	}
+	reports := []report.ClientReport{
+		{ClientID: 1, KeyValue: operations[:2]},
+		{ClientID: 2, KeyValue: operations[2:]},
+	}
+	path := filepath.Join(t.TempDir(), "history.json")
+	result := ValidateAndReturnVisualize(zaptest.NewLogger(t), Config{HistoryPath: path}, reports, nil, 5*time.Second)
+	require.Error(t, result.Error())
+
+	history, err := report.LoadOperations(path)
+	require.NoError(t, err)
+	require.Equal(t, operations, history)
+
+	_, err = report.LoadOperations(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func TestFinalState(t *testing.T) {
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectState map[string]string
+		expectError bool
+	}{
+		{
+			name: "Writes replayed in revision order",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key1", "value2"), Output: putResponse(4, model.EtcdOperationResult{})},
+				{ClientId: 2, Input: putRequest("key1", "value1"), Output: putResponse(2, model.EtcdOperationResult{})},
+				{ClientId: 2, Input: putRequest("key2", "value"), Output: putResponse(3, model.EtcdOperationResult{})},
+				{ClientId: 1, Input: deleteRequest("key2"), Output: putResponse(5, model.EtcdOperationResult{Deleted: 1})},
+				{ClientId: 1, Input: getRequest("key1"), Output: getResponseWithKVs(5, keyValueRevision("key1", "value2", 4))},
+			},
+			expectState: map[string]string{"key1": "value2"},
+		},
+		{
+			name: "Failed comparison",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value1"), Output: putResponse(2, model.EtcdOperationResult{})},
+				{ClientId: 1, Input: compareRevisionAndPutRequest("key", 1, "value2"), Output: model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Revision: 2, Txn: &model.TxnResponse{Failure: true}}}},
+			},
+			expectState: map[string]string{"key": "value1"},
+		},
+		{
+			name: "Persisted failed write",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value1"), Output: model.MaybeEtcdResponse{Persisted: true, PersistedRevision: 3}},
+				{ClientId: 2, Input: putRequest("key", "value2"), Output: putResponse(2, model.EtcdOperationResult{})},
+			},
+			expectState: map[string]string{"key": "value1"},
+		},
+		{
+			name: "Failed write with unknown outcome",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Output: errorResponse(errors.New("timeout"))},
+			},
+			expectError: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := FinalState(tc.operations)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectState, state)
+		})
+	}
+}
+
+func TestVerifyNoLostWrites(t *testing.T) {
+	op := func(clientID int, call, ret int64, request model.EtcdRequest, response model.MaybeEtcdResponse) porcupine.Operation {
+		return porcupine.Operation{ClientId: clientID, Input: request, Call: call, Output: response, Return: ret}
+	}
+	leaseGrant := model.EtcdRequest{Type: model.LeaseGrant, LeaseGrant: &model.LeaseGrantRequest{LeaseID: 1}}
+	leaseRevoke := model.EtcdRequest{Type: model.LeaseRevoke, LeaseRevoke: &model.LeaseRevokeRequest{LeaseID: 1}}
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		persisted   []model.EtcdRequest
+		expectError error
+	}{
+		{
+			name: "Writes present",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(1, 300, 400, putRequest("key2", "value2"), putResponse(3, model.EtcdOperationResult{})),
+			},
+			persisted: []model.EtcdRequest{putRequest("key1", "value1"), putRequest("key2", "value2")},
+		},
+		{
+			name: "Write overwritten",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(2, 300, 400, putRequest("key1", "value2"), putResponse(3, model.EtcdOperationResult{})),
+			},
+			persisted: []model.EtcdRequest{putRequest("key1", "value1"), putRequest("key1", "value2")},
+		},
+		{
+			name: "Write overwritten by request with unknown outcome",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(2, 300, 400, putRequest("key1", "value2"), errorResponse(errors.New("timeout"))),
+			},
+			persisted: []model.EtcdRequest{putRequest("key1", "value1"), putRequest("key1", "value2")},
+		},
+		{
+			name: "Write deleted",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(2, 300, 400, deleteRequest("key1"), putResponse(3, model.EtcdOperationResult{Deleted: 1})),
+			},
+			persisted: []model.EtcdRequest{putRequest("key1", "value1"), deleteRequest("key1")},
+		},
+		{
+			name: "Write deleted by request with unknown outcome",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(2, 300, 400, deleteRequest("key1"), errorResponse(errors.New("timeout"))),
+			},
+			persisted: []model.EtcdRequest{putRequest("key1", "value1"), deleteRequest("key1")},
+		},
+		{
+			name: "Write deleted with its lease",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequestWithLease("key1", "value1", 1), putResponse(2, model.EtcdOperationResult{})),
+			},
+			persisted: []model.EtcdRequest{leaseGrant, putRequestWithLease("key1", "value1", 1), leaseRevoke},
+		},
+		{
+			name: "Write vanished",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(1, 300, 400, putRequest("key2", "value2"), putResponse(3, model.EtcdOperationResult{})),
+			},
+			persisted:   []model.EtcdRequest{putRequest("key2", "value2"), putRequest("key3", "value3")},
+			expectError: ErrLostWrite,
+		},
+		{
+			name: "Write reverted to older value",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(1, 300, 400, putRequest("key1", "value2"), putResponse(3, model.EtcdOperationResult{})),
+			},
+			persisted:   []model.EtcdRequest{putRequest("key1", "value1")},
+			expectError: ErrLostWrite,
+		},
+		{
+			name: "Write persisted with another value",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+			},
+			persisted:   []model.EtcdRequest{putRequest("key1", "value2")},
+			expectError: ErrLostWrite,
+		},
+		{
+			name: "Delete reverted",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), putResponse(2, model.EtcdOperationResult{})),
+				op(1, 300, 400, deleteRequest("key1"), putResponse(3, model.EtcdOperationResult{Deleted: 1})),
+			},
+			persisted:   []model.EtcdRequest{putRequest("key1", "value1"), putRequest("key2", "value2")},
+			expectError: ErrLostWrite,
+		},
+		{
+			name: "Write with unknown outcome not persisted",
+			operations: []porcupine.Operation{
+				op(1, 100, 200, putRequest("key1", "value1"), errorResponse(errors.New("timeout"))),
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorIs(t, VerifyNoLostWrites(tc.operations, model.NewReplay(tc.persisted)), tc.expectError)
+		})
+	}
+}
+
+func TestValidateTimeWindow(t *testing.T) {
+	reports := []report.ClientReport{
+		{
+			ClientID: 1,
+			KeyValue: []porcupine.Operation{
+				{ClientId: 1, Input: getRequest("key"), Call: 100, Output: getResponse(1), Return: 200},
+				{ClientId: 1, Input: putRequest("key", "value1"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+				{ClientId: 1, Input: putRequest("key", "value2"), Call: 700, Output: putResponse(3, model.EtcdOperationResult{}), Return: 800},
+			},
+		},
+		{
+			ClientID: 2,
+			KeyValue: []porcupine.Operation{
+				// stale read, outside of the window
+				{ClientId: 2, Input: getRequest("key"), Call: 450, Output: getResponse(1), Return: 500},
+				{ClientId: 2, Input: getRequest("key"), Call: 550, Output: getResponseWithKVs(2, keyValueRevision("key", "value1", 2)), Return: 650},
+				{ClientId: 2, Input: getRequest("key"), Call: 900, Output: getResponseWithKVs(3, keyValueRevision("key", "value2", 3)), Return: 1000},
+			},
+		},
+		{
+			ClientID: 3,
+			KeyValue: []porcupine.Operation{
+				{ClientId: 3, Input: putRequest("key", "value3"), Call: 860, Output: putResponse(4, model.EtcdOperationResult{}), Return: 950},
+			},
+		},
+		{
+			ClientID: 4,
+			KeyValue: []porcupine.Operation{
+				// read straddling the window end, observing a write called after it
+				{ClientId: 4, Input: getRequest("key"), Call: 840, Output: getResponseWithKVs(4, keyValueRevision("key", "value3", 4)), Return: 870},
+			},
+		},
+	}
+	lg := zaptest.NewLogger(t)
+	result := ValidateAndReturnVisualize(lg, Config{}, reports, nil, 5*time.Second)
+	require.Error(t, result.Error())
+
+	window := &TimeWindow{Start: 600, End: 850}
+	result = ValidateAndReturnVisualize(lg, Config{Window: window}, reports, nil, 5*time.Second)
+	require.NoError(t, result.Error())
+
+	filtered := window.filter(lg, reports)
+	// reads before the window are dropped, writes are kept to build the state
+	require.Equal(t, reports[0].KeyValue[1:], filtered[0].KeyValue)
+	// operations straddling the window start are kept, operations after its end dropped
+	require.Equal(t, reports[1].KeyValue[1:2], filtered[1].KeyValue)
+	require.Empty(t, filtered[2].KeyValue)
+	// reads straddling the window end are dropped like the writes they might observe
+	require.Empty(t, filtered[3].KeyValue)
+}
+
+func TestKeyFilter(t *testing.T) {
+	tcs := []struct {
+		name    string
+		filter  KeyFilter
+		request model.EtcdRequest
+		expect  bool
+	}{
+		{name: "Get key", filter: KeyFilter{Key: "key"}, request: rangeRequest("key", "", 0, 0), expect: true},
+		{name: "Get other key", filter: KeyFilter{Key: "key"}, request: rangeRequest("key1", "", 0, 0), expect: false},
+		{name: "Range including key", filter: KeyFilter{Key: "key"}, request: rangeRequest("a", "z", 0, 0), expect: true},
+		{name: "Range ending at key", filter: KeyFilter{Key: "key"}, request: rangeRequest("a", "key", 0, 0), expect: false},
+		{name: "Range over all keys", filter: KeyFilter{Key: "key"}, request: rangeRequest("", "\x00", 0, 0), expect: true},
+		{name: "Get key with prefix", filter: KeyFilter{Key: "key", Prefix: true}, request: rangeRequest("key1", "", 0, 0), expect: true},
+		{name: "Get key without prefix", filter: KeyFilter{Key: "key", Prefix: true}, request: rangeRequest("kez", "", 0, 0), expect: false},
+		{name: "Range overlapping prefix", filter: KeyFilter{Key: "key", Prefix: true}, request: rangeRequest("key5", "z", 0, 0), expect: true},
+		{name: "Range after prefix", filter: KeyFilter{Key: "key", Prefix: true}, request: rangeRequest("kez", "z", 0, 0), expect: false},
+		{name: "Put key", filter: KeyFilter{Key: "key"}, request: putRequest("key", "value"), expect: true},
+		{name: "Delete key", filter: KeyFilter{Key: "key"}, request: deleteRequest("key"), expect: true},
+		{name: "Put other key", filter: KeyFilter{Key: "key"}, request: putRequest("other", "value"), expect: false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, tc.filter.matches(tc.request))
+		})
+	}
+
+	operations := []porcupine.Operation{
+		{Input: rangeRequest("key", "", 2, 0), Output: rangeResponse(0)},
+		{Input: rangeRequest("other", "", 2, 0), Output: rangeResponse(0)},
+	}
+	require.Equal(t, operations[:1], KeyFilter{Key: "key"}.filter(zaptest.NewLogger(t), operations))
+}
+
+func TestEstimateTimeout(t *testing.T) {
+	sequential := make([]porcupine.Operation, 0, 1000)
+	concurrent := make([]porcupine.Operation, 0, 1000)
+	for i := int64(0); i < 1000; i++ {
+		sequential = append(sequential, porcupine.Operation{Call: 2 * i, Return: 2*i + 1})
+		concurrent = append(concurrent, porcupine.Operation{Call: i, Return: i + 1000})
+	}
+	require.Equal(t, 1, concurrency(sequential))
+	require.Equal(t, 1000, concurrency(concurrent))
+	// operations returning when the next one is called don't overlap
+	require.Equal(t, 1, concurrency([]porcupine.Operation{{Call: 0, Return: 1}, {Call: 1, Return: 2}}))
+	// failed operations never return
+	require.Equal(t, 2, concurrency([]porcupine.Operation{{Call: 0, Return: math.MaxInt64}, {Call: 1, Return: 2}, {Call: 3, Return: 4}}))
+
+	require.Equal(t, minEstimatedTimeout, EstimateTimeout(nil))
+	require.Equal(t, minEstimatedTimeout, EstimateTimeout(sequential))
+	require.Equal(t, 50*time.Second, EstimateTimeout(concurrent))
+	require.Equal(t, 200*time.Second, EstimateTimeout(append(concurrent, concurrent...)))
+	require.Equal(t, maxEstimatedTimeout, EstimateTimeout(append(append(concurrent, concurrent...), append(concurrent, concurrent...)...)))
+}
+
+func TestReportWriters(t *testing.T) {
+	result := RobustnessResult{
+		Assumptions:    ResultFromError(nil),
+		RevisionBounds: ResultFromError(nil),
+		Linearization:  LinearizationResult{Result: ResultFromError(errors.New("broke linearization"))},
+	}
+
+	var text bytes.Buffer
+	require.NoError(t, TextReportWriter{}.WriteReport(&text, result))
+	require.Equal(t, `assumptions: Success
+revision bounds: Success
+linearization: Failure: broke linearization
+revision density: Skipped
+watch: Skipped
+serializable: Skipped
+session: Skipped
+causality: Skipped
+compaction: Skipped
+lease: Skipped
+txn: Skipped
+lost writes: Skipped
+final state: Skipped
+`, text.String())
+
+	var jsonOutput bytes.Buffer
+	require.NoError(t, JSONReportWriter{}.WriteReport(&jsonOutput, result))
+	var jsonResult jsonReport
+	require.NoError(t, json.Unmarshal(jsonOutput.Bytes(), &jsonResult))
+	require.Equal(t, "Failure", jsonResult.Status)
+	require.Equal(t, "linearization: broke linearization", jsonResult.Error)
+	require.Len(t, jsonResult.Phases, len(result.Phases()))
+	require.Equal(t, jsonPhase{Name: "linearization", Status: "Failure", Message: "broke linearization"}, jsonResult.Phases[2])
+
+	var junit bytes.Buffer
+	require.NoError(t, JUnitReportWriter{}.WriteReport(&junit, result))
+	var suite junitTestSuite
+	require.NoError(t, xml.Unmarshal(junit.Bytes(), &suite))
+	require.Equal(t, "robustness", suite.Name)
+	require.Equal(t, 13, suite.Tests)
+	require.Equal(t, 1, suite.Failures)
+	require.Equal(t, 10, suite.Skipped)
+	require.Equal(t, "linearization", suite.TestCases[2].Name)
+	require.Equal(t, &junitFailure{Message: "broke linearization", Details: "broke linearization"}, suite.TestCases[2].Failure)
+}
+
+func TestVisualizeClients(t *testing.T) {
+	lg := zaptest.NewLogger(t)
+	operations := []porcupine.Operation{
+		{ClientId: 1, Input: putRequest("key1", "value1"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+		{ClientId: 2, Input: putRequest("key2", "value2"), Call: 300, Output: putResponse(3, model.EtcdOperationResult{}), Return: 400},
+	}
+	result := validateLinearizableOperationsAndVisualize(lg, operations, 5*time.Second, 0)
+	require.NoError(t, result.Error())
+
+	path := filepath.Join(t.TempDir(), "history.html")
+	require.NoError(t, result.Visualize(lg, path))
+	history, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(history), "key1")
+	require.Contains(t, string(history), "key2")
+
+	result.AddToVisualization([]porcupine.Operation{
+		{ClientId: 2, Input: getRequest("key3"), Call: 500, Output: getResponse(3), Return: 600},
+	})
+	require.NoError(t, result.VisualizeClients(lg, path, 1))
+	history, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(history), "key1")
+	require.NotContains(t, string(history), "key2")
+	require.NotContains(t, string(history), "key3")
+}
+
+func TestVisualizationFilterClients(t *testing.T) {
+	op := func(clientID int) json.RawMessage {
+		return json.RawMessage(fmt.Sprintf(`{"ClientId":%d}`, clientID))
+	}
+	data := visualizationData{
+		Partitions: []visualizationPartition{
+			{
+				History: []json.RawMessage{op(1), op(2), op(1)},
+				PartialLinearizations: [][]visualizationStep{
+					{{Index: 1, StateDescription: "a"}, {Index: 0, StateDescription: "b"}, {Index: 2, StateDescription: "c"}},
+				},
+				Largest: map[int]int{0: 0, 1: 0, 2: 0},
+			},
+			{
+				History:               []json.RawMessage{op(2)},
+				PartialLinearizations: [][]visualizationStep{{{Index: 0, StateDescription: "d"}}},
+				Largest:               map[int]int{0: 0},
+			},
+		},
+		Annotations: []json.RawMessage{op(1), op(2), json.RawMessage(`{"ClientId":0,"Tag":"server"}`)},
+	}
+	require.Equal(t, visualizationData{
+		Partitions: []visualizationPartition{
+			{
+				History: []json.RawMessage{op(1), op(1)},
+				PartialLinearizations: [][]visualizationStep{
+					{{Index: 0, StateDescription: "b"}, {Index: 1, StateDescription: "c"}},
+				},
+				Largest: map[int]int{0: 0, 1: 0},
+			},
+		},
+		Annotations: []json.RawMessage{op(1), json.RawMessage(`{"ClientId":0,"Tag":"server"}`)},
+	}, data.filterClients(map[int]bool{1: true}))
+}
+
+func TestLatencyOutliers(t *testing.T) {
+	reports := []report.ClientReport{
+		{ClientID: 1, KeyValue: []porcupine.Operation{
+			{ClientId: 1, Input: getRequest("key"), Call: 100, Output: getResponse(1), Return: 200},
+			{ClientId: 1, Input: putRequest("key", "value"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 1300},
+		}},
+		{ClientID: 2, KeyValue: []porcupine.Operation{
+			{ClientId: 2, Input: getRequest("key"), Call: 150, Output: getResponse(1), Return: 650},
+		}},
+	}
+	lg := zaptest.NewLogger(t)
+	require.Empty(t, latencyOutliers(lg, reports, 0))
+
+	outliers := latencyOutliers(lg, reports, 2)
+	require.Len(t, outliers, 2)
+	require.Equal(t, OperationLatency{ClientID: 1, Call: 300, Return: 1300, Latency: 1000, Request: putRequest("key", "value"), Response: putResponse(2, model.EtcdOperationResult{})}, outliers[0])
+	require.Equal(t, 2, outliers[1].ClientID)
+	require.Equal(t, 500*time.Nanosecond, outliers[1].Latency)
+
+	require.Len(t, latencyOutliers(lg, reports, 10), 3)
+}
+
+func TestValidateRevisionBounds(t *testing.T) {
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectError error
+	}{
+		{
+			name: "Success",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: getRequest("key"), Call: 300, Output: getResponseWithKVs(2, keyValueRevision("key", "value", 2)), Return: 400},
+			},
+		},
+		{
+			name: "Stale read",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: getRequest("key"), Call: 300, Output: getResponse(1), Return: 400},
+			},
+			expectError: errBrokeRevisionBounds,
+		},
+		{
+			name: "Concurrent read",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 300},
+				{ClientId: 2, Input: getRequest("key"), Call: 200, Output: getResponse(1), Return: 400},
+			},
+		},
+		{
+			name: "Stale write",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key1", "value"), Call: 100, Output: putResponse(3, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: putRequest("key2", "value"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+			},
+			expectError: errBrokeRevisionBounds,
+		},
+		{
+			name: "Read at revision",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: rangeRequest("key", "", 1, 0), Call: 300, Output: rangeResponse(0), Return: 400},
+			},
+		},
+		{
+			name: "Read above max revision",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: getRequest("key"), Call: 300, Output: getResponse(3), Return: 400},
+			},
+			expectError: errRevisionAboveMax,
+		},
+		{
+			name: "Read without writes above initial revision",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: getRequest("key"), Call: 100, Output: getResponse(2), Return: 200},
+			},
+			expectError: errRevisionAboveMax,
+		},
+		{
+			name: "Read of write with unknown outcome",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 300, Output: errorResponse(errors.New("timeout")), Return: 400},
+				{ClientId: 2, Input: getRequest("key"), Call: 500, Output: getResponse(3), Return: 600},
+			},
+		},
+		{
+			name: "Read of revision above write with unknown outcome",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 300, Output: errorResponse(errors.New("timeout")), Return: 400},
+				{ClientId: 2, Input: getRequest("key"), Call: 500, Output: getResponse(4), Return: 600},
+			},
+			expectError: errRevisionAboveMax,
+		},
+		{
+			name: "Read observing revision lower than completed read",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(3, model.EtcdOperationResult{}), Return: 1000},
+				{ClientId: 2, Input: getRequest("key"), Call: 200, Output: getResponse(3), Return: 300},
+				{ClientId: 3, Input: getRequest("key"), Call: 400, Output: getResponse(2), Return: 500},
+			},
+			expectError: errReadRevisionOrder,
+		},
+		{
+			name: "Concurrent reads observing decreasing revisions",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(3, model.EtcdOperationResult{}), Return: 1000},
+				{ClientId: 2, Input: getRequest("key"), Call: 200, Output: getResponse(3), Return: 400},
+				{ClientId: 3, Input: getRequest("key"), Call: 300, Output: getResponse(2), Return: 500},
+			},
+		},
+		{
+			name: "Stale read at revision after completed read",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(3, model.EtcdOperationResult{}), Return: 1000},
+				{ClientId: 2, Input: getRequest("key"), Call: 200, Output: getResponse(3), Return: 300},
+				{ClientId: 3, Input: rangeRequest("key", "", 2, 0), Call: 400, Output: rangeResponse(0), Return: 500},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRevisionBoundsError(zaptest.NewLogger(t), tc.operations)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateCompaction(t *testing.T) {
+	compactRequest := func(rev int64) model.EtcdRequest {
+		return model.EtcdRequest{Type: model.Compact, Compact: &model.CompactRequest{Revision: rev}}
+	}
+	compactResponse := model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Compact: &model.CompactResponse{}, Revision: model.RevisionForNonLinearizableResponse}}
+	compactedResponse := model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{ClientError: mvcc.ErrCompacted.Error()}}
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectError error
+	}{
+		{
+			name: "Read at compacted revision",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: compactRequest(3), Call: 100, Output: compactResponse, Return: 200},
+				{ClientId: 2, Input: rangeRequest("key", "", 3, 0), Call: 300, Output: rangeResponse(0), Return: 400},
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 500, Output: compactedResponse, Return: 600},
+			},
+		},
+		{
+			name: "Read concurrent with compaction",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: compactRequest(3), Call: 100, Output: compactResponse, Return: 400},
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 200, Output: rangeResponse(0), Return: 300},
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 350, Output: compactedResponse, Return: 500},
+			},
+		},
+		{
+			name: "Compaction with unknown outcome",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: compactRequest(3), Call: 100, Output: errorResponse(errors.New("timeout")), Return: 200},
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 300, Output: compactedResponse, Return: 400},
+			},
+		},
+		{
+			name: "Read returned compacted revision",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: compactRequest(3), Call: 100, Output: compactResponse, Return: 200},
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 300, Output: rangeResponse(0), Return: 400},
+			},
+			expectError: errReadAfterCompaction,
+		},
+		{
+			name: "Read returned revision compacted by earlier of multiple compactions",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: compactRequest(3), Call: 100, Output: compactResponse, Return: 200},
+				{ClientId: 1, Input: compactRequest(5), Call: 300, Output: compactResponse, Return: 600},
+				// only the first compaction returned before the reads
+				{ClientId: 2, Input: rangeRequest("key", "", 4, 0), Call: 400, Output: rangeResponse(0), Return: 500},
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 400, Output: rangeResponse(0), Return: 500},
+			},
+			expectError: errReadAfterCompaction,
+		},
+		{
+			name: "Read compacted without compaction",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: compactRequest(2), Call: 100, Output: compactResponse, Return: 200},
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 300, Output: compactedResponse, Return: 400},
+			},
+			expectError: errCompactedWithoutCall,
+		},
+		{
+			name: "Read compacted before compaction",
+			operations: []porcupine.Operation{
+				{ClientId: 2, Input: rangeRequest("key", "", 2, 0), Call: 100, Output: compactedResponse, Return: 200},
+				{ClientId: 1, Input: compactRequest(3), Call: 300, Output: compactResponse, Return: 400},
+			},
+			expectError: errCompactedWithoutCall,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			reports := []report.ClientReport{{KeyValue: tc.operations}}
+			err := validateCompactionError(zaptest.NewLogger(t), reports)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateCompactionLiveKeys(t *testing.T) {
+	persistedRequests := []model.EtcdRequest{
+		putRequest("a", "1"),
+		putRequest("b", "1"),
+		putRequest("a", "2"),
+		putRequest("c", "1"),
+		deleteRequest("b"),
+	}
+	compaction := porcupine.Operation{
+		ClientId: 1,
+		Input:    model.EtcdRequest{Type: model.Compact, Compact: &model.CompactRequest{Revision: 4}},
+		Call:     100,
+		Output:   model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Compact: &model.CompactResponse{}, Revision: model.RevisionForNonLinearizableResponse}},
+		Return:   200,
+	}
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectError error
+	}{
+		{
+			name: "Read returning live keys",
+			operations: []porcupine.Operation{
+				compaction,
+				{ClientId: 2, Input: rangeRequest("a", "z", 0, 0), Call: 300, Output: rangeResponseWithRevision(5, 3, keyValueRevision("a", "2", 4), keyValueRevision("b", "1", 3), keyValueRevision("c", "1", 5)), Return: 400},
+			},
+		},
+		{
+			name: "Read missing key deleted after compaction",
+			operations: []porcupine.Operation{
+				compaction,
+				{ClientId: 2, Input: rangeRequest("a", "z", 0, 0), Call: 300, Output: rangeResponseWithRevision(6, 2, keyValueRevision("a", "2", 4), keyValueRevision("c", "1", 5)), Return: 400},
+			},
+		},
+		{
+			name: "Limited read after compaction",
+			operations: []porcupine.Operation{
+				compaction,
+				{ClientId: 2, Input: rangeRequest("a", "z", 5, 1), Call: 300, Output: rangeResponseWithRevision(6, 3, keyValueRevision("a", "2", 4)), Return: 400},
+			},
+		},
+		{
+			name: "Read concurrent with compaction missing live key",
+			operations: []porcupine.Operation{
+				compaction,
+				{ClientId: 2, Input: rangeRequest("a", "z", 5, 0), Call: 150, Output: rangeResponseWithRevision(6, 2, keyValueRevision("a", "2", 4), keyValueRevision("c", "1", 5)), Return: 400},
+			},
+		},
+		{
+			name: "Read after compaction missing live key",
+			operations: []porcupine.Operation{
+				compaction,
+				{ClientId: 2, Input: rangeRequest("a", "z", 5, 0), Call: 300, Output: rangeResponseWithRevision(6, 2, keyValueRevision("a", "2", 4), keyValueRevision("c", "1", 5)), Return: 400},
+			},
+			expectError: errCompactedLiveKey,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			replay := model.NewReplay(persistedRequests)
+			reports := []report.ClientReport{{KeyValue: tc.operations}}
+			err := validateCompactionLiveKeysError(zaptest.NewLogger(t), reports, replay)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateLease(t *testing.T) {
+	grantRequest := func(leaseID, ttl int64) model.EtcdRequest {
+		return model.EtcdRequest{Type: model.LeaseGrant, LeaseGrant: &model.LeaseGrantRequest{LeaseID: leaseID, TTL: ttl}}
+	}
+	grantResponse := model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{LeaseGrant: &model.LeaseGrantReponse{}, Revision: 1}}
+	revokeRequest := model.EtcdRequest{Type: model.LeaseRevoke, LeaseRevoke: &model.LeaseRevokeRequest{LeaseID: 1}}
+	revokeResponse := model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{LeaseRevoke: &model.LeaseRevokeResponse{}, Revision: 3}}
+	grantAndPut := []porcupine.Operation{
+		{ClientId: 1, Input: grantRequest(1, 10), Call: 0, Output: grantResponse, Return: int64(time.Second)},
+		{ClientId: 1, Input: putRequestWithLease("key", "value", 1), Call: int64(time.Second), Output: putResponse(2), Return: int64(2 * time.Second)},
+	}
+	expiredAt := func(responseTime time.Duration) []model.WatchOperation {
+		return watchResponse(int64(responseTime), deleteWatchEvent("key", 3))
+	}
+	keepAlive := func(call, ret time.Duration, ttl int64) model.LeaseKeepAliveOperation {
+		return model.LeaseKeepAliveOperation{LeaseID: 1, Call: call, Return: ret, TTL: ttl}
+	}
+	timeToLive := func(call, ret time.Duration, ttl int64) model.LeaseTimeToLiveOperation {
+		return model.LeaseTimeToLiveOperation{LeaseID: 1, Call: call, Return: ret, TTL: ttl}
+	}
+	tcs := []struct {
+		name        string
+		reports     []report.ClientReport
+		expectError error
+	}{
+		{
+			name:    "Expired after TTL",
+			reports: []report.ClientReport{{KeyValue: grantAndPut, Watch: expiredAt(11 * time.Second)}},
+		},
+		{
+			name:        "Expired before TTL",
+			reports:     []report.ClientReport{{KeyValue: grantAndPut, Watch: expiredAt(9 * time.Second)}},
+			expectError: errLeasedKeyDeletedEarly,
+		},
+		{
+			name: "Expired after keepalive TTL",
+			reports: []report.ClientReport{{
+				KeyValue:       grantAndPut,
+				LeaseKeepAlive: []model.LeaseKeepAliveOperation{keepAlive(5*time.Second, 6*time.Second, 10)},
+				Watch:          expiredAt(16 * time.Second),
+			}},
+		},
+		{
+			name: "Expired before keepalive TTL",
+			reports: []report.ClientReport{{
+				KeyValue:       grantAndPut,
+				LeaseKeepAlive: []model.LeaseKeepAliveOperation{keepAlive(5*time.Second, 6*time.Second, 10)},
+				Watch:          expiredAt(12 * time.Second),
+			}},
+			expectError: errLeasedKeyDeletedEarly,
+		},
+		{
+			name: "Deleted by client",
+			reports: []report.ClientReport{{
+				KeyValue: append(grantAndPut, porcupine.Operation{ClientId: 1, Input: deleteRequest("key"), Call: int64(3 * time.Second), Output: putResponse(3, model.EtcdOperationResult{Deleted: 1}), Return: int64(4 * time.Second)}),
+				Watch:    expiredAt(5 * time.Second),
+			}},
+		},
+		{
+			name: "Deleted by revoke",
+			reports: []report.ClientReport{{
+				KeyValue: append(grantAndPut, porcupine.Operation{ClientId: 1, Input: revokeRequest, Call: int64(3 * time.Second), Output: revokeResponse, Return: int64(4 * time.Second)}),
+				Watch:    expiredAt(5 * time.Second),
+			}},
+		},
+		{
+			name: "Keepalive after expiry",
+			reports: []report.ClientReport{{
+				KeyValue:       grantAndPut,
+				LeaseKeepAlive: []model.LeaseKeepAliveOperation{keepAlive(11*time.Second, 12*time.Second, 0)},
+			}},
+		},
+		{
+			name: "Keepalive reported expired before TTL",
+			reports: []report.ClientReport{{
+				KeyValue:       grantAndPut,
+				LeaseKeepAlive: []model.LeaseKeepAliveOperation{keepAlive(5*time.Second, 6*time.Second, 10), keepAlive(13*time.Second, 14*time.Second, 0)},
+			}},
+			expectError: errLeaseExpiredEarly,
+		},
+		{
+			name: "Failed keepalive doesn't extend lease",
+			reports: []report.ClientReport{{
+				KeyValue:       grantAndPut,
+				LeaseKeepAlive: []model.LeaseKeepAliveOperation{{LeaseID: 1, Call: 5 * time.Second, Return: 6 * time.Second, Error: "timeout"}},
+				Watch:          expiredAt(12 * time.Second),
+			}},
+		},
+		{
+			name: "Remaining TTL decreasing",
+			reports: []report.ClientReport{{
+				KeyValue:        grantAndPut,
+				LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(3*time.Second, 4*time.Second, 7), timeToLive(5*time.Second, 6*time.Second, 5)},
+			}},
+		},
+		{
+			name: "Remaining TTL increased within granularity",
+			reports: []report.ClientReport{{
+				KeyValue:        grantAndPut,
+				LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(3*time.Second, 4*time.Second, 6), timeToLive(5*time.Second, 6*time.Second, 7)},
+			}},
+		},
+		{
+			name: "Remaining TTL increased without renewal",
+			reports: []report.ClientReport{{
+				KeyValue:        grantAndPut,
+				LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(3*time.Second, 4*time.Second, 5), timeToLive(5*time.Second, 6*time.Second, 9)},
+			}},
+			expectError: errLeaseTTLIncreased,
+		},
+		{
+			name: "Remaining TTL increased across clients",
+			reports: []report.ClientReport{
+				{ClientID: 1, KeyValue: grantAndPut, LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(3*time.Second, 4*time.Second, 5)}},
+				{ClientID: 2, LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(5*time.Second, 6*time.Second, 9)}},
+			},
+			expectError: errLeaseTTLIncreased,
+		},
+		{
+			name: "Remaining TTL increased after keepalive",
+			reports: []report.ClientReport{{
+				KeyValue:        grantAndPut,
+				LeaseKeepAlive:  []model.LeaseKeepAliveOperation{keepAlive(4*time.Second, 5*time.Second, 10)},
+				LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(3*time.Second, 4*time.Second, 5), timeToLive(6*time.Second, 7*time.Second, 9)},
+			}},
+		},
+		{
+			name: "Remaining TTL increased in overlapping queries",
+			reports: []report.ClientReport{
+				{ClientID: 1, KeyValue: grantAndPut, LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(3*time.Second, 6*time.Second, 5)}},
+				{ClientID: 2, LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(2*time.Second, 4*time.Second, 9)}},
+			},
+		},
+		{
+			name: "Lease not found after TTL query",
+			reports: []report.ClientReport{{
+				KeyValue:        grantAndPut,
+				LeaseTimeToLive: []model.LeaseTimeToLiveOperation{timeToLive(3*time.Second, 4*time.Second, 7), timeToLive(11*time.Second, 12*time.Second, -1)},
+			}},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLeaseError(zaptest.NewLogger(t), tc.reports)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateSession(t *testing.T) {
+	tcs := []struct {
+		name              string
+		persistedRequests []model.EtcdRequest
+		operations        []porcupine.Operation
+		expectError       error
+	}{
+		{
+			name: "Success",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("key", "value"),
+			},
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: getRequest("key"), Call: 100, Output: getResponse(1), Return: 200},
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+				{ClientId: 1, Input: getRequest("key"), Call: 500, Output: getResponseWithKVs(2, keyValueRevision("key", "value", 2)), Return: 600},
+			},
+		},
+		{
+			name: "Revision going back",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("key", "value"),
+			},
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 1, Input: getRequest("key"), Call: 300, Output: getResponse(1), Return: 400},
+			},
+			expectError: errBrokeMonotonicRevision,
+		},
+		{
+			name: "Revision going back across clients",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("key", "value"),
+			},
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: getRequest("key"), Call: 300, Output: getResponse(1), Return: 400},
+			},
+		},
+		{
+			name: "Read older value than own write",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("key", "value1"),
+				putRequest("key", "value2"),
+			},
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value1"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 1, Input: putRequest("key", "value2"), Call: 300, Output: putResponse(3, model.EtcdOperationResult{}), Return: 400},
+				{ClientId: 1, Input: getRequest("key"), Call: 500, Output: getResponseWithKVs(3, keyValueRevision("key", "value1", 2)), Return: 600},
+			},
+			expectError: errBrokeReadYourWrites,
+		},
+		{
+			name: "Read missing own write",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("key", "value"),
+			},
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 1, Input: getRequest("key"), Call: 300, Output: getResponse(2), Return: 400},
+			},
+			expectError: errBrokeReadYourWrites,
+		},
+		{
+			name: "Own write deleted by other client",
+			persistedRequests: []model.EtcdRequest{
+				putRequest("key", "value"),
+				deleteRequest("key"),
+			},
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: deleteRequest("key"), Call: 300, Output: putResponse(3, model.EtcdOperationResult{Deleted: 1}), Return: 400},
+				{ClientId: 1, Input: getRequest("key"), Call: 500, Output: getResponse(3), Return: 600},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			replay := model.NewReplay(tc.persistedRequests)
+			reports := []report.ClientReport{{KeyValue: tc.operations}}
+			err := validateSessionError(zaptest.NewLogger(t), reports, replay)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateCausality(t *testing.T) {
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectError error
+	}{
+		{
+			name: "Success",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value1"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 2, Input: getRequest("key"), Call: 300, Output: getResponseWithKVs(2, keyValueRevision("key", "value1", 2)), Return: 400},
+				{ClientId: 1, Input: putRequest("key", "value2"), Call: 500, Output: putResponse(3, model.EtcdOperationResult{}), Return: 600},
+				{ClientId: 2, Input: getRequest("key"), Call: 700, Output: getResponseWithKVs(3, keyValueRevision("key", "value2", 3)), Return: 800},
+			},
+		},
+		{
+			name: "Success regardless of timestamps",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value1"), Call: 500, Output: putResponse(2, model.EtcdOperationResult{}), Return: 600},
+				{ClientId: 2, Input: getRequest("key"), Call: 100, Output: getResponseWithKVs(2, keyValueRevision("key", "value1", 2)), Return: 200},
+			},
+		},
+		{
+			name: "Client write after read of later revision",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: getRequest("key"), Call: 100, Output: getResponseWithKVs(3, keyValueRevision("key", "value2", 3)), Return: 200},
+				{ClientId: 1, Input: putRequest("key", "value1"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+				{ClientId: 2, Input: putRequest("key", "value2"), Call: 300, Output: putResponse(3, model.EtcdOperationResult{}), Return: 400},
+			},
+			expectError: errBrokeCausality,
+		},
+		{
+			name: "Client read missing its own write",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value2"), Call: 100, Output: putResponse(3, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 1, Input: getRequest("key"), Call: 300, Output: getResponseWithKVs(2, keyValueRevision("key", "value1", 2)), Return: 400},
+			},
+			expectError: errBrokeCausality,
+		},
+		{
+			name: "Stale read",
+			operations: []porcupine.Operation{
+				{ClientId: 1, Input: putRequest("key", "value2"), Call: 100, Output: putResponse(3, model.EtcdOperationResult{}), Return: 200},
+				{ClientId: 1, Input: rangeRequest("key", "", 2, 0), Call: 300, Output: getResponseWithKVs(2, keyValueRevision("key", "value1", 2)), Return: 400},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			reports := []report.ClientReport{{KeyValue: tc.operations}}
+			err := validateCausalityError(zaptest.NewLogger(t), reports)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateLeaseAttachment(t *testing.T) {
+	persistedRequests := []model.EtcdRequest{
+		{Type: model.LeaseGrant, LeaseGrant: &model.LeaseGrantRequest{LeaseID: 1}},
+		putRequestWithLease("key1", "value", 1),
+		putRequestWithLease("key2", "value", 1),
+		// detaches key2 from the lease
+		putRequest("key2", "value"),
+		putRequestWithLease("key3", "value", 1),
+		// revoked by a client or by expiration
+		{Type: model.LeaseRevoke, LeaseRevoke: &model.LeaseRevokeRequest{LeaseID: 1}},
+	}
+	watch := func(request model.WatchRequest, events ...model.WatchEvent) []model.WatchOperation {
+		return []model.WatchOperation{{Request: request, Responses: []model.WatchResponse{{Events: events}}}}
+	}
+	watchPrefix := model.WatchRequest{Key: "key", WithPrefix: true}
+	tcs := []struct {
+		name        string
+		watch       []model.WatchOperation
+		expectError error
+	}{
+		{
+			name:  "Revoke deleted attached keys",
+			watch: watch(watchPrefix, deleteWatchEvent("key1", 6), deleteWatchEvent("key3", 6)),
+		},
+		{
+			name:  "Watch of single key",
+			watch: watch(model.WatchRequest{Key: "key1"}, deleteWatchEvent("key1", 6)),
+		},
+		{
+			name:  "No events at revoke revision",
+			watch: watch(watchPrefix, putWatchEvent("key1", "value", 2, true)),
+		},
+		{
+			name:        "Revoke deleted detached key",
+			watch:       watch(watchPrefix, deleteWatchEvent("key1", 6), deleteWatchEvent("key2", 6), deleteWatchEvent("key3", 6)),
+			expectError: errLeaseRevokeMismatch,
+		},
+		{
+			name:        "Revoke missed attached key",
+			watch:       watch(watchPrefix, deleteWatchEvent("key1", 6)),
+			expectError: errLeaseRevokeMismatch,
+		},
+		{
+			name:        "Revoke put key",
+			watch:       watch(watchPrefix, deleteWatchEvent("key1", 6), putWatchEvent("key3", "value", 6, false)),
+			expectError: errLeaseRevokeMismatch,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			replay := model.NewReplay(persistedRequests)
+			reports := []report.ClientReport{{Watch: tc.watch}}
+			err := validateLeaseAttachmentError(zaptest.NewLogger(t), reports, replay)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateDefragLeaseAttachment(t *testing.T) {
+	leaseGrant := model.EtcdRequest{Type: model.LeaseGrant, LeaseGrant: &model.LeaseGrantRequest{LeaseID: 1}}
+	operation := func(request model.EtcdRequest, call, ret time.Duration, revision int64) porcupine.Operation {
+		return porcupine.Operation{Input: request, Call: int64(call), Output: model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Revision: revision}}, Return: int64(ret)}
+	}
+	operations := []porcupine.Operation{
+		operation(putRequest("key2", "value"), 1*time.Second, 2*time.Second, 3),
+		operation(model.EtcdRequest{Type: model.Defragment, Defragment: &model.DefragmentRequest{}}, 3*time.Second, 4*time.Second, model.RevisionForNonLinearizableResponse),
+		operation(getRequest("key1"), 5*time.Second, 6*time.Second, 4),
+	}
+	tcs := []struct {
+		name              string
+		persistedRequests []model.EtcdRequest
+		expectError       error
+	}{
+		{
+			name: "Attachments unchanged",
+			persistedRequests: []model.EtcdRequest{
+				leaseGrant,
+				putRequestWithLease("key1", "value", 1),
+				putRequest("key2", "value"),
+				putRequest("key3", "value"),
+			},
+		},
+		{
+			name: "Key detached by a write",
+			persistedRequests: []model.EtcdRequest{
+				leaseGrant,
+				putRequestWithLease("key1", "value", 1),
+				putRequest("key2", "value"),
+				putRequest("key1", "value"),
+			},
+		},
+		{
+			name: "Key detached without a write",
+			persistedRequests: []model.EtcdRequest{
+				leaseGrant,
+				putRequestWithLease("key1", "value", 1),
+				putRequest("key2", "value"),
+				leaseGrant,
+				putRequest("key3", "value"),
+			},
+			expectError: errLeaseDefragMismatch,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			replay := model.NewReplay(tc.persistedRequests)
+			reports := []report.ClientReport{{KeyValue: operations}}
+			err := validateDefragLeaseAttachment(zaptest.NewLogger(t), reports, replay)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateTxnBranches(t *testing.T) {
+	persistedRequests := []model.EtcdRequest{
+		putRequest("key", "value1"),
+		compareRevisionAndPutRequest("key", 2, "value2"),
+		putRequest("key2", "value1"),
+		putRequest("key3", "value1"),
+	}
+	txnFailureResponse := func(rev int64) model.MaybeEtcdResponse {
+		return model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Revision: rev, Txn: &model.TxnResponse{Failure: true}}}
+	}
+	readKeys := model.EtcdRequest{Type: model.Txn, Txn: &model.TxnRequest{OperationsOnSuccess: []model.EtcdOperation{
+		{Type: model.RangeOperation, Range: model.RangeOptions{Start: "key2"}},
+		{Type: model.RangeOperation, Range: model.RangeOptions{Start: "key3"}},
+	}}}
+	putAndReadKey := model.EtcdRequest{Type: model.Txn, Txn: &model.TxnRequest{OperationsOnSuccess: []model.EtcdOperation{
+		{Type: model.PutOperation, Put: model.PutOptions{Key: "key4", Value: model.ToValueOrHash("value1")}},
+		{Type: model.RangeOperation, Range: model.RangeOptions{Start: "key4"}},
+	}}}
+	rangeResult := func(kvs ...model.KeyValue) model.EtcdOperationResult {
+		return model.EtcdOperationResult{RangeResponse: model.RangeResponse{KVs: kvs, Count: int64(len(kvs))}}
+	}
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectError error
+	}{
+		{
+			name: "Success branch on matching revision",
+			operations: []porcupine.Operation{
+				{Input: compareRevisionAndPutRequest("key", 2, "value2"), Output: putResponse(3, model.EtcdOperationResult{})},
+			},
+		},
+		{
+			name: "Failure branch on not matching revision",
+			operations: []porcupine.Operation{
+				{Input: compareRevisionAndPutRequest("key", 1, "value2"), Output: txnFailureResponse(3)},
+			},
+		},
+		{
+			name: "Success branch on matching version",
+			operations: []porcupine.Operation{
+				{Input: compareVersionAndPutRequest("key", 1, "value2"), Output: putResponse(3, model.EtcdOperationResult{})},
+			},
+		},
+		{
+			name: "Success branch on not matching revision",
+			operations: []porcupine.Operation{
+				{Input: compareRevisionAndPutRequest("key", 1, "value2"), Output: putResponse(3, model.EtcdOperationResult{})},
+			},
+			expectError: errBrokeTxnBranch,
+		},
+		{
+			name: "Failure branch on matching revision",
+			operations: []porcupine.Operation{
+				{Input: compareRevisionAndPutRequest("key", 2, "value2"), Output: txnFailureResponse(2)},
+			},
+			expectError: errBrokeTxnBranch,
+		},
+		{
+			name: "Failure branch on matching version",
+			operations: []porcupine.Operation{
+				{Input: compareVersionAndPutRequest("key", 2, "value2"), Output: txnFailureResponse(3)},
+			},
+			expectError: errBrokeTxnBranch,
+		},
+		{
+			name: "Reads observing single revision",
+			operations: []porcupine.Operation{
+				{Input: readKeys, Output: putResponse(5, rangeResult(keyValueRevision("key2", "value1", 4)), rangeResult(keyValueRevision("key3", "value1", 5)))},
+			},
+		},
+		{
+			name: "Reads observing different revisions",
+			operations: []porcupine.Operation{
+				{Input: readKeys, Output: putResponse(4, rangeResult(keyValueRevision("key2", "value1", 4)), rangeResult(keyValueRevision("key3", "value1", 5)))},
+			},
+			expectError: errTxnReadMismatch,
+		},
+		{
+			name: "Read following write of the transaction",
+			operations: []porcupine.Operation{
+				{Input: putAndReadKey, Output: putResponse(6, model.EtcdOperationResult{}, rangeResult(keyValueRevision("key4", "value1", 6)))},
+			},
+		},
+		{
+			name: "Read missing write of the transaction",
+			operations: []porcupine.Operation{
+				{Input: putAndReadKey, Output: putResponse(6, model.EtcdOperationResult{}, rangeResult())},
+			},
+			expectError: errTxnReadMismatch,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			replay := model.NewReplay(persistedRequests)
+			reports := []report.ClientReport{{KeyValue: tc.operations}}
+			err := validateTxnBranchesError(zaptest.NewLogger(t), reports, replay)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateTxnAtomicity(t *testing.T) {
+	putBoth := model.EtcdRequest{Type: model.Txn, Txn: &model.TxnRequest{OperationsOnSuccess: []model.EtcdOperation{
+		{Type: model.PutOperation, Put: model.PutOptions{Key: "a", Value: model.ToValueOrHash("2")}},
+		{Type: model.PutOperation, Put: model.PutOptions{Key: "b", Value: model.ToValueOrHash("2")}},
+	}}}
+	persistedRequests := []model.EtcdRequest{
+		putRequest("a", "1"),
+		putBoth,
+		putRequest("b", "3"),
+	}
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectError error
+	}{
+		{
+			name: "Read observing all writes of the transaction",
+			operations: []porcupine.Operation{
+				{Input: rangeRequest("a", "z", 0, 0), Output: rangeResponseWithRevision(3, 2, keyValueRevision("a", "2", 3), keyValueRevision("b", "2", 3))},
+			},
+		},
+		{
+			name: "Read observing write following the transaction",
+			operations: []porcupine.Operation{
+				{Input: rangeRequest("a", "z", 0, 0), Output: rangeResponseWithRevision(4, 2, keyValueRevision("a", "2", 3), keyValueRevision("b", "3", 4))},
+			},
+		},
+		{
+			name: "Read of a single key written by the transaction",
+			operations: []porcupine.Operation{
+				{Input: rangeRequest("a", "", 3, 0), Output: rangeResponseWithRevision(3, 1, keyValueRevision("a", "2", 3))},
+			},
+		},
+		{
+			name: "Limited read observing first write of the transaction",
+			operations: []porcupine.Operation{
+				{Input: rangeRequest("a", "z", 3, 1), Output: rangeResponseWithRevision(3, 2, keyValueRevision("a", "2", 3))},
+			},
+		},
+		{
+			name: "Read missing write of the transaction",
+			operations: []porcupine.Operation{
+				{Input: rangeRequest("a", "z", 0, 0), Output: rangeResponseWithRevision(3, 1, keyValueRevision("a", "2", 3))},
+			},
+			expectError: errTxnPartialRead,
+		},
+		{
+			name: "Read observing write of the transaction next to the previous value",
+			operations: []porcupine.Operation{
+				{Input: rangeRequest("a", "z", 3, 0), Output: rangeResponseWithRevision(3, 2, keyValueRevision("a", "1", 2), keyValueRevision("b", "2", 3))},
+			},
+			expectError: errTxnPartialRead,
+		},
 	}
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
-			lg := zaptest.NewLogger(t)
-			result := ValidateAndReturnVisualize(lg, Config{}, tc.reports, tc.persistedRequests, 5*time.Second)
-
-			if tc.expectError != "" {
-				require.ErrorContains(t, result.Error(), tc.expectError)
-			} else {
-				require.NoError(t, result.Error())
-			}
-			err := result.Linearization.Visualize(lg, filepath.Join(t.TempDir(), "history.html"))
-			require.NoError(t, err)
+			replay := model.NewReplay(persistedRequests)
+			reports := []report.ClientReport{{KeyValue: tc.operations}}
+			err := validateTxnAtomicityError(zaptest.NewLogger(t), reports, replay)
+			require.ErrorIs(t, err, tc.expectError)
 		})
 	}
 }
@@ -1728,9 +3092,47 @@ func TestValidateWatch(t *testing.T) {
 			expectError: errBrokeIsCreate.Error(),
 		},
 		{
-			name: "PrevKV - no previous values - pass",
+			name: "PrevKV - no previous values - fail",
+			reports: []report.ClientReport{
+				{
+					Watch: []model.WatchOperation{
+						{
+							Request: model.WatchRequest{
+								Key:        "a",
+								WithPrevKV: true,
+							},
+							Responses: []model.WatchResponse{
+								{
+									Events: []model.WatchEvent{
+										putWatchEvent("a", "1", 2, true),
+										putWatchEvent("a", "2", 3, false),
+										deleteWatchEvent("a", 4),
+										putWatchEvent("a", "4", 5, true),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("a", "2"),
+				deleteRequest("a"),
+				putRequest("a", "4"),
+			},
+			expectError: errBrokePrevKV.Error(),
+		},
+		{
+			name: "PrevKV - no previous values after compaction - pass",
 			reports: []report.ClientReport{
 				{
+					KeyValue: []porcupine.Operation{
+						{
+							Input:  model.EtcdRequest{Type: model.Compact, Compact: &model.CompactRequest{Revision: 4}},
+							Output: model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Compact: &model.CompactResponse{}, Revision: model.RevisionForNonLinearizableResponse}},
+						},
+					},
 					Watch: []model.WatchOperation{
 						{
 							Request: model.WatchRequest{
@@ -1789,6 +3191,38 @@ func TestValidateWatch(t *testing.T) {
 				putRequest("a", "4"),
 			},
 		},
+		{
+			name: "PrevKV - value on create - fail",
+			reports: []report.ClientReport{
+				{
+					Watch: []model.WatchOperation{
+						{
+							Request: model.WatchRequest{
+								Key:        "a",
+								WithPrevKV: true,
+							},
+							Responses: []model.WatchResponse{
+								{
+									Events: []model.WatchEvent{
+										putWatchEventWithPrevKVV("a", "1", 2, true, "0", 1, 1),
+										putWatchEventWithPrevKVV("a", "2", 3, false, "1", 2, 1),
+										deleteWatchEventWithPrevKVV("a", 4, "2", 3, 2),
+										putWatchEvent("a", "4", 5, true),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				putRequest("a", "2"),
+				deleteRequest("a"),
+				putRequest("a", "4"),
+			},
+			expectError: errBrokePrevKV.Error(),
+		},
 		{
 			name: "PrevKV - mismatch value on put - fail",
 			reports: []report.ClientReport{
@@ -1974,6 +3408,86 @@ func TestValidateWatch(t *testing.T) {
 			},
 			expectError: errBrokeFilter.Error(),
 		},
+		{
+			name: "Filter - put events filtered out - pass",
+			reports: []report.ClientReport{
+				{
+					Watch: []model.WatchOperation{
+						{
+							Request: model.WatchRequest{Key: "a", Revision: 2, FilterPut: true},
+							Responses: []model.WatchResponse{
+								{
+									Events: []model.WatchEvent{
+										deleteWatchEvent("a", 3),
+										deleteWatchEvent("a", 5),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				deleteRequest("a"),
+				putRequest("a", "2"),
+				deleteRequest("a"),
+			},
+		},
+		{
+			name: "Filter - filtered out delete event - fail",
+			reports: []report.ClientReport{
+				{
+					Watch: []model.WatchOperation{
+						{
+							Request: model.WatchRequest{Key: "a", Revision: 2, FilterDelete: true},
+							Responses: []model.WatchResponse{
+								{
+									Events: []model.WatchEvent{
+										putWatchEvent("a", "1", 2, true),
+										deleteWatchEvent("a", 3),
+										putWatchEvent("a", "2", 4, true),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				deleteRequest("a"),
+				putRequest("a", "2"),
+				deleteRequest("a"),
+			},
+			expectError: errBrokeFilter.Error(),
+		},
+		{
+			name: "Filter - event not filtered out missing - fail",
+			reports: []report.ClientReport{
+				{
+					Watch: []model.WatchOperation{
+						{
+							Request: model.WatchRequest{Key: "a", Revision: 2, FilterPut: true},
+							Responses: []model.WatchResponse{
+								{
+									Events: []model.WatchEvent{
+										deleteWatchEvent("a", 5),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			persistedRequests: []model.EtcdRequest{
+				putRequest("a", "1"),
+				deleteRequest("a"),
+				putRequest("a", "2"),
+				deleteRequest("a"),
+			},
+			expectError: errBrokeResumable.Error(),
+		},
 	}
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
@@ -1986,6 +3500,212 @@ func TestValidateWatch(t *testing.T) {
 	}
 }
 
+func TestValidateWatchLinearizedOrder(t *testing.T) {
+	linearization := []porcupine.Operation{
+		{ClientId: 1, Input: putRequest("a", "1"), Output: putResponse(2, model.EtcdOperationResult{})},
+		{ClientId: 2, Input: putRequest("b", "2"), Output: putResponse(3, model.EtcdOperationResult{})},
+		{ClientId: 1, Input: putRequest("a", "3"), Output: errorResponse(errors.New("timeout"))},
+		{ClientId: 2, Input: putRequest("b", "2"), Output: putResponse(5, model.EtcdOperationResult{})},
+	}
+	tcs := []struct {
+		name        string
+		events      []model.WatchEvent
+		expectError error
+	}{
+		{
+			name:   "Linearized order",
+			events: []model.WatchEvent{putWatchEvent("a", "1", 2, true), putWatchEvent("b", "2", 3, true), putWatchEvent("a", "3", 4, false)},
+		},
+		{
+			name:   "Subsequence",
+			events: []model.WatchEvent{putWatchEvent("a", "1", 2, true), putWatchEvent("a", "3", 4, false)},
+		},
+		{
+			name:        "Reordered",
+			events:      []model.WatchEvent{putWatchEvent("a", "3", 3, false), putWatchEvent("a", "1", 4, true)},
+			expectError: errBrokeLinearizedOrder,
+		},
+		{
+			name:   "Duplicated put is skipped",
+			events: []model.WatchEvent{putWatchEvent("a", "3", 4, false), putWatchEvent("b", "2", 5, false)},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			reports := []report.ClientReport{{Watch: watchResponse(100, tc.events...)}}
+			err := validateWatchLinearizedOrderError(zaptest.NewLogger(t), reports, linearization)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateRevisionDensity(t *testing.T) {
+	multiPut := putRequest("a", "1")
+	multiPut.Txn.OperationsOnSuccess = append(multiPut.Txn.OperationsOnSuccess, putRequest("b", "1").Txn.OperationsOnSuccess...)
+	leaseGrant := model.EtcdRequest{Type: model.LeaseGrant, LeaseGrant: &model.LeaseGrantRequest{LeaseID: 1}}
+	leaseGrantResponse := model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{LeaseGrant: &model.LeaseGrantReponse{}, Revision: 2}}
+	tcs := []struct {
+		name          string
+		linearization []porcupine.Operation
+		expectError   error
+	}{
+		{
+			name: "Dense",
+			linearization: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Output: putResponse(2, model.EtcdOperationResult{})},
+				{Input: getRequest("a"), Output: getResponse(2)},
+				{Input: multiPut, Output: putResponse(3, model.EtcdOperationResult{}, model.EtcdOperationResult{})},
+				{Input: deleteRequest("a"), Output: putResponse(4, model.EtcdOperationResult{Deleted: 1})},
+				{Input: deleteRequest("a"), Output: putResponse(4, model.EtcdOperationResult{})},
+				{Input: putRequest("a", "2"), Output: putResponse(5, model.EtcdOperationResult{})},
+			},
+		},
+		{
+			name: "Gap explained by write with unknown outcome",
+			linearization: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Output: putResponse(2, model.EtcdOperationResult{})},
+				{Input: putRequest("a", "2"), Output: errorResponse(errors.New("timeout"))},
+				{Input: putRequest("a", "3"), Output: putResponse(4, model.EtcdOperationResult{})},
+			},
+		},
+		{
+			name: "Write with unknown outcome doesn't explain later gaps",
+			linearization: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Output: errorResponse(errors.New("timeout"))},
+				{Input: putRequest("a", "2"), Output: putResponse(2, model.EtcdOperationResult{})},
+				{Input: putRequest("a", "3"), Output: putResponse(4, model.EtcdOperationResult{})},
+			},
+			expectError: errRevisionGap,
+		},
+		{
+			name: "Gap explained by lease expiration",
+			linearization: []porcupine.Operation{
+				{Input: leaseGrant, Output: leaseGrantResponse},
+				{Input: putRequestWithLease("a", "1", 1), Output: putResponse(2, model.EtcdOperationResult{})},
+				{Input: putRequest("b", "1"), Output: putResponse(4, model.EtcdOperationResult{})},
+			},
+		},
+		{
+			name: "Lease expires only once",
+			linearization: []porcupine.Operation{
+				{Input: leaseGrant, Output: leaseGrantResponse},
+				{Input: putRequestWithLease("a", "1", 1), Output: putResponse(2, model.EtcdOperationResult{})},
+				{Input: putRequest("b", "1"), Output: putResponse(4, model.EtcdOperationResult{})},
+				{Input: putRequest("b", "2"), Output: putResponse(6, model.EtcdOperationResult{})},
+			},
+			expectError: errRevisionGap,
+		},
+		{
+			name: "Gap",
+			linearization: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Output: putResponse(2, model.EtcdOperationResult{})},
+				{Input: putRequest("a", "2"), Output: putResponse(4, model.EtcdOperationResult{})},
+			},
+			expectError: errRevisionGap,
+		},
+		{
+			name: "Repeat",
+			linearization: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Output: putResponse(2, model.EtcdOperationResult{})},
+				{Input: putRequest("b", "1"), Output: putResponse(2, model.EtcdOperationResult{})},
+			},
+			expectError: errRevisionRepeated,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRevisionDensityError(zaptest.NewLogger(t), tc.linearization)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
+func TestValidateWritesOnly(t *testing.T) {
+	operations := []porcupine.Operation{
+		{ClientId: 1, Input: getRequest("key"), Call: 100, Output: getResponse(1), Return: 200},
+		{ClientId: 1, Input: putRequest("key", "value1"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+		{ClientId: 1, Input: putRequest("key", "value2"), Call: 500, Output: putResponse(3, model.EtcdOperationResult{}), Return: 600},
+		// Read observing a write called after it returned isn't linearizable.
+		{ClientId: 2, Input: getRequest("key"), Call: 350, Output: getResponseWithKVs(3, keyValueRevision("key", "value2", 3)), Return: 360},
+	}
+	reports := []report.ClientReport{
+		{ClientID: 1, KeyValue: operations[:3]},
+		{ClientID: 2, KeyValue: operations[3:]},
+	}
+	persisted := []model.EtcdRequest{putRequest("key", "value1"), putRequest("key", "value2")}
+
+	result := ValidateAndReturnVisualize(zaptest.NewLogger(t), Config{}, reports, persisted, 5*time.Second)
+	require.ErrorContains(t, result.Error(), "linearization")
+
+	result = ValidateAndReturnVisualize(zaptest.NewLogger(t), Config{WritesOnly: true}, reports, persisted, 5*time.Second)
+	require.NoError(t, result.Error())
+	require.Equal(t, Unknown, result.Linearization.Status)
+	require.Equal(t, Success, result.RevisionDensity.Status)
+	require.Equal(t, Success, result.LostWrites.Status)
+	require.Equal(t, Success, result.FinalState.Status)
+
+	result = ValidateAndReturnVisualize(zaptest.NewLogger(t), Config{WritesOnly: true}, reports, persisted[:1], 5*time.Second)
+	require.ErrorContains(t, result.FinalState.Error(), errFinalStateMismatch.Error())
+}
+
+func TestValidateWriteRevisions(t *testing.T) {
+	leaseGrant := model.EtcdRequest{Type: model.LeaseGrant, LeaseGrant: &model.LeaseGrantRequest{LeaseID: 1}}
+	leaseGrantResponse := model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{LeaseGrant: &model.LeaseGrantReponse{}, Revision: 1}}
+	tcs := []struct {
+		name        string
+		operations  []porcupine.Operation
+		expectError error
+	}{
+		{
+			name: "Dense in revision order",
+			operations: []porcupine.Operation{
+				{Input: putRequest("a", "2"), Call: 100, Output: putResponse(3, model.EtcdOperationResult{}), Return: 400},
+				{Input: getRequest("a"), Call: 150, Output: getResponse(3), Return: 250},
+				{Input: putRequest("a", "1"), Call: 200, Output: putResponse(2, model.EtcdOperationResult{}), Return: 300},
+			},
+		},
+		{
+			name: "Gap explained by write with unknown outcome called before",
+			operations: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{Input: putRequest("a", "3"), Call: 300, Output: putResponse(4, model.EtcdOperationResult{}), Return: 400},
+				{Input: putRequest("a", "2"), Call: 250, Output: errorResponse(errors.New("timeout")), Return: 500},
+			},
+		},
+		{
+			name: "Write with unknown outcome called after doesn't explain gap",
+			operations: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{Input: putRequest("a", "3"), Call: 300, Output: putResponse(4, model.EtcdOperationResult{}), Return: 400},
+				{Input: putRequest("a", "2"), Call: 450, Output: errorResponse(errors.New("timeout")), Return: 500},
+			},
+			expectError: errRevisionGap,
+		},
+		{
+			name: "Gap explained by lease expiration",
+			operations: []porcupine.Operation{
+				{Input: leaseGrant, Call: 100, Output: leaseGrantResponse, Return: 200},
+				{Input: putRequestWithLease("a", "1", 1), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+				{Input: putRequest("b", "1"), Call: 500, Output: putResponse(4, model.EtcdOperationResult{}), Return: 600},
+			},
+		},
+		{
+			name: "Repeat",
+			operations: []porcupine.Operation{
+				{Input: putRequest("a", "1"), Call: 100, Output: putResponse(2, model.EtcdOperationResult{}), Return: 200},
+				{Input: putRequest("b", "1"), Call: 300, Output: putResponse(2, model.EtcdOperationResult{}), Return: 400},
+			},
+			expectError: errRevisionRepeated,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateWriteRevisionsError(zaptest.NewLogger(t), tc.operations)
+			require.ErrorIs(t, err, tc.expectError)
+		})
+	}
+}
+
 func putWatchEvent(key, value string, rev int64, isCreate bool) model.WatchEvent {
 	return model.WatchEvent{
 		PersistedEvent: putPersistedEvent(key, value, rev, isCreate),
@@ -2065,6 +3785,10 @@ func getResponse(rev int64) model.MaybeEtcdResponse {
 	return model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Revision: rev, Range: &model.RangeResponse{KVs: []model.KeyValue{}}}}
 }
 
+func getResponseWithKVs(rev int64, kvs ...model.KeyValue) model.MaybeEtcdResponse {
+	return model.MaybeEtcdResponse{EtcdResponse: model.EtcdResponse{Revision: rev, Range: &model.RangeResponse{KVs: kvs, Count: int64(len(kvs))}}}
+}
+
 func putRequest(key, value string) model.EtcdRequest {
 	return model.EtcdRequest{
 		Type:        model.Txn,
@@ -2088,6 +3812,18 @@ func putRequest(key, value string) model.EtcdRequest {
 	}
 }
 
+func compareRevisionAndPutRequest(key string, expectedRevision int64, value string) model.EtcdRequest {
+	req := putRequest(key, value)
+	req.Txn.Conditions = []model.EtcdCondition{{Key: key, ExpectedRevision: expectedRevision}}
+	return req
+}
+
+func compareVersionAndPutRequest(key string, expectedVersion int64, value string) model.EtcdRequest {
+	req := putRequest(key, value)
+	req.Txn.Conditions = []model.EtcdCondition{{Key: key, ExpectedVersion: expectedVersion}}
+	return req
+}
+
 func putRequestWithLease(key, value string, leaseID int64) model.EtcdRequest {
 	req := putRequest(key, value)
 	req.Txn.OperationsOnSuccess[0].Put.LeaseID = leaseID
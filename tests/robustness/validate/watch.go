@@ -51,6 +51,7 @@ func validateWatch(lg *zap.Logger, cfg Config, reports []report.ClientReport, re
 
 func validateWatchError(lg *zap.Logger, cfg Config, reports []report.ClientReport, replay *model.EtcdReplay) error {
 	// Validate etcd watch properties defined in https://etcd.io/docs/v3.6/learning/api_guarantees/#watch-apis
+	compactRevision := maxCompactRevision(reports)
 	for _, r := range reports {
 		err := validateFilter(lg, r)
 		if err != nil {
@@ -80,7 +81,7 @@ func validateWatchError(lg *zap.Logger, cfg Config, reports []report.ClientRepor
 		if err != nil {
 			return err
 		}
-		err = validatePrevKV(lg, replay, r)
+		err = validatePrevKV(lg, replay, r, compactRevision)
 		if err != nil {
 			return err
 		}
@@ -248,8 +249,11 @@ func validateResumable(lg *zap.Logger, replay *model.EtcdReplay, report report.C
 }
 
 // validatePrevKV ensures that a watch response (if configured with WithPrevKV()) returns
-// the appropriate response.
-func validatePrevKV(lg *zap.Logger, replay *model.EtcdReplay, report report.ClientReport) (err error) {
+// the appropriate response: the value of the key in the state just before the
+// event, and no value for the event creating the key. The previous value can
+// only be missing if it might have been compacted, by a compaction of the
+// event revision or a later one.
+func validatePrevKV(lg *zap.Logger, replay *model.EtcdReplay, report report.ClientReport, compactRevision int64) (err error) {
 	for _, op := range report.Watch {
 		if !op.Request.WithPrevKV {
 			continue
@@ -261,21 +265,13 @@ func validatePrevKV(lg *zap.Logger, replay *model.EtcdReplay, report report.Clie
 				if err2 != nil {
 					panic(err2)
 				}
-				// TODO(MadhavJivrajani): check if compaction has been run as part
-				// of failpoint injection. If compaction has run, prevKV can be nil
-				// even if it is not a create event.
-				//
-				// Considering that Kubernetes opens watches to etcd using WithPrevKV()
-				// option, ideally we would want to explicitly check the condition that
-				// Kubernetes does while parsing events received from etcd:
-				// https://github.com/kubernetes/kubernetes/blob/a9e4f5b7862e84c4152eabe2e960f3f6fb9a4867/staging/src/k8s.io/apiserver/pkg/storage/etcd3/event.go#L59
-				// i.e. prevKV is nil iff the event is a create event, we cannot reliably
-				// check that without knowing if compaction has run.
-
-				// We allow PrevValue to be nil since in the face of compaction, etcd does not
-				// guarantee its presence.
-				if event.PrevValue != nil && *event.PrevValue != state.KeyValues[event.Key] {
-					lg.Error("Incorrect event prevValue field", zap.Int("client", report.ClientID), zap.Any("event", event), zap.Any("previousValue", state.KeyValues[event.Key]))
+				previous, prevKeyExists := state.KeyValues[event.Key]
+				switch {
+				case event.PrevValue != nil && *event.PrevValue != previous:
+					lg.Error("Incorrect event prevValue field", zap.Int("client", report.ClientID), zap.Any("event", event), zap.Any("previousValue", previous))
+					err = errBrokePrevKV
+				case event.PrevValue == nil && prevKeyExists && event.Revision > compactRevision:
+					lg.Error("Missing event prevValue field", zap.Int("client", report.ClientID), zap.Any("event", event), zap.Any("previousValue", previous), zap.Int64("compact-revision", compactRevision))
 					err = errBrokePrevKV
 				}
 			}
@@ -284,6 +280,22 @@ func validatePrevKV(lg *zap.Logger, replay *model.EtcdReplay, report report.Clie
 	return err
 }
 
+// maxCompactRevision returns the highest revision compactions were requested
+// for, including the ones with unknown outcome, 0 if there is none.
+func maxCompactRevision(reports []report.ClientReport) (revision int64) {
+	for _, r := range reports {
+		for _, op := range r.KeyValue {
+			request := op.Input.(model.EtcdRequest)
+			response := op.Output.(model.MaybeEtcdResponse)
+			// A compaction that failed with an error known to etcd didn't happen.
+			if request.Type == model.Compact && response.ClientError == "" {
+				revision = max(revision, request.Compact.Revision)
+			}
+		}
+	}
+	return revision
+}
+
 func validateIsCreate(lg *zap.Logger, replay *model.EtcdReplay, report report.ClientReport) (err error) {
 	for _, op := range report.Watch {
 		for _, resp := range op.Responses {
@@ -0,0 +1,101 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+var errBrokeLinearizedOrder = errors.New("broke Linearized Order - watch delivered events in a different order than the writes were linearized")
+
+// validateWatchLinearizedOrder checks that each watch delivers events of puts
+// in the order the writes were linearized, so the watch didn't diverge from
+// the committed order found by linearization. Puts are matched with events by
+// key and value, so puts that aren't unique are skipped.
+func validateWatchLinearizedOrder(lg *zap.Logger, reports []report.ClientReport, linearization []porcupine.Operation) Result {
+	lg.Info("Validating watch linearized order")
+	start := time.Now()
+	err := validateWatchLinearizedOrderError(lg, reports, linearization)
+	if err != nil {
+		lg.Error("Watch linearized order validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+	lg.Info("Watch linearized order validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(err)
+}
+
+type putValue struct {
+	key   string
+	value model.ValueOrHash
+}
+
+func validateWatchLinearizedOrderError(lg *zap.Logger, reports []report.ClientReport, linearization []porcupine.Operation) error {
+	// position holds the index in the linearization of the write putting the
+	// value, -1 if more than one write did.
+	position := map[putValue]int{}
+	for i, op := range linearization {
+		request := op.Input.(model.EtcdRequest)
+		if request.Type != model.Txn {
+			continue
+		}
+		response := op.Output.(model.MaybeEtcdResponse)
+		ops := append(request.Txn.OperationsOnSuccess, request.Txn.OperationsOnFailure...)
+		if response.Txn != nil {
+			ops = request.Txn.OperationsOnSuccess
+			if response.Txn.Failure {
+				ops = request.Txn.OperationsOnFailure
+			}
+		}
+		for _, txnOp := range ops {
+			if txnOp.Type != model.PutOperation {
+				continue
+			}
+			kv := putValue{key: txnOp.Put.Key, value: txnOp.Put.Value}
+			if _, ok := position[kv]; ok {
+				position[kv] = -1
+				continue
+			}
+			position[kv] = i
+		}
+	}
+	for _, r := range reports {
+		for _, watch := range r.Watch {
+			last := -1
+			for _, resp := range watch.Responses {
+				for _, event := range resp.Events {
+					if event.Type != model.PutOperation {
+						continue
+					}
+					i, ok := position[putValue{key: event.Key, value: event.Value}]
+					if !ok || i < 0 {
+						continue
+					}
+					if i < last {
+						lg.Error("Watch event out of linearized order", zap.Int("client", r.ClientID), zap.String("key", event.Key), zap.Int64("revision", event.Revision), zap.Int("position", i), zap.Int("previous-position", last))
+						return errBrokeLinearizedOrder
+					}
+					last = i
+				}
+			}
+		}
+	}
+	return nil
+}
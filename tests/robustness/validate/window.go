@@ -0,0 +1,62 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+	"go.etcd.io/etcd/tests/v3/robustness/report"
+)
+
+// TimeWindow is a period of the history, expressed like operation call and
+// return times, as durations since the start of the history.
+type TimeWindow struct {
+	Start time.Duration
+	// End of the window, 0 means the end of the history.
+	End time.Duration
+}
+
+// filter returns the reports with only the key value operations relevant to
+// the window: operations called before its end that returned after its start.
+// Writes called before its end are all kept, as they build the state seen by
+// operations within the window. Reads returning after its end are dropped
+// too, as they might have observed writes dropped for being called after it.
+// Passing validation of the result doesn't imply the full history passes.
+// Neither is the opposite guaranteed, as a dropped write might have taken
+// effect before a kept one still in flight at the end of the window, so a
+// failure is worth confirming on the full history.
+func (w TimeWindow) filter(lg *zap.Logger, reports []report.ClientReport) []report.ClientReport {
+	lg.Warn("Validating operations within time window only, passing validation doesn't imply the full history is valid",
+		zap.Duration("start", w.Start), zap.Duration("end", w.End))
+	filtered := make([]report.ClientReport, 0, len(reports))
+	for _, r := range reports {
+		windowed := report.ClientReport{ClientID: r.ClientID, Watch: r.Watch}
+		for _, op := range r.KeyValue {
+			if w.End != 0 && op.Call >= w.End.Nanoseconds() {
+				continue
+			}
+			request := op.Input.(model.EtcdRequest)
+			if request.IsRead() && (op.Return < w.Start.Nanoseconds() || w.End != 0 && op.Return > w.End.Nanoseconds()) {
+				continue
+			}
+			windowed.KeyValue = append(windowed.KeyValue, op)
+		}
+		filtered = append(filtered, windowed)
+	}
+	return filtered
+}
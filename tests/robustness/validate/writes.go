@@ -0,0 +1,157 @@
+// Copyright 2025 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"go.uber.org/zap"
+
+	"go.etcd.io/etcd/tests/v3/robustness/model"
+)
+
+var errFinalStateMismatch = errors.New("final state of acknowledged writes doesn't match the persisted state")
+
+// validateWritesOnly runs the validations of the write path, see
+// Config.WritesOnly, on the linearizable operations patched with the persisted
+// requests.
+func validateWritesOnly(lg *zap.Logger, result RobustnessResult, operations []porcupine.Operation, persistedRequests []model.EtcdRequest) RobustnessResult {
+	lg.Info("Validating writes only, reads are not validated")
+	result.RevisionDensity = validateWriteRevisions(lg, operations)
+	if len(persistedRequests) == 0 {
+		lg.Info("Skipping lost writes and final state validation as persisted requests were empty")
+		return result
+	}
+	result.LostWrites = ResultFromError(VerifyNoLostWrites(operations, model.NewReplay(persistedRequests)))
+	result.FinalState = validateFinalState(lg, operations, persistedRequests)
+	return result
+}
+
+// validateWriteRevisions checks revision density like validateRevisionDensity,
+// but without a linearization, walking writes in the order of their revisions.
+// The revisions skipped before a write have to be explained by writes with
+// unknown outcome, lease revokes or leases that could have expired, called
+// before the write returned.
+func validateWriteRevisions(lg *zap.Logger, operations []porcupine.Operation) Result {
+	lg.Info("Validating revision density of writes")
+	start := time.Now()
+	err := validateWriteRevisionsError(lg, operations)
+	if err != nil {
+		lg.Error("Revision density validation of writes failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+		return ResultFromError(err)
+	}
+	lg.Info("Revision density validation of writes success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(nil)
+}
+
+func validateWriteRevisionsError(lg *zap.Logger, operations []porcupine.Operation) error {
+	type write struct {
+		op       porcupine.Operation
+		revision int64
+	}
+	var writes []write
+	// maybeCalls are the call times of the operations that might have
+	// incremented the revision once.
+	var maybeCalls []int64
+	for _, op := range operations {
+		request := op.Input.(model.EtcdRequest)
+		response := op.Output.(model.MaybeEtcdResponse)
+		if response.ClientError != "" {
+			continue
+		}
+		switch {
+		case request.Type == model.LeaseGrant:
+			maybeCalls = append(maybeCalls, op.Call)
+		case request.Type != model.Txn && request.Type != model.LeaseRevoke, request.IsRead():
+		case response.Persisted && response.PersistedRevision > 0:
+			writes = append(writes, write{op: op, revision: response.PersistedRevision})
+		case response.Error != "" || response.Persisted, request.Type == model.LeaseRevoke:
+			// Revoking a lease without keys doesn't increment the revision.
+			maybeCalls = append(maybeCalls, op.Call)
+		case isWrite(request, response):
+			writes = append(writes, write{op: op, revision: response.Revision})
+		}
+	}
+	sort.SliceStable(writes, func(i, j int) bool { return writes[i].revision < writes[j].revision })
+	sort.Slice(maybeCalls, func(i, j int) bool { return maybeCalls[i] < maybeCalls[j] })
+
+	// The database is expected to be empty at start.
+	last := int64(1)
+	for i, w := range writes {
+		if w.revision <= last {
+			lg.Error("Write didn't increment the revision", zap.Int("client", w.op.ClientId), zap.Int64("revision", w.revision), zap.Int64("previous-revision", last), zap.Any("request", w.op.Input), zap.Any("response", w.op.Output))
+			return errRevisionRepeated
+		}
+		// An operation can only have produced a revision lower than the write
+		// if it was called before the write returned.
+		skipped := w.revision - 2 - int64(i)
+		explained := int64(sort.Search(len(maybeCalls), func(j int) bool { return maybeCalls[j] >= w.op.Return }))
+		if skipped > explained {
+			lg.Error("Write revision skipped revisions", zap.Int("client", w.op.ClientId), zap.Int64("revision", w.revision), zap.Int64("skipped", skipped), zap.Int64("explained", explained), zap.Any("request", w.op.Input), zap.Any("response", w.op.Output))
+			return errRevisionGap
+		}
+		last = w.revision
+	}
+	return nil
+}
+
+// validateFinalState checks that the writes of the operations, replayed in the
+// order of their revisions, result in the same keyspace as the persisted
+// requests. It is skipped if the outcome of a write is unknown.
+func validateFinalState(lg *zap.Logger, operations []porcupine.Operation, persistedRequests []model.EtcdRequest) Result {
+	lg.Info("Validating final state")
+	start := time.Now()
+	expected, err := FinalState(operations)
+	if err != nil {
+		lg.Info("Skipping final state validation", zap.Error(err))
+		return Result{}
+	}
+	err = validateFinalStateError(lg, expected, persistedRequests)
+	if err != nil {
+		lg.Error("Final state validation failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+		return ResultFromError(err)
+	}
+	lg.Info("Final state validation success", zap.Duration("duration", time.Since(start)))
+	return ResultFromError(nil)
+}
+
+func validateFinalStateError(lg *zap.Logger, expected map[string]string, persistedRequests []model.EtcdRequest) error {
+	state := model.DeterministicModel.Init().(model.EtcdState)
+	for _, request := range persistedRequests {
+		state, _ = state.Step(request)
+	}
+	for key, value := range expected {
+		persisted, ok := state.KeyValues[key]
+		if !ok {
+			lg.Error("Key of acknowledged write not persisted", zap.String("key", key), zap.String("value", value))
+			return fmt.Errorf("%w: key %q missing", errFinalStateMismatch, key)
+		}
+		if persisted.Value != model.ToValueOrHash(value) {
+			lg.Error("Value of acknowledged write not persisted", zap.String("key", key), zap.String("value", value), zap.Any("persisted", persisted.Value))
+			return fmt.Errorf("%w: key %q has different value", errFinalStateMismatch, key)
+		}
+	}
+	for key := range state.KeyValues {
+		if _, ok := expected[key]; !ok {
+			lg.Error("Persisted key not written by acknowledged writes", zap.String("key", key))
+			return fmt.Errorf("%w: key %q unexpected", errFinalStateMismatch, key)
+		}
+	}
+	return nil
+}
@@ -51,7 +51,9 @@ func readRaw(fromIndex *uint64, waldir string, out io.Writer) {
 		}
 		walReaders = append(walReaders, fileutil.NewFileReader(f))
 	}
-	decoder := wal.NewDecoderAdvanced(true, walReaders...)
+	// Copied or truncated files may end within the zero padding of a
+	// preallocated segment, which doesn't mean the last record is corrupted.
+	decoder := wal.NewDecoderWithConfig(wal.DecoderConfig{ContinueOnCrcError: true, TolerateZeroPadding: true}, walReaders...)
 	// The variable is used to not pollute log with multiple continuous crc errors.
 	crcDesync := false
 	for {
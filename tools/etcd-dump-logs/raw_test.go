@@ -15,9 +15,13 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_readRaw(t *testing.T) {
@@ -66,3 +70,23 @@ Entry: Term:27 Index:34 Data:"?"
 EOF: All entries were processed.
 `, out.String())
 }
+
+func Test_readRawTruncatedInPadding(t *testing.T) {
+	path := t.TempDir()
+	mustCreateWALLog(t, path)
+	names, err := filepath.Glob(filepath.Join(walDir(path), "*.wal"))
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+
+	// cut the file 3 bytes into the zero padding after the written frames,
+	// which end 8-byte aligned
+	data, err := os.ReadFile(names[0])
+	require.NoError(t, err)
+	end := len(bytes.TrimRight(data, "\x00"))
+	end += (8 - end%8) % 8
+	require.NoError(t, os.Truncate(names[0], int64(end+3)))
+
+	var out bytes.Buffer
+	readRaw(nil, walDir(path), &out)
+	assert.True(t, strings.HasSuffix(out.String(), "Entry: Term:27 Index:34 Data:\"?\" \nEOF: All entries were processed.\n"), out.String())
+}